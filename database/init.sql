@@ -1,28 +1,66 @@
 CREATE TYPE media_type AS ENUM ('Image', 'Video', 'Audio', 'Book');
 CREATE TYPE tag_type AS ENUM ('General', 'People');
+CREATE TYPE processing_state AS ENUM ('Pending', 'Ready', 'Failed');
+CREATE TYPE job_state AS ENUM ('Queued', 'Running', 'Done', 'Failed');
+
+CREATE TABLE "roles" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "name" character varying NOT NULL,
+  "permissions" jsonb NOT NULL,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "roles_name_key" ON "roles" ("name");
+
+INSERT INTO "roles" ("name", "permissions") VALUES
+  ('viewer', '[]'),
+  ('uploader', '["post.create", "invite.create"]'),
+  ('moderator', '["post.create", "post.edit.any", "post.delete.any", "tag.edit", "invite.create"]'),
+  ('admin', '["post.create", "post.edit.any", "post.delete.any", "tag.edit", "user.manage", "invite.create"]');
 
 CREATE TABLE "users" (
   "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
   "username" character varying NOT NULL,
   "pass_hash" character varying NOT NULL,
-  "is_admin" boolean NOT NULL DEFAULT false,
-  PRIMARY KEY ("id")
+  "email" character varying NULL,
+  "disabled" boolean NOT NULL DEFAULT false,
+  "totp_secret" character varying NULL,
+  "totp_enabled" boolean NOT NULL DEFAULT false,
+  "totp_required" boolean NOT NULL DEFAULT false,
+  "totp_last_step" bigint NOT NULL DEFAULT 0,
+  "display_name" character varying NULL,
+  "bio" text NULL,
+  "avatar" character varying NULL,
+  "favourites_public" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "role_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "users_roles_users" FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE NO ACTION
 );
 
 CREATE UNIQUE INDEX "users_username_key" ON "users" ("username");
+CREATE UNIQUE INDEX "users_email_key" ON "users" ("email");
 
 CREATE TABLE "posts" (
   "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
   "title" character varying NOT NULL,
   "media_type" media_type NOT NULL,
   "filename" character varying NOT NULL,
+  "content_hash" character varying NULL,
   "file_ext" character varying NOT NULL,
   "user_owns" bigint NULL,
+  "processing_state" processing_state NOT NULL DEFAULT 'Ready',
+  "audio_title" character varying NULL,
+  "artist" character varying NULL,
+  "album" character varying NULL,
+  "duration_ms" bigint NULL,
+  "bitrate" bigint NULL,
   PRIMARY KEY ("id"),
   CONSTRAINT "posts_users_owns" FOREIGN KEY ("user_owns") REFERENCES "users" ("id") ON DELETE SET NULL
 );
 
 CREATE UNIQUE INDEX "posts_filename_key" ON "posts" ("filename");
+CREATE UNIQUE INDEX "posts_content_hash_key" ON "posts" ("content_hash");
 
 CREATE TABLE "tags" (
   "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
@@ -44,10 +82,170 @@ CREATE TABLE "post_tags" (
 CREATE TABLE "sessions" (
   "id" character varying NOT NULL,
   "user_id" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "last_seen_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "user_agent" character varying NOT NULL DEFAULT '',
+  "ip" character varying NOT NULL DEFAULT '',
   PRIMARY KEY ("id"),
   CONSTRAINT "sessions_users_sessions" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
 );
 
+CREATE INDEX "session_expires_at" ON "sessions" ("expires_at");
+
+CREATE TABLE "login_throttles" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "kind" character varying NOT NULL,
+  "key" character varying NOT NULL,
+  "failures" bigint NOT NULL DEFAULT 0,
+  "last_failure_at" timestamptz NOT NULL,
+  "locked_until" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "loginthrottle_kind_key" ON "login_throttles" ("kind", "key");
+CREATE INDEX "loginthrottle_last_failure_at" ON "login_throttles" ("last_failure_at");
+
+CREATE TABLE "api_tokens" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "name" character varying NOT NULL,
+  "token_hash" character varying NOT NULL,
+  "scopes" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "last_used_at" timestamptz NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "api_tokens_users_api_tokens" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "api_tokens_token_hash_key" ON "api_tokens" ("token_hash");
+
+CREATE TABLE "recovery_codes" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "code_hash" character varying NOT NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "recovery_codes_users_recovery_codes" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "recoverycode_user_id_code_hash" ON "recovery_codes" ("user_id", "code_hash");
+
+CREATE TABLE "password_resets" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "token_hash" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "password_resets_users_password_resets" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "password_resets_token_hash_key" ON "password_resets" ("token_hash");
+CREATE INDEX "passwordreset_expires_at" ON "password_resets" ("expires_at");
+
+CREATE TABLE "identities" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "provider" character varying NOT NULL,
+  "subject" character varying NOT NULL,
+  "email" character varying NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL,
+  "last_login_at" timestamptz NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "identities_users_identities" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "identity_provider_subject" ON "identities" ("provider", "subject");
+
+CREATE TABLE "invites" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "code_hash" character varying NOT NULL,
+  "max_uses" bigint NOT NULL,
+  "uses" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NULL,
+  "revoked_at" timestamptz NULL,
+  "role_id" bigint NOT NULL,
+  "created_by" bigint NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "invites_roles_invites" FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE NO ACTION,
+  CONSTRAINT "invites_users_invites" FOREIGN KEY ("created_by") REFERENCES "users" ("id") ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX "invites_code_hash_key" ON "invites" ("code_hash");
+
+ALTER TABLE "users"
+  ADD COLUMN "invite_id" bigint NULL,
+  ADD CONSTRAINT "users_invites_invitees" FOREIGN KEY ("invite_id") REFERENCES "invites" ("id") ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX "users_username_lower_key" ON "users" (lower("username"));
+
+CREATE TABLE "jobs" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "kind" character varying NOT NULL,
+  "post_id" bigint NULL,
+  "state" job_state NOT NULL DEFAULT 'Queued',
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "run_at" timestamptz NOT NULL,
+  "locked_until" timestamptz NULL,
+  "last_error" character varying NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "jobs_posts_jobs" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "job_state_run_at" ON "jobs" ("state", "run_at");
+
+CREATE TABLE "pages" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "post_id" bigint NOT NULL,
+  "number" bigint NOT NULL,
+  "file_ext" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "pages_posts_pages" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "page_post_id_number" ON "pages" ("post_id", "number");
+
+CREATE TABLE "reading_progresses" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "user_id" bigint NOT NULL,
+  "post_id" bigint NOT NULL,
+  "page" bigint NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "reading_progresses_users_reading_progress" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE,
+  CONSTRAINT "reading_progresses_posts_reading_progress" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "readingprogress_user_id_post_id" ON "reading_progresses" ("user_id", "post_id");
+
+CREATE TABLE "pools" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "name" character varying NOT NULL,
+  "description" text NOT NULL DEFAULT '',
+  "user_id" bigint NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "pools_users_pools" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE SET NULL
+);
+
+CREATE TABLE "pool_entries" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "pool_id" bigint NOT NULL,
+  "post_id" bigint NOT NULL,
+  "position" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "pool_entries_pools_entries" FOREIGN KEY ("pool_id") REFERENCES "pools" ("id") ON DELETE CASCADE,
+  CONSTRAINT "pool_entries_posts_pool_entries" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "poolentry_pool_id_post_id" ON "pool_entries" ("pool_id", "post_id");
+CREATE INDEX "poolentry_pool_id_position" ON "pool_entries" ("pool_id", "position");
+CREATE INDEX "poolentry_post_id" ON "pool_entries" ("post_id");
+
 CREATE TABLE "user_favourites" (
   "user_id" bigint NOT NULL,
   "post_id" bigint NOT NULL,
@@ -0,0 +1,13 @@
+CREATE TABLE "api_tokens" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "name" character varying NOT NULL,
+  "token_hash" character varying NOT NULL,
+  "scopes" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "last_used_at" timestamptz NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "api_tokens_users_api_tokens" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "api_tokens_token_hash_key" ON "api_tokens" ("token_hash");
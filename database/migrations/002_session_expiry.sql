@@ -0,0 +1,12 @@
+-- Existing sessions had no expiry, so they are given one more day before the reaper removes them.
+ALTER TABLE "sessions"
+  ADD COLUMN "created_at" timestamptz NOT NULL DEFAULT now(),
+  ADD COLUMN "last_seen_at" timestamptz NOT NULL DEFAULT now(),
+  ADD COLUMN "expires_at" timestamptz NOT NULL DEFAULT now() + interval '1 day';
+
+ALTER TABLE "sessions"
+  ALTER COLUMN "created_at" DROP DEFAULT,
+  ALTER COLUMN "last_seen_at" DROP DEFAULT,
+  ALTER COLUMN "expires_at" DROP DEFAULT;
+
+CREATE INDEX "session_expires_at" ON "sessions" ("expires_at");
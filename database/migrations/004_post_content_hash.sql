@@ -0,0 +1,12 @@
+ALTER TABLE "posts" ADD COLUMN "content_hash" character varying NULL;
+
+-- Filenames start with the sha256 of the content. Only the oldest post of each
+-- hash is given it, so duplicates stored before this change stay NULL and
+-- keep working without breaking the unique index.
+UPDATE "posts" AS p
+SET "content_hash" = left(p."filename", 64)
+WHERE p."id" = (
+  SELECT min(o."id") FROM "posts" AS o WHERE left(o."filename", 64) = left(p."filename", 64)
+);
+
+CREATE UNIQUE INDEX "posts_content_hash_key" ON "posts" ("content_hash");
@@ -0,0 +1,22 @@
+-- Posts uploaded before the job queue already have their thumbnails.
+CREATE TYPE processing_state AS ENUM ('Pending', 'Ready', 'Failed');
+CREATE TYPE job_state AS ENUM ('Queued', 'Running', 'Done', 'Failed');
+
+ALTER TABLE "posts" ADD COLUMN "processing_state" processing_state NOT NULL DEFAULT 'Ready';
+
+CREATE TABLE "jobs" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "kind" character varying NOT NULL,
+  "post_id" bigint NULL,
+  "state" job_state NOT NULL DEFAULT 'Queued',
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "run_at" timestamptz NOT NULL,
+  "locked_until" timestamptz NULL,
+  "last_error" character varying NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "jobs_posts_jobs" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "job_state_run_at" ON "jobs" ("state", "run_at");
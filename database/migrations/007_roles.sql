@@ -0,0 +1,26 @@
+-- Roles replace the is_admin flag. Admins keep every permission and everyone
+-- else becomes an uploader, which is what a plain user could do before.
+CREATE TABLE "roles" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "name" character varying NOT NULL,
+  "permissions" jsonb NOT NULL,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "roles_name_key" ON "roles" ("name");
+
+INSERT INTO "roles" ("name", "permissions") VALUES
+  ('viewer', '[]'),
+  ('uploader', '["post.create"]'),
+  ('moderator', '["post.create", "post.edit.any", "post.delete.any", "tag.edit"]'),
+  ('admin', '["post.create", "post.edit.any", "post.delete.any", "tag.edit", "user.manage"]');
+
+ALTER TABLE "users" ADD COLUMN "role_id" bigint NULL;
+
+UPDATE "users"
+SET "role_id" = (SELECT "id" FROM "roles" WHERE "name" = CASE WHEN "users"."is_admin" THEN 'admin' ELSE 'uploader' END);
+
+ALTER TABLE "users"
+  ALTER COLUMN "role_id" SET NOT NULL,
+  ADD CONSTRAINT "users_roles_users" FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE NO ACTION,
+  DROP COLUMN "is_admin";
@@ -0,0 +1,12 @@
+CREATE TABLE "login_throttles" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "kind" character varying NOT NULL,
+  "key" character varying NOT NULL,
+  "failures" bigint NOT NULL DEFAULT 0,
+  "last_failure_at" timestamptz NOT NULL,
+  "locked_until" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "loginthrottle_kind_key" ON "login_throttles" ("kind", "key");
+CREATE INDEX "loginthrottle_last_failure_at" ON "login_throttles" ("last_failure_at");
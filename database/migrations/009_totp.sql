@@ -0,0 +1,14 @@
+ALTER TABLE "users" ADD COLUMN "totp_secret" character varying NULL;
+ALTER TABLE "users" ADD COLUMN "totp_enabled" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "totp_required" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE "recovery_codes" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "code_hash" character varying NOT NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "recovery_codes_users_recovery_codes" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "recoverycode_user_id_code_hash" ON "recovery_codes" ("user_id", "code_hash");
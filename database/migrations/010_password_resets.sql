@@ -0,0 +1,15 @@
+ALTER TABLE "users" ADD COLUMN "email" character varying NULL;
+CREATE UNIQUE INDEX "users_email_key" ON "users" ("email");
+
+CREATE TABLE "password_resets" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "token_hash" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "password_resets_users_password_resets" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "password_resets_token_hash_key" ON "password_resets" ("token_hash");
+CREATE INDEX "passwordreset_expires_at" ON "password_resets" ("expires_at");
@@ -0,0 +1,13 @@
+CREATE TABLE "identities" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "provider" character varying NOT NULL,
+  "subject" character varying NOT NULL,
+  "email" character varying NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL,
+  "last_login_at" timestamptz NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "identities_users_identities" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "identity_provider_subject" ON "identities" ("provider", "subject");
@@ -0,0 +1,23 @@
+-- Invite codes for invite-only registration. Anyone who could upload may now also invite.
+UPDATE "roles" SET "permissions" = "permissions" || '["invite.create"]' WHERE "name" IN ('uploader', 'moderator', 'admin');
+
+CREATE TABLE "invites" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "code_hash" character varying NOT NULL,
+  "max_uses" bigint NOT NULL,
+  "uses" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NULL,
+  "revoked_at" timestamptz NULL,
+  "role_id" bigint NOT NULL,
+  "created_by" bigint NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "invites_roles_invites" FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE NO ACTION,
+  CONSTRAINT "invites_users_invites" FOREIGN KEY ("created_by") REFERENCES "users" ("id") ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX "invites_code_hash_key" ON "invites" ("code_hash");
+
+ALTER TABLE "users"
+  ADD COLUMN "invite_id" bigint NULL,
+  ADD CONSTRAINT "users_invites_invitees" FOREIGN KEY ("invite_id") REFERENCES "invites" ("id") ON DELETE SET NULL;
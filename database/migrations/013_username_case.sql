@@ -0,0 +1,2 @@
+-- Usernames are unique ignoring case. Rename any accounts that only differ by case before running this.
+CREATE UNIQUE INDEX "users_username_lower_key" ON "users" (lower("username"));
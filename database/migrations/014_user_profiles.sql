@@ -0,0 +1,6 @@
+-- Public profile fields. Accounts made before this get the time of the migration as their join date.
+ALTER TABLE "users" ADD COLUMN "display_name" character varying NULL;
+ALTER TABLE "users" ADD COLUMN "bio" text NULL;
+ALTER TABLE "users" ADD COLUMN "avatar" character varying NULL;
+ALTER TABLE "users" ADD COLUMN "favourites_public" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "created_at" timestamptz NOT NULL DEFAULT now();
@@ -0,0 +1,11 @@
+-- Audio posts used to skip processing, so they are queued again to get their tags read and a thumbnail made.
+ALTER TABLE "posts" ADD COLUMN "audio_title" character varying NULL;
+ALTER TABLE "posts" ADD COLUMN "artist" character varying NULL;
+ALTER TABLE "posts" ADD COLUMN "album" character varying NULL;
+ALTER TABLE "posts" ADD COLUMN "duration_ms" bigint NULL;
+ALTER TABLE "posts" ADD COLUMN "bitrate" bigint NULL;
+
+UPDATE "posts" SET "processing_state" = 'Pending' WHERE "media_type" = 'Audio';
+
+INSERT INTO "jobs" ("kind", "post_id", "run_at", "created_at", "updated_at")
+SELECT 'process_post', "id", now(), now(), now() FROM "posts" WHERE "media_type" = 'Audio';
@@ -0,0 +1,24 @@
+-- Book posts are stored as an archive with each page also kept on its own, listed here in reading order.
+CREATE TABLE "pages" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "post_id" bigint NOT NULL,
+  "number" bigint NOT NULL,
+  "file_ext" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "pages_posts_pages" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "page_post_id_number" ON "pages" ("post_id", "number");
+
+CREATE TABLE "reading_progresses" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "user_id" bigint NOT NULL,
+  "post_id" bigint NOT NULL,
+  "page" bigint NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "reading_progresses_users_reading_progress" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE,
+  CONSTRAINT "reading_progresses_posts_reading_progress" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "readingprogress_user_id_post_id" ON "reading_progresses" ("user_id", "post_id");
@@ -0,0 +1,25 @@
+-- Pools keep posts in an order of their own. A pool stays when its owner is deleted, for moderators to look after.
+CREATE TABLE "pools" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "name" character varying NOT NULL,
+  "description" text NOT NULL DEFAULT '',
+  "user_id" bigint NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "pools_users_pools" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE SET NULL
+);
+
+CREATE TABLE "pool_entries" (
+  "id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "pool_id" bigint NOT NULL,
+  "post_id" bigint NOT NULL,
+  "position" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "pool_entries_pools_entries" FOREIGN KEY ("pool_id") REFERENCES "pools" ("id") ON DELETE CASCADE,
+  CONSTRAINT "pool_entries_posts_pool_entries" FOREIGN KEY ("post_id") REFERENCES "posts" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "poolentry_pool_id_post_id" ON "pool_entries" ("pool_id", "post_id");
+CREATE INDEX "poolentry_pool_id_position" ON "pool_entries" ("pool_id", "position");
+CREATE INDEX "poolentry_post_id" ON "pool_entries" ("post_id");
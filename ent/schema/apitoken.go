@@ -0,0 +1,30 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+type APIToken struct {
+	ent.Schema
+}
+
+func (APIToken) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").NotEmpty(),
+		field.String("token_hash").NotEmpty().Unique().Sensitive(),
+		field.Strings("scopes"),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("last_used_at").Optional().Nillable(),
+		field.Int("user_id").Immutable(),
+	}
+}
+
+func (APIToken) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("owner", User.Type).Ref("api_tokens").Unique().Field("user_id").Required().Immutable(),
+	}
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// Identity links a user to the subject an external OpenID Connect provider knows them by.
+type Identity struct {
+	ent.Schema
+}
+
+func (Identity) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("provider").NotEmpty().Immutable(),
+		field.String("subject").NotEmpty().Immutable(),
+		field.Int("user_id").Immutable(),
+		field.String("email").Default(""),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("last_login_at").Optional().Nillable(),
+	}
+}
+
+func (Identity) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("owner", User.Type).Ref("identities").Unique().Field("user_id").Required().Immutable(),
+	}
+}
+
+func (Identity) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("provider", "subject").Unique(),
+	}
+}
@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+// Invite is a code that lets people register while registration is invite-only.
+type Invite struct {
+	ent.Schema
+}
+
+func (Invite) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("code_hash").NotEmpty().Unique().Sensitive().Immutable(),
+		field.Int("created_by").Optional().Nillable().Immutable(),
+		field.Int("role_id").Immutable(),
+		field.Int("max_uses").Positive().Immutable(),
+		field.Int("uses").Default(0),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("expires_at").Optional().Nillable().Immutable(),
+		field.Time("revoked_at").Optional().Nillable(),
+	}
+}
+
+func (Invite) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("creator", User.Type).Ref("invites").Unique().Field("created_by").Immutable(),
+		edge.From("role", Role.Type).Ref("invites").Unique().Field("role_id").Required().Immutable(),
+		edge.To("invitees", User.Type),
+	}
+}
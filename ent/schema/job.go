@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"goserv/internal/static/enum"
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type Job struct {
+	ent.Schema
+}
+
+func (Job) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("kind").NotEmpty().Immutable(),
+		field.Int("post_id").Optional().Immutable(),
+		field.Enum("state").
+			Values(enum.JobState("").Values()...).
+			Default(string(enum.JobQueued)).
+			SchemaType(map[string]string{
+				dialect.Postgres: "job_state",
+			}),
+		field.Int("attempts").Default(0),
+		field.Time("run_at").Default(time.Now),
+		field.Time("locked_until").Optional().Nillable(),
+		field.String("last_error").Default(""),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("updated_at").Default(time.Now).UpdateDefault(time.Now),
+	}
+}
+
+func (Job) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("post", Post.Type).Ref("jobs").Unique().Field("post_id").Immutable(),
+	}
+}
+
+func (Job) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("state", "run_at"),
+	}
+}
@@ -0,0 +1,29 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// LoginThrottle counts recent failed logins for one account or one client IP.
+type LoginThrottle struct {
+	ent.Schema
+}
+
+func (LoginThrottle) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("kind").NotEmpty().Immutable(),
+		field.String("key").NotEmpty().Immutable(),
+		field.Int("failures").Default(0),
+		field.Time("last_failure_at"),
+		field.Time("locked_until").Optional().Nillable(),
+	}
+}
+
+func (LoginThrottle) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("kind", "key").Unique(),
+		index.Fields("last_failure_at"),
+	}
+}
@@ -0,0 +1,33 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// Page is one image of a book post, in reading order starting from 1.
+type Page struct {
+	ent.Schema
+}
+
+func (Page) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("post_id").Immutable(),
+		field.Int("number").Positive().Immutable(),
+		field.String("file_ext").NotEmpty().Immutable(),
+	}
+}
+
+func (Page) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("post", Post.Type).Ref("pages").Unique().Field("post_id").Required().Immutable(),
+	}
+}
+
+func (Page) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("post_id", "number").Unique(),
+	}
+}
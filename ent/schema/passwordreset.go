@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// PasswordReset is an emailed, single-use token that lets a user set a new password.
+type PasswordReset struct {
+	ent.Schema
+}
+
+func (PasswordReset) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("token_hash").NotEmpty().Unique().Sensitive().Immutable(),
+		field.Int("user_id").Immutable(),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("expires_at").Immutable(),
+	}
+}
+
+func (PasswordReset) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("owner", User.Type).Ref("password_resets").Unique().Field("user_id").Required().Immutable(),
+	}
+}
+
+func (PasswordReset) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("expires_at"),
+	}
+}
@@ -0,0 +1,31 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+// Pool is a named collection of posts kept in the order its owner chose.
+type Pool struct {
+	ent.Schema
+}
+
+func (Pool) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").NotEmpty(),
+		field.Text("description").Default(""),
+		field.Int("user_id").Optional(),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("updated_at").Default(time.Now).UpdateDefault(time.Now),
+	}
+}
+
+func (Pool) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("owner", User.Type).Ref("pools").Unique().Field("user_id"),
+		edge.To("entries", PoolEntry.Type),
+	}
+}
@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// PoolEntry places a post in a pool. Positions only order the entries, so removing one leaves a gap.
+type PoolEntry struct {
+	ent.Schema
+}
+
+func (PoolEntry) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("pool_id").Immutable(),
+		field.Int("post_id").Immutable(),
+		field.Int("position"),
+	}
+}
+
+func (PoolEntry) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("pool", Pool.Type).Ref("entries").Unique().Field("pool_id").Required().Immutable(),
+		edge.From("post", Post.Type).Ref("pool_entries").Unique().Field("post_id").Required().Immutable(),
+	}
+}
+
+func (PoolEntry) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("pool_id", "post_id").Unique(),
+		index.Fields("pool_id", "position"),
+		index.Fields("post_id"),
+	}
+}
@@ -22,8 +22,21 @@ func (Post) Fields() []ent.Field {
 				dialect.Postgres: "media_type",
 			}),
 		field.String("filename").Unique(),
+		field.String("content_hash").Unique().Optional().Nillable().Immutable(),
 		field.String("file_ext"),
 		field.Int("user_owns").Optional(),
+		field.Enum("processing_state").
+			Values(enum.ProcessingState("").Values()...).
+			Default(string(enum.ProcessingReady)).
+			SchemaType(map[string]string{
+				dialect.Postgres: "processing_state",
+			}),
+		// read from the tags of audio posts while they are processed
+		field.String("audio_title").Optional(),
+		field.String("artist").Optional(),
+		field.String("album").Optional(),
+		field.Int64("duration_ms").Optional(),
+		field.Int("bitrate").Optional(),
 	}
 }
 
@@ -32,5 +45,9 @@ func (Post) Edges() []ent.Edge {
 		edge.From("owner", User.Type).Ref("owns").Unique().Field("user_owns"),
 		edge.From("favourited_by", User.Type).Ref("favourites"),
 		edge.To("tags", Tag.Type),
+		edge.To("jobs", Job.Type),
+		edge.To("pages", Page.Type),
+		edge.To("reading_progress", ReadingProgress.Type),
+		edge.To("pool_entries", PoolEntry.Type),
 	}
 }
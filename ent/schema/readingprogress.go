@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// ReadingProgress is the page of a book post a user was last reading.
+type ReadingProgress struct {
+	ent.Schema
+}
+
+func (ReadingProgress) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("user_id").Immutable(),
+		field.Int("post_id").Immutable(),
+		field.Int("page").Positive(),
+		field.Time("updated_at").Default(time.Now).UpdateDefault(time.Now),
+	}
+}
+
+func (ReadingProgress) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("user", User.Type).Ref("reading_progress").Unique().Field("user_id").Required().Immutable(),
+		edge.From("post", Post.Type).Ref("reading_progress").Unique().Field("post_id").Required().Immutable(),
+	}
+}
+
+func (ReadingProgress) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "post_id").Unique(),
+	}
+}
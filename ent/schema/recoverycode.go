@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// RecoveryCode is a one-time code that stands in for a TOTP code when the authenticator is lost.
+type RecoveryCode struct {
+	ent.Schema
+}
+
+func (RecoveryCode) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("code_hash").NotEmpty().Sensitive().Immutable(),
+		field.Int("user_id").Immutable(),
+	}
+}
+
+func (RecoveryCode) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("owner", User.Type).Ref("recovery_codes").Unique().Field("user_id").Required().Immutable(),
+	}
+}
+
+func (RecoveryCode) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "code_hash").Unique(),
+	}
+}
@@ -1,9 +1,12 @@
 package schema
 
 import (
+	"time"
+
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
 )
 
 type Session struct {
@@ -14,6 +17,11 @@ func (Session) Fields() []ent.Field {
 	return []ent.Field{
 		field.String("id").NotEmpty().Immutable(),
 		field.Int("user_id").Immutable(),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("last_seen_at").Default(time.Now),
+		field.Time("expires_at"),
+		field.String("user_agent").Default(""),
+		field.String("ip").Default(""),
 	}
 }
 
@@ -23,6 +31,12 @@ func (Session) Edges() []ent.Edge {
 	}
 }
 
+func (Session) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("expires_at"),
+	}
+}
+
 func (Session) ID() []ent.Field {
 	return []ent.Field{
 		field.String("id").NotEmpty().Immutable(),
@@ -1,6 +1,8 @@
 package schema
 
 import (
+	"time"
+
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
@@ -14,7 +16,19 @@ func (User) Fields() []ent.Field {
 	return []ent.Field{
 		field.String("username").NotEmpty().Unique().Immutable(),
 		field.String("pass_hash").NotEmpty(),
-		field.Bool("is_admin").Default(false),
+		field.String("email").Optional().Nillable().Unique(),
+		field.Int("role_id"),
+		field.Bool("disabled").Default(false),
+		field.String("totp_secret").Optional().Sensitive(),
+		field.Bool("totp_enabled").Default(false),
+		field.Bool("totp_required").Default(false),
+		field.Int64("totp_last_step").Default(0),
+		field.Int("invite_id").Optional().Nillable().Immutable(),
+		field.String("display_name").Optional(),
+		field.Text("bio").Optional(),
+		field.String("avatar").Optional(),
+		field.Bool("favourites_public").Default(false),
+		field.Time("created_at").Default(time.Now).Immutable(),
 	}
 }
 
@@ -23,5 +37,14 @@ func (User) Edges() []ent.Edge {
 		edge.To("owns", Post.Type),
 		edge.To("favourites", Post.Type),
 		edge.To("sessions", Session.Type),
+		edge.To("api_tokens", APIToken.Type),
+		edge.To("recovery_codes", RecoveryCode.Type),
+		edge.To("password_resets", PasswordReset.Type),
+		edge.To("identities", Identity.Type),
+		edge.To("invites", Invite.Type),
+		edge.To("reading_progress", ReadingProgress.Type),
+		edge.To("pools", Pool.Type),
+		edge.From("invite", Invite.Type).Ref("invitees").Unique().Field("invite_id").Immutable(),
+		edge.From("role", Role.Type).Ref("users").Unique().Field("role_id").Required(),
 	}
 }
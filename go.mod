@@ -9,12 +9,13 @@ require golang.org/x/crypto v0.40.0
 require (
 	entgo.io/ent v0.14.5
 	github.com/go-chi/chi/v5 v5.2.2
+	github.com/stretchr/testify v1.11.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.11.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
@@ -0,0 +1,276 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goserv/internal/domain/posts"
+	pRepo "goserv/internal/domain/posts/repository"
+	"goserv/internal/domain/posts/search"
+	pService "goserv/internal/domain/posts/service"
+	"goserv/internal/domain/sessions"
+	sRepo "goserv/internal/domain/sessions/repository"
+	sService "goserv/internal/domain/sessions/service"
+	"goserv/internal/domain/tags"
+	tRepo "goserv/internal/domain/tags/repository"
+	tService "goserv/internal/domain/tags/service"
+	"goserv/internal/domain/tokens"
+	tokRepo "goserv/internal/domain/tokens/repository"
+	"goserv/internal/domain/users"
+	uRepo "goserv/internal/domain/users/repository"
+	uService "goserv/internal/domain/users/service"
+	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testPolicy = sessions.Policy{Absolute: 24 * time.Hour, Idle: time.Hour}
+
+func newTestAPI(postRepo *pRepo.PostMock, userRepo *uRepo.UserMock) http.Handler {
+	sessionRepo := &sRepo.SessionMock{
+		GetSessionFunc: func(ctx context.Context, sessionID string) (*sessions.Session, error) {
+			if sessionID == "valid" {
+				now := time.Now()
+				return &sessions.Session{ID: sessionID, UserID: 1, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}, nil
+			}
+			return nil, errors.New("no session")
+		},
+	}
+	tokenRepo := &tokRepo.TokenMock{
+		GetByHashFunc: func(ctx context.Context, tokenHash string) (*tokens.Token, error) {
+			if tokenHash == tokens.Hash(tokens.Prefix+"readonly") {
+				return &tokens.Token{ID: 3, UserID: 1, Scopes: []enum.Scope{enum.ScopePostsRead}}, nil
+			}
+			return nil, myErrors.ErrNotFound
+		},
+		TouchTokenFunc: func(ctx context.Context, tokenID int, usedAt time.Time) error {
+			return nil
+		},
+	}
+	throttleRepo := &sRepo.ThrottleMock{
+		GetThrottleFunc: func(ctx context.Context, kind string, key string) (*sessions.Throttle, error) {
+			return nil, myErrors.ErrNotFound
+		},
+	}
+	tagRepo := &tRepo.TagMock{
+		ListGeneralTagsFunc: func(ctx context.Context) ([]tags.Tag, error) {
+			return []tags.Tag{{ID: 1, Name: "beach", Type: enum.TagGeneral}}, nil
+		},
+	}
+
+	api := NewAPI(
+		pService.NewPostService(postRepo, nil, nil),
+		tService.NewTagService(tagRepo),
+		uService.NewUserService(userRepo, sessionRepo, nil),
+		sService.NewSessionService(sessionRepo, userRepo, throttleRepo, testPolicy, sessions.LoginLimits{}, []byte("secret")),
+	)
+	return api.Routes(middleware.AuthCheckMiddleware(sessionRepo, tokenRepo, testPolicy))
+}
+
+func serve(handler http.Handler, method string, target string, loggedIn bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if loggedIn {
+		req.AddCookie(sessions.NewCookie("valid", time.Time{}))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI_ListPosts(t *testing.T) {
+	postRepo := &pRepo.PostMock{
+		ListPostsFunc: func(ctx context.Context, query search.Node, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+			assert.Equal(t, search.Tag{Name: "beach"}, query)
+			assert.Equal(t, 1, page.Limit)
+			return pagination.Page[posts.Post]{
+				Items: []posts.Post{{ID: 2, Title: "sea", MediaType: enum.MediaImage, Filename: "abc", FileExt: ".png"}},
+				Next:  "next-cursor",
+			}, nil
+		},
+	}
+
+	rec := serve(newTestAPI(postRepo, &uRepo.UserMock{}), http.MethodGet, "/posts?q=beach&limit=1", false)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body struct {
+		Data []PostDTO `json:"data"`
+		Next string    `json:"next"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "next-cursor", body.Next)
+	assert.Equal(t, []PostDTO{{
+		ID:           2,
+		Title:        "sea",
+		MediaType:    "Image",
+		ContentURL:   "/assets/content/abc.png",
+		ThumbnailURL: "/assets/thumbnails/abc.jpg",
+	}}, body.Data)
+}
+
+func TestAPI_Errors(t *testing.T) {
+	type want struct {
+		status int
+		code   string
+	}
+	type test struct {
+		name     string
+		method   string
+		target   string
+		loggedIn bool
+		want     want
+	}
+
+	postRepo := &pRepo.PostMock{
+		GetPostFunc: func(ctx context.Context, postID int) (*posts.Post, error) {
+			if postID == 5 {
+				return &posts.Post{ID: 5, OwnerID: 2, Filename: "abc", FileExt: ".png"}, nil
+			}
+			return nil, myErrors.ErrNotFound
+		},
+		ListPostsFunc: func(ctx context.Context, query search.Node, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+			return pagination.Page[posts.Post]{}, nil
+		},
+	}
+	userRepo := &uRepo.UserMock{
+		GetPermissionsFunc: func(ctx context.Context, userID int) ([]enum.Permission, error) {
+			return []enum.Permission{enum.PermPostCreate}, nil
+		},
+	}
+
+	tests := []test{
+		{
+			name:   "bad search query",
+			method: http.MethodGet,
+			target: "/posts?q=(beach",
+			want:   want{status: http.StatusBadRequest, code: codeBadRequest},
+		},
+		{
+			name:   "post not found",
+			method: http.MethodGet,
+			target: "/posts/9",
+			want:   want{status: http.StatusNotFound, code: codeNotFound},
+		},
+		{
+			name:   "invalid post id",
+			method: http.MethodGet,
+			target: "/posts/abc",
+			want:   want{status: http.StatusBadRequest, code: codeBadRequest},
+		},
+		{
+			name:   "delete needs login",
+			method: http.MethodDelete,
+			target: "/posts/5",
+			want:   want{status: http.StatusUnauthorized, code: codeUnauthorized},
+		},
+		{
+			name:     "delete someone elses post",
+			method:   http.MethodDelete,
+			target:   "/posts/5",
+			loggedIn: true,
+			want:     want{status: http.StatusForbidden, code: codeForbidden},
+		},
+		{
+			name:   "unknown endpoint",
+			method: http.MethodGet,
+			target: "/nothing",
+			want:   want{status: http.StatusNotFound, code: codeNotFound},
+		},
+	}
+
+	handler := newTestAPI(postRepo, userRepo)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := serve(handler, test.method, test.target, test.loggedIn)
+			assert.Equal(t, test.want.status, rec.Code)
+
+			var body errorEnvelope
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			assert.Equal(t, test.want.code, body.Error.Code)
+		})
+	}
+}
+
+func TestAPI_FavouritePost(t *testing.T) {
+	favourited := 0
+	postRepo := &pRepo.PostMock{
+		GetPostFunc: func(ctx context.Context, postID int) (*posts.Post, error) {
+			return &posts.Post{ID: postID}, nil
+		},
+		FavouritePostFunc: func(ctx context.Context, postID int, userID int) error {
+			favourited = postID
+			assert.Equal(t, 1, userID)
+			return nil
+		},
+	}
+
+	rec := serve(newTestAPI(postRepo, &uRepo.UserMock{}), http.MethodPut, "/posts/7/favourite", true)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 7, favourited)
+}
+
+func TestAPI_TokenScopes(t *testing.T) {
+	postRepo := &pRepo.PostMock{
+		GetPostFunc: func(ctx context.Context, postID int) (*posts.Post, error) {
+			return &posts.Post{ID: postID}, nil
+		},
+		GetPostWithFavouriteStatusFunc: func(ctx context.Context, postID int, userID int) (*posts.Post, bool, error) {
+			assert.Equal(t, 1, userID)
+			return &posts.Post{ID: postID}, false, nil
+		},
+	}
+	handler := newTestAPI(postRepo, &uRepo.UserMock{})
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/7", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.Prefix+"readonly")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/posts/7/favourite", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.Prefix+"readonly")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestAPI_ViewerCannotUpload(t *testing.T) {
+	userRepo := &uRepo.UserMock{
+		GetPermissionsFunc: func(ctx context.Context, userID int) ([]enum.Permission, error) {
+			return []enum.Permission{}, nil
+		},
+	}
+
+	rec := serve(newTestAPI(&pRepo.PostMock{}, userRepo), http.MethodPost, "/posts", true)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	var body errorEnvelope
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, codeForbidden, body.Error.Code)
+}
+
+func TestAPI_LoginNeedsSecondFactor(t *testing.T) {
+	userRepo := &uRepo.UserMock{
+		CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+			return &users.User{ID: 1, Username: username, TOTPEnabled: true}, true, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(`{"username": "alice", "password": "password"}`))
+	rec := httptest.NewRecorder()
+	newTestAPI(&pRepo.PostMock{}, userRepo).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, rec.Result().Cookies())
+
+	var body errorEnvelope
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, codeSecondFactor, body.Error.Code)
+}
@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"goserv/internal/domain/posts"
+	"goserv/internal/domain/tags"
+	"goserv/internal/domain/users"
+	"goserv/internal/static/constant"
+	"goserv/internal/static/enum"
+)
+
+type PostDTO struct {
+	ID              int       `json:"id"`
+	Title           string    `json:"title"`
+	MediaType       string    `json:"media_type"`
+	OwnerID         int       `json:"owner_id,omitempty"`
+	ContentURL      string    `json:"content_url"`
+	ThumbnailURL    string    `json:"thumbnail_url,omitempty"`
+	ProcessingState string    `json:"processing_state,omitempty"`
+	Audio           *AudioDTO `json:"audio,omitempty"`
+	Tags            []TagDTO  `json:"tags,omitempty"`
+	People          []TagDTO  `json:"people,omitempty"`
+	IsFavourite     *bool     `json:"is_favourite,omitempty"`
+}
+
+// AudioDTO holds an audio post's tags, with DurationMS and Bitrate (bits per second) left out when unknown.
+type AudioDTO struct {
+	Title      string `json:"title,omitempty"`
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Bitrate    int    `json:"bitrate,omitempty"`
+}
+
+type TagDTO struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type UserDTO struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Code is a TOTP or recovery code, needed by accounts with two-factor login
+	Code string `json:"code,omitempty"`
+}
+
+func toPostDTO(post *posts.Post) PostDTO {
+	dto := PostDTO{
+		ID:              post.ID,
+		Title:           post.Title,
+		MediaType:       string(post.MediaType),
+		OwnerID:         post.OwnerID,
+		ContentURL:      "/assets/content/" + post.Filename + post.FileExt,
+		ProcessingState: string(post.ProcessingState),
+	}
+	if post.Ready() {
+		dto.ThumbnailURL = "/assets/thumbnails/" + post.Filename + constant.ThumbnailExt
+	}
+	if post.MediaType == enum.MediaAudio {
+		dto.Audio = &AudioDTO{
+			Title:      post.Audio.Title,
+			Artist:     post.Audio.Artist,
+			Album:      post.Audio.Album,
+			DurationMS: post.Audio.Duration.Milliseconds(),
+			Bitrate:    post.Audio.Bitrate,
+		}
+	}
+
+	for i := range post.Tags {
+		if post.Tags[i].Type == enum.TagPeople {
+			dto.People = append(dto.People, toTagDTO(post.Tags[i]))
+			continue
+		}
+		dto.Tags = append(dto.Tags, toTagDTO(post.Tags[i]))
+	}
+	return dto
+}
+
+func toPostDTOs(posts []posts.Post) []PostDTO {
+	dtos := make([]PostDTO, len(posts))
+	for i := range posts {
+		dtos[i] = toPostDTO(&posts[i])
+	}
+	return dtos
+}
+
+func toTagDTO(tag tags.Tag) TagDTO {
+	return TagDTO{ID: tag.ID, Name: tag.Name, Type: string(tag.Type)}
+}
+
+func toTagDTOs(tags []tags.Tag) []TagDTO {
+	dtos := make([]TagDTO, len(tags))
+	for i := range tags {
+		dtos[i] = toTagDTO(tags[i])
+	}
+	return dtos
+}
+
+func toUserDTO(user *users.User) UserDTO {
+	return UserDTO{ID: user.ID, Username: user.Username}
+}
@@ -0,0 +1,218 @@
+package v1
+
+import (
+	"errors"
+	"goserv/internal/domain/posts"
+	"goserv/internal/domain/posts/search"
+	"goserv/internal/domain/tags"
+	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"goserv/internal/utils/validate"
+	"net/http"
+	"strconv"
+)
+
+const maxUploadMemory = 10 << 20
+
+func (a *API) listPosts(w http.ResponseWriter, r *http.Request) {
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	page, err := a.postSvc.ListPosts(r.Context(), r.URL.Query().Get("q"), pageReq)
+	if err != nil {
+		if errors.Is(err, search.ErrSyntax) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error listing posts")
+		return
+	}
+
+	writeList(w, toPostDTOs(page.Items), page.Next, page.Prev)
+}
+
+func (a *API) getPost(w http.ResponseWriter, r *http.Request) {
+	postID, ok := postIDParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid post id")
+		return
+	}
+
+	userID, _ := middleware.GetUserID(r)
+	post, isFav, err := a.postSvc.GetPostWithFavouriteStatus(r.Context(), postID, userID)
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	dto := toPostDTO(post)
+	if userID != 0 {
+		dto.IsFavourite = &isFav
+	}
+	writeData(w, http.StatusOK, dto)
+}
+
+func (a *API) createPost(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+
+	canCreate, err := a.userSvc.HasPermission(r.Context(), userID, enum.PermPostCreate)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error checking permissions")
+		return
+	}
+	if !canCreate {
+		writeError(w, http.StatusForbidden, codeForbidden, "Your role cannot upload posts")
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid form data")
+		return
+	}
+
+	title := r.FormValue("title")
+	mediaType := enum.MediaType(r.FormValue("media"))
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Missing uploaded file")
+		return
+	}
+	defer file.Close()
+
+	if !validate.IsValidFileType(header.Filename, mediaType) {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid file extension for media type")
+		return
+	}
+
+	var requested []tags.Tag
+	for _, name := range r.MultipartForm.Value["tags"] {
+		requested = append(requested, tags.Tag{Name: name, Type: enum.TagGeneral})
+	}
+	for _, name := range r.MultipartForm.Value["people"] {
+		requested = append(requested, tags.Tag{Name: name, Type: enum.TagPeople})
+	}
+
+	postTags, err := a.tagSvc.ResolveTags(r.Context(), requested)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error saving tags")
+		return
+	}
+
+	post := &posts.Post{Title: title, MediaType: mediaType, Filename: header.Filename, Tags: postTags}
+	postID, err := a.postSvc.AddPost(r.Context(), post, file, userID)
+	if err != nil {
+		var dupErr *posts.DuplicateError
+		if errors.As(err, &dupErr) {
+			writeErrorDetails(w, http.StatusConflict, codeConflict, "This file has already been uploaded", map[string]any{
+				"post_id": dupErr.PostID,
+				"url":     "/api/v1/posts/" + strconv.Itoa(dupErr.PostID),
+			})
+			return
+		}
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error adding post")
+		return
+	}
+
+	created, err := a.postSvc.GetPost(r.Context(), postID)
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+	writeData(w, http.StatusCreated, toPostDTO(created))
+}
+
+func (a *API) deletePost(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+	postID, ok := postIDParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid post id")
+		return
+	}
+
+	post, err := a.postSvc.GetPost(r.Context(), postID)
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	if post.OwnerID != userID {
+		canDelete, err := a.userSvc.HasPermission(r.Context(), userID, enum.PermPostDeleteAny)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, "Error checking permissions")
+			return
+		}
+		if !canDelete {
+			writeError(w, http.StatusForbidden, codeForbidden, "Only the owner or a moderator can delete this post")
+			return
+		}
+	}
+
+	if err := a.postSvc.DeletePost(r.Context(), post); err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error deleting post")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) favouritePost(w http.ResponseWriter, r *http.Request) {
+	a.setFavourite(w, r, true)
+}
+
+func (a *API) unfavouritePost(w http.ResponseWriter, r *http.Request) {
+	a.setFavourite(w, r, false)
+}
+
+func (a *API) setFavourite(w http.ResponseWriter, r *http.Request, favourite bool) {
+	userID, _ := middleware.GetUserID(r)
+	postID, ok := postIDParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid post id")
+		return
+	}
+
+	if _, err := a.postSvc.GetPost(r.Context(), postID); err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	var err error
+	if favourite {
+		err = a.postSvc.FavouritePost(r.Context(), postID, userID)
+	} else {
+		err = a.postSvc.UnfavouritePost(r.Context(), postID, userID)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error updating favourite")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) listFavourites(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	page, err := a.postSvc.ListUserFavs(r.Context(), userID, pageReq)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error listing favourites")
+		return
+	}
+	writeList(w, toPostDTOs(page.Items), page.Next, page.Prev)
+}
+
+func writePostError(w http.ResponseWriter, err error) {
+	if errors.Is(err, myErrors.ErrNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Post not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, codeInternal, "Error getting post")
+}
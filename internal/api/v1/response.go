@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const (
+	codeBadRequest   = "bad_request"
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+	codeNotFound     = "not_found"
+	codeConflict     = "conflict"
+	codeRateLimited  = "rate_limited"
+	codeSecondFactor = "second_factor_required"
+	codeInternal     = "internal_error"
+)
+
+type dataEnvelope struct {
+	Data any `json:"data"`
+}
+
+type listEnvelope struct {
+	Data any    `json:"data"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+type errorEnvelope struct {
+	Error ErrorDTO `json:"error"`
+}
+
+type ErrorDTO struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to encode api response: %v\n", err)
+	}
+}
+
+func writeData(w http.ResponseWriter, status int, data any) {
+	writeJSON(w, status, dataEnvelope{Data: data})
+}
+
+func writeList(w http.ResponseWriter, data any, next string, prev string) {
+	writeJSON(w, http.StatusOK, listEnvelope{Data: data, Next: next, Prev: prev})
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, errorEnvelope{Error: ErrorDTO{Code: code, Message: message}})
+}
+
+func writeErrorDetails(w http.ResponseWriter, status int, code string, message string, details map[string]any) {
+	writeJSON(w, status, errorEnvelope{Error: ErrorDTO{Code: code, Message: message, Details: details}})
+}
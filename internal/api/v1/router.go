@@ -0,0 +1,97 @@
+package v1
+
+import (
+	pService "goserv/internal/domain/posts/service"
+	sService "goserv/internal/domain/sessions/service"
+	tService "goserv/internal/domain/tags/service"
+	uService "goserv/internal/domain/users/service"
+	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type API struct {
+	postSvc    *pService.PostService
+	tagSvc     *tService.TagService
+	userSvc    *uService.UserService
+	sessionSvc *sService.SessionService
+}
+
+func NewAPI(
+	postSvc *pService.PostService,
+	tagSvc *tService.TagService,
+	userSvc *uService.UserService,
+	sessionSvc *sService.SessionService,
+) *API {
+	return &API{
+		postSvc:    postSvc,
+		tagSvc:     tagSvc,
+		userSvc:    userSvc,
+		sessionSvc: sessionSvc,
+	}
+}
+
+func (a *API) Routes(checkMiddleware func(http.Handler) http.Handler) http.Handler {
+	r := chi.NewRouter()
+	r.Use(checkMiddleware)
+
+	r.With(requireScope(enum.ScopePostsRead)).Get("/posts", a.listPosts)
+	r.With(requireScope(enum.ScopePostsRead)).Get("/posts/{id}", a.getPost)
+	r.With(requireScope(enum.ScopePostsRead)).Get("/tags", a.listTags)
+	r.With(requireScope(enum.ScopePostsRead)).Get("/people", a.listPeople)
+	r.Post("/sessions", a.createSession)
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireUser)
+
+		r.With(requireScope(enum.ScopePostsWrite)).Post("/posts", a.createPost)
+		r.With(requireScope(enum.ScopePostsWrite)).Delete("/posts/{id}", a.deletePost)
+		r.With(requireScope(enum.ScopeFavouritesWrite)).Put("/posts/{id}/favourite", a.favouritePost)
+		r.With(requireScope(enum.ScopeFavouritesWrite)).Delete("/posts/{id}/favourite", a.unfavouritePost)
+		r.With(requireScope(enum.ScopeFavouritesRead)).Get("/favourites", a.listFavourites)
+		r.Get("/sessions/current", a.currentSession)
+		r.Delete("/sessions/current", a.deleteSession)
+	})
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Endpoint not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, codeBadRequest, "Method not allowed")
+	})
+	return r
+}
+
+func requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := middleware.GetUserID(r)
+		if !ok || userID == 0 {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requireScope(scope enum.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !middleware.HasScope(r, scope) {
+				writeError(w, http.StatusForbidden, codeForbidden, "Token is missing scope "+string(scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func postIDParam(r *http.Request) (int, bool) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || postID <= 0 {
+		return 0, false
+	}
+	return postID, true
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"goserv/internal/domain/sessions"
+	sService "goserv/internal/domain/sessions/service"
+	"goserv/internal/middleware"
+	"net/http"
+	"strconv"
+)
+
+func (a *API) createSession(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid login body")
+		return
+	}
+
+	client := sessions.ClientFromRequest(r)
+	session, err := a.sessionSvc.Login(r.Context(), req.Username, req.Password, client)
+
+	var secondFactor *sessions.SecondFactorError
+	if errors.As(err, &secondFactor) {
+		if secondFactor.Pending.Enroll {
+			writeError(w, http.StatusForbidden, codeForbidden, "Two-factor login must be set up by logging in on the website first")
+			return
+		}
+		if req.Code == "" {
+			writeError(w, http.StatusUnauthorized, codeSecondFactor, "A two-factor code is required")
+			return
+		}
+		session, err = a.sessionSvc.LoginSecondFactor(r.Context(), &secondFactor.Pending, req.Code, client)
+	}
+	if err != nil {
+		var throttled *sessions.ThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, codeRateLimited, throttled.Error())
+			return
+		}
+		if errors.Is(err, sService.ErrAccountDisabled) {
+			writeError(w, http.StatusForbidden, codeForbidden, "This account has been disabled")
+			return
+		}
+		if errors.Is(err, sService.ErrInvalidCode) {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid two-factor code")
+			return
+		}
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid credentials")
+		return
+	}
+
+	user, err := a.userSvc.GetByUsername(r.Context(), req.Username)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error reading user")
+		return
+	}
+
+	http.SetCookie(w, sessions.NewCookie(session.ID, session.ExpiresAt))
+	writeData(w, http.StatusCreated, toUserDTO(user))
+}
+
+func (a *API) currentSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+	user, err := a.userSvc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error reading user")
+		return
+	}
+	writeData(w, http.StatusOK, toUserDTO(user))
+}
+
+func (a *API) deleteSession(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessions.CookieName)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "No session cookie")
+		return
+	}
+
+	if err := a.sessionSvc.Logout(r.Context(), cookie.Value); err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error logging out")
+		return
+	}
+
+	cookie.MaxAge = -1
+	cookie.Path = "/"
+	http.SetCookie(w, cookie)
+	w.WriteHeader(http.StatusNoContent)
+}
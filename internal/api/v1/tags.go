@@ -0,0 +1,21 @@
+package v1
+
+import "net/http"
+
+func (a *API) listTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := a.tagSvc.ListGeneralTags(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error listing tags")
+		return
+	}
+	writeData(w, http.StatusOK, toTagDTOs(tags))
+}
+
+func (a *API) listPeople(w http.ResponseWriter, r *http.Request) {
+	people, err := a.tagSvc.ListPeopleTags(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Error listing people")
+		return
+	}
+	writeData(w, http.StatusOK, toTagDTOs(people))
+}
@@ -0,0 +1,388 @@
+package handler
+
+import (
+	"errors"
+	"goserv/internal/domain/posts"
+	"goserv/internal/domain/posts/search"
+	pService "goserv/internal/domain/posts/service"
+	sService "goserv/internal/domain/sessions/service"
+	"goserv/internal/domain/tags"
+	tService "goserv/internal/domain/tags/service"
+	"goserv/internal/domain/users"
+	uService "goserv/internal/domain/users/service"
+	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminHandler serves the /admin console. Each route is expected to sit behind middleware.RequirePermission.
+type AdminHandler struct {
+	userSvc    *uService.UserService
+	regSvc     *uService.RegistrationService
+	sessionSvc *sService.SessionService
+	tagSvc     *tService.TagService
+	postSvc    *pService.PostService
+	tmpl       *template.Template
+}
+
+func NewAdminHandler(
+	userSvc *uService.UserService,
+	regSvc *uService.RegistrationService,
+	sessionSvc *sService.SessionService,
+	tagSvc *tService.TagService,
+	postSvc *pService.PostService,
+	tmpl *template.Template,
+) *AdminHandler {
+	return &AdminHandler{userSvc: userSvc, regSvc: regSvc, sessionSvc: sessionSvc, tagSvc: tagSvc, postSvc: postSvc, tmpl: tmpl}
+}
+
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+
+	allUsers, err := h.userSvc.ListUsers(r.Context())
+	if err != nil {
+		http.Error(w, "Error listing users", http.StatusInternalServerError)
+		return
+	}
+
+	roles, err := h.userSvc.ListRoles(r.Context())
+	if err != nil {
+		http.Error(w, "Error listing roles", http.StatusInternalServerError)
+		return
+	}
+
+	locked, err := h.sessionSvc.ListLockedAccounts(r.Context())
+	if err != nil {
+		http.Error(w, "Error listing locked accounts", http.StatusInternalServerError)
+		return
+	}
+	lockedUntil := make(map[string]time.Time, len(locked))
+	for i := range locked {
+		lockedUntil[locked[i].Key] = *locked[i].LockedUntil
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "admin_users.html", struct {
+		Users       []users.User
+		Roles       []users.Role
+		LockedUntil map[string]time.Time
+		CurrentID   int
+		CSRF        string
+	}{
+		Users:       allUsers,
+		Roles:       roles,
+		LockedUntil: lockedUntil,
+		CurrentID:   userID,
+		CSRF:        middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := h.userIDs(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if err := h.userSvc.SetRole(r.Context(), actorID, targetID, role); err != nil {
+		writeUserError(w, err, "Error updating user role")
+		return
+	}
+	log.Printf("Admin %d gave user %d the %s role\n", actorID, targetID, role)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) SetDisabled(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := h.userIDs(w, r)
+	if !ok {
+		return
+	}
+
+	disabled := r.FormValue("disabled") == "true"
+	if err := h.userSvc.SetDisabled(r.Context(), actorID, targetID, disabled); err != nil {
+		writeUserError(w, err, "Error updating user")
+		return
+	}
+	log.Printf("Admin %d set disabled=%t for user %d\n", actorID, disabled, targetID)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// RequireTwoFactor makes a user log in with an authenticator, setting one up on their next login if needed.
+func (h *AdminHandler) RequireTwoFactor(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := h.userIDs(w, r)
+	if !ok {
+		return
+	}
+
+	required := r.FormValue("required") == "true"
+	if err := h.userSvc.SetTOTPRequired(r.Context(), actorID, targetID, required); err != nil {
+		writeUserError(w, err, "Error updating user")
+		return
+	}
+	log.Printf("Admin %d set totp_required=%t for user %d\n", actorID, required, targetID)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := h.userIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userSvc.DeleteUser(r.Context(), actorID, targetID); err != nil {
+		writeUserError(w, err, "Error deleting user")
+		return
+	}
+	log.Printf("Admin %d deleted user %d\n", actorID, targetID)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// UnlockUser lifts a lockout left by failed logins.
+func (h *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := middleware.GetUserID(r)
+	username := r.FormValue("username")
+	if username == "" {
+		http.Error(w, "Missing username", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.sessionSvc.UnlockAccount(r.Context(), username); err != nil {
+		http.Error(w, "Error unlocking user", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Admin %d unlocked logins for %q\n", actorID, username)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// ListInvites shows every invite, whoever made it.
+func (h *AdminHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	invites, err := h.regSvc.ListInvites(r.Context())
+	if err != nil {
+		http.Error(w, "Error listing invites", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "admin_invites.html", struct {
+		Invites []users.Invite
+		Mode    string
+		Now     time.Time
+		CSRF    string
+	}{
+		Invites: invites,
+		Mode:    h.regSvc.Mode(),
+		Now:     time.Now(),
+		CSRF:    middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := middleware.GetUserID(r)
+	inviteID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid invite id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.regSvc.RevokeInvite(r.Context(), actorID, inviteID); err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.Error(w, "Invite not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error revoking invite", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Admin %d revoked invite %d\n", actorID, inviteID)
+	http.Redirect(w, r, "/admin/invites", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) userIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	actorID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	targetID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return actorID, targetID, true
+}
+
+func writeUserError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, uService.ErrOwnAccount):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, myErrors.ErrNotFound):
+		http.Error(w, "User or role not found", http.StatusNotFound)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	allTags, err := h.tagSvc.ListTags(r.Context())
+	if err != nil {
+		http.Error(w, "Error listing tags", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "admin_tags.html", struct {
+		Tags  []tags.Tag
+		Types []string
+		CSRF  string
+	}{
+		Tags:  allTags,
+		Types: enum.TagType("").Values(),
+		CSRF:  middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminHandler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	tagID, ok := tagIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tagSvc.RenameTag(r.Context(), tagID, r.FormValue("name")); err != nil {
+		writeTagError(w, err, "Error renaming tag")
+		return
+	}
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) RetypeTag(w http.ResponseWriter, r *http.Request) {
+	tagID, ok := tagIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tagSvc.RetypeTag(r.Context(), tagID, enum.TagType(r.FormValue("type"))); err != nil {
+		writeTagError(w, err, "Error changing tag type")
+		return
+	}
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	tagID, ok := tagIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tagSvc.DeleteTag(r.Context(), tagID); err != nil {
+		writeTagError(w, err, "Error deleting tag")
+		return
+	}
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
+
+func tagIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	tagID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid tag id", http.StatusBadRequest)
+		return 0, false
+	}
+	return tagID, true
+}
+
+func writeTagError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, tService.ErrInvalidName), errors.Is(err, tService.ErrInvalidType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, tags.ErrTagExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, myErrors.ErrNotFound):
+		http.Error(w, "Tag not found", http.StatusNotFound)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid page request", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	page, err := h.postSvc.ListPosts(r.Context(), query, pageReq)
+	if err != nil {
+		if errors.Is(err, search.ErrSyntax) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error listing posts", http.StatusInternalServerError)
+		return
+	}
+
+	allUsers, err := h.userSvc.ListUsers(r.Context())
+	if err != nil {
+		http.Error(w, "Error listing users", http.StatusInternalServerError)
+		return
+	}
+	owners := make(map[int]string, len(allUsers))
+	for i := range allUsers {
+		owners[allUsers[i].ID] = allUsers[i].Username
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "admin_posts.html", struct {
+		Posts  []posts.Post
+		Owners map[int]string
+		Nav    pagination.Nav
+		Query  string
+		CSRF   string
+	}{
+		Posts:  page.Items,
+		Owners: owners,
+		Nav:    pagination.NewNav(r.URL, page.Prev, page.Next),
+		Query:  query,
+		CSRF:   middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// TakedownPost removes any post along with its stored files.
+func (h *AdminHandler) TakedownPost(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := middleware.GetUserID(r)
+	postID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.postSvc.GetPost(r.Context(), postID)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error getting post", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.postSvc.DeletePost(r.Context(), post); err != nil {
+		http.Error(w, "Error deleting post", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Admin %d took down post %d\n", actorID, post.ID)
+	http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+}
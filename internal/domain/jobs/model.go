@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"goserv/internal/static/enum"
+	"time"
+)
+
+type Kind string
+
+const (
+	// KindProcessPost makes the thumbnails for a freshly uploaded post.
+	KindProcessPost Kind = "process_post"
+)
+
+type Job struct {
+	ID        int
+	Kind      Kind
+	PostID    int
+	State     enum.JobState
+	Attempts  int
+	RunAt     time.Time
+	LastError string
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"goserv/ent/gen"
+	entJob "goserv/ent/gen/job"
+	"goserv/internal/domain/jobs"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/errors"
+	"time"
+)
+
+// claimTries bounds how often Claim retries after losing a job to another worker.
+const claimTries = 3
+
+type Job interface {
+	Enqueue(ctx context.Context, job *jobs.Job) (int, error)
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (*jobs.Job, error)
+	Renew(ctx context.Context, jobID int, lockedUntil time.Time) error
+	Complete(ctx context.Context, jobID int) error
+	Retry(ctx context.Context, jobID int, runAt time.Time, lastError string) error
+	Fail(ctx context.Context, jobID int, lastError string) error
+}
+
+type jobRepository struct {
+	client *gen.Client
+}
+
+func NewJobRepository(client *gen.Client) *jobRepository {
+	return &jobRepository{client: client}
+}
+
+func (repo *jobRepository) Enqueue(ctx context.Context, job *jobs.Job) (int, error) {
+	create := repo.client.Job.
+		Create().
+		SetKind(string(job.Kind))
+	if job.PostID != 0 {
+		create = create.SetPostID(job.PostID)
+	}
+	if !job.RunAt.IsZero() {
+		create = create.SetRunAt(job.RunAt)
+	}
+
+	saved, err := create.Save(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return saved.ID, nil
+}
+
+// Claim takes the oldest due job, or one whose worker stopped renewing its lease, and marks it running
+// until now+lease. The conditional update means only one worker wins when several look at the same row.
+func (repo *jobRepository) Claim(ctx context.Context, now time.Time, lease time.Duration) (*jobs.Job, error) {
+	for range claimTries {
+		job, err := repo.client.Job.
+			Query().
+			Where(entJob.Or(
+				entJob.And(entJob.StateEQ(entJob.StateQueued), entJob.RunAtLTE(now)),
+				entJob.And(entJob.StateEQ(entJob.StateRunning), entJob.LockedUntilLT(now)),
+			)).
+			Order(entJob.ByRunAt()).
+			First(ctx)
+		if err != nil {
+			if gen.IsNotFound(err) {
+				return nil, errors.ErrNotFound
+			}
+			return nil, err
+		}
+
+		update := repo.client.Job.
+			Update().
+			Where(entJob.IDEQ(job.ID), entJob.StateEQ(job.State)).
+			SetState(entJob.StateRunning).
+			SetLockedUntil(now.Add(lease)).
+			AddAttempts(1)
+		if job.LockedUntil != nil {
+			update = update.Where(entJob.LockedUntilEQ(*job.LockedUntil))
+		}
+
+		claimed, err := update.Save(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if claimed == 1 {
+			job.State = entJob.StateRunning
+			job.Attempts++
+			return toDomainJob(job), nil
+		}
+	}
+	return nil, errors.ErrNotFound
+}
+
+// Renew moves a running job's lease on to lockedUntil, keeping other workers off it while it runs.
+func (repo *jobRepository) Renew(ctx context.Context, jobID int, lockedUntil time.Time) error {
+	_, err := repo.client.Job.
+		Update().
+		Where(entJob.IDEQ(jobID), entJob.StateEQ(entJob.StateRunning)).
+		SetLockedUntil(lockedUntil).
+		Save(ctx)
+	return err
+}
+
+func (repo *jobRepository) Complete(ctx context.Context, jobID int) error {
+	return repo.client.Job.
+		UpdateOneID(jobID).
+		SetState(entJob.StateDone).
+		ClearLockedUntil().
+		SetLastError("").
+		Exec(ctx)
+}
+
+func (repo *jobRepository) Retry(ctx context.Context, jobID int, runAt time.Time, lastError string) error {
+	return repo.client.Job.
+		UpdateOneID(jobID).
+		SetState(entJob.StateQueued).
+		SetRunAt(runAt).
+		ClearLockedUntil().
+		SetLastError(lastError).
+		Exec(ctx)
+}
+
+func (repo *jobRepository) Fail(ctx context.Context, jobID int, lastError string) error {
+	return repo.client.Job.
+		UpdateOneID(jobID).
+		SetState(entJob.StateFailed).
+		ClearLockedUntil().
+		SetLastError(lastError).
+		Exec(ctx)
+}
+
+func toDomainJob(job *gen.Job) *jobs.Job {
+	return &jobs.Job{
+		ID:        job.ID,
+		Kind:      jobs.Kind(job.Kind),
+		PostID:    job.PostID,
+		State:     enum.JobState(job.State),
+		Attempts:  job.Attempts,
+		RunAt:     job.RunAt,
+		LastError: job.LastError,
+	}
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"goserv/internal/domain/jobs"
+	"time"
+)
+
+type JobMock struct {
+	EnqueueFunc  func(ctx context.Context, job *jobs.Job) (int, error)
+	ClaimFunc    func(ctx context.Context, now time.Time, lease time.Duration) (*jobs.Job, error)
+	RenewFunc    func(ctx context.Context, jobID int, lockedUntil time.Time) error
+	CompleteFunc func(ctx context.Context, jobID int) error
+	RetryFunc    func(ctx context.Context, jobID int, runAt time.Time, lastError string) error
+	FailFunc     func(ctx context.Context, jobID int, lastError string) error
+}
+
+func (m *JobMock) Enqueue(ctx context.Context, job *jobs.Job) (int, error) {
+	return m.EnqueueFunc(ctx, job)
+}
+
+func (m *JobMock) Claim(ctx context.Context, now time.Time, lease time.Duration) (*jobs.Job, error) {
+	return m.ClaimFunc(ctx, now, lease)
+}
+
+func (m *JobMock) Renew(ctx context.Context, jobID int, lockedUntil time.Time) error {
+	return m.RenewFunc(ctx, jobID, lockedUntil)
+}
+
+func (m *JobMock) Complete(ctx context.Context, jobID int) error {
+	return m.CompleteFunc(ctx, jobID)
+}
+
+func (m *JobMock) Retry(ctx context.Context, jobID int, runAt time.Time, lastError string) error {
+	return m.RetryFunc(ctx, jobID, runAt, lastError)
+}
+
+func (m *JobMock) Fail(ctx context.Context, jobID int, lastError string) error {
+	return m.FailFunc(ctx, jobID, lastError)
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goserv/internal/domain/jobs"
+	"goserv/internal/domain/jobs/repository"
+	myErrors "goserv/internal/utils/errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 30 * time.Minute
+	// lease is how long a worker holds a job without renewing it before another worker may pick it up again.
+	lease = 10 * time.Minute
+)
+
+// Handler runs one kind of job. OnFailure, if set, is called once the job has used up its attempts.
+type Handler struct {
+	Run       func(ctx context.Context, job *jobs.Job) error
+	OnFailure func(ctx context.Context, job *jobs.Job, err error)
+}
+
+type Options struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+}
+
+type JobService struct {
+	repo     repository.Job
+	opts     Options
+	handlers map[jobs.Kind]Handler
+	now      func() time.Time
+	lease    time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewJobService(repo repository.Job, opts Options) *JobService {
+	return &JobService{
+		repo:     repo,
+		opts:     opts,
+		handlers: make(map[jobs.Kind]Handler),
+		now:      time.Now,
+		lease:    lease,
+	}
+}
+
+// Register must be called before Start.
+func (s *JobService) Register(kind jobs.Kind, handler Handler) {
+	s.handlers[kind] = handler
+}
+
+// Backoff is how long to wait before the next try after the given number of attempts.
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Start launches the worker pool. Workers keep going until Stop is called.
+func (s *JobService) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for range s.opts.Workers {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.work(ctx)
+		}()
+	}
+}
+
+// Stop waits for running jobs to return. A job cut short by shutdown is picked up again once its lease runs out.
+func (s *JobService) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *JobService) work(ctx context.Context) {
+	for {
+		ran, err := s.RunNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Job worker error: %v\n", err)
+		}
+		if ran {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.opts.PollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs a single job, reporting whether there was one to run.
+func (s *JobService) RunNext(ctx context.Context) (bool, error) {
+	job, err := s.repo.Claim(ctx, s.now(), s.lease)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	handler, ok := s.handlers[job.Kind]
+	if !ok {
+		return true, s.repo.Fail(ctx, job.ID, fmt.Sprintf("no handler for job kind %q", job.Kind))
+	}
+
+	runErr := s.runLeased(ctx, handler, job)
+	if runErr == nil {
+		return true, s.repo.Complete(ctx, job.ID)
+	}
+	if ctx.Err() != nil {
+		// shutting down, leave the job for its lease to expire
+		return true, nil
+	}
+
+	if job.Attempts < s.opts.MaxAttempts {
+		log.Printf("Job %d (%s) failed on attempt %d, retrying: %v\n", job.ID, job.Kind, job.Attempts, runErr)
+		return true, s.repo.Retry(ctx, job.ID, s.now().Add(Backoff(job.Attempts)), runErr.Error())
+	}
+
+	log.Printf("Job %d (%s) failed after %d attempts: %v\n", job.ID, job.Kind, job.Attempts, runErr)
+	if handler.OnFailure != nil {
+		handler.OnFailure(ctx, job, runErr)
+	}
+	return true, s.repo.Fail(ctx, job.ID, runErr.Error())
+}
+
+// runLeased runs the job, renewing its lease every third of the lease while it is still going so that
+// a slow job is not picked up by a second worker. Renewal stops on shutdown, letting the lease run out.
+func (s *JobService) runLeased(ctx context.Context, handler Handler, job *jobs.Job) error {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.repo.Renew(ctx, job.ID, s.now().Add(s.lease)); err != nil && ctx.Err() == nil {
+					log.Printf("Failed to renew lease on job %d (%s): %v\n", job.ID, job.Kind, err)
+				}
+			}
+		}
+	}()
+
+	err := handler.Run(ctx, job)
+	close(done)
+	wg.Wait()
+	return err
+}
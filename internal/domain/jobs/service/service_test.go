@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"goserv/internal/domain/jobs"
+	"goserv/internal/domain/jobs/repository"
+	myErrors "goserv/internal/utils/errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, maxBackoff, Backoff(20))
+}
+
+func TestJobService_RunNext(t *testing.T) {
+	type args struct {
+		job    *jobs.Job
+		runErr error
+	}
+	type want struct {
+		ran      bool
+		outcome  string
+		runAt    time.Time
+		failedCb bool
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []test{
+		{
+			name: "job succeeds",
+			args: args{job: &jobs.Job{ID: 1, Kind: jobs.KindProcessPost, Attempts: 1}},
+			want: want{ran: true, outcome: "complete"},
+		},
+		{
+			name: "job fails with attempts left",
+			args: args{job: &jobs.Job{ID: 1, Kind: jobs.KindProcessPost, Attempts: 2}, runErr: errors.New("test error")},
+			want: want{ran: true, outcome: "retry", runAt: now.Add(time.Minute)},
+		},
+		{
+			name: "job fails on last attempt",
+			args: args{job: &jobs.Job{ID: 1, Kind: jobs.KindProcessPost, Attempts: 3}, runErr: errors.New("test error")},
+			want: want{ran: true, outcome: "fail", failedCb: true},
+		},
+		{
+			name: "unknown kind",
+			args: args{job: &jobs.Job{ID: 1, Kind: "mystery", Attempts: 1}},
+			want: want{ran: true, outcome: "fail"},
+		},
+		{
+			name: "nothing queued",
+			args: args{job: nil},
+			want: want{ran: false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var outcome string
+			var runAt time.Time
+			jobRepo := &repository.JobMock{
+				ClaimFunc: func(ctx context.Context, claimAt time.Time, lease time.Duration) (*jobs.Job, error) {
+					assert.Equal(t, now, claimAt)
+					if test.args.job == nil {
+						return nil, myErrors.ErrNotFound
+					}
+					return test.args.job, nil
+				},
+				CompleteFunc: func(ctx context.Context, jobID int) error {
+					outcome = "complete"
+					return nil
+				},
+				RetryFunc: func(ctx context.Context, jobID int, retryAt time.Time, lastError string) error {
+					outcome = "retry"
+					runAt = retryAt
+					assert.Equal(t, test.args.runErr.Error(), lastError)
+					return nil
+				},
+				FailFunc: func(ctx context.Context, jobID int, lastError string) error {
+					outcome = "fail"
+					return nil
+				},
+			}
+
+			failedCb := false
+			service := NewJobService(jobRepo, Options{Workers: 1, PollInterval: time.Second, MaxAttempts: 3})
+			service.now = func() time.Time { return now }
+			service.Register(jobs.KindProcessPost, Handler{
+				Run: func(ctx context.Context, job *jobs.Job) error {
+					return test.args.runErr
+				},
+				OnFailure: func(ctx context.Context, job *jobs.Job, err error) {
+					failedCb = true
+				},
+			})
+
+			ran, err := service.RunNext(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, test.want.ran, ran)
+			assert.Equal(t, test.want.outcome, outcome)
+			assert.Equal(t, test.want.runAt, runAt)
+			assert.Equal(t, test.want.failedCb, failedCb)
+		})
+	}
+}
+
+func TestJobService_RunNextRenewsLease(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	renewals := make(chan time.Time, 100)
+	jobRepo := &repository.JobMock{
+		ClaimFunc: func(ctx context.Context, claimAt time.Time, lease time.Duration) (*jobs.Job, error) {
+			assert.Equal(t, 30*time.Millisecond, lease)
+			return &jobs.Job{ID: 1, Kind: jobs.KindProcessPost, Attempts: 1}, nil
+		},
+		RenewFunc: func(ctx context.Context, jobID int, lockedUntil time.Time) error {
+			renewals <- lockedUntil
+			return nil
+		},
+		CompleteFunc: func(ctx context.Context, jobID int) error {
+			return nil
+		},
+	}
+
+	service := NewJobService(jobRepo, Options{Workers: 1, PollInterval: time.Second, MaxAttempts: 3})
+	service.now = func() time.Time { return now }
+	service.lease = 30 * time.Millisecond
+	service.Register(jobs.KindProcessPost, Handler{
+		Run: func(ctx context.Context, job *jobs.Job) error {
+			// outlive the lease several times over
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		},
+	})
+
+	ran, err := service.RunNext(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ran)
+
+	close(renewals)
+	count := 0
+	for lockedUntil := range renewals {
+		assert.Equal(t, now.Add(30*time.Millisecond), lockedUntil)
+		count++
+	}
+	assert.GreaterOrEqual(t, count, 3)
+}
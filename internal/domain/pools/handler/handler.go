@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"errors"
+	"goserv/internal/domain/pools"
+	"goserv/internal/domain/pools/service"
+	"goserv/internal/middleware"
+	"goserv/internal/static/constant"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PoolHandler struct {
+	svc  *service.PoolService
+	tmpl *template.Template
+}
+
+func NewPoolHandler(svc *service.PoolService, tmpl *template.Template) *PoolHandler {
+	return &PoolHandler{svc: svc, tmpl: tmpl}
+}
+
+type poolEntry struct {
+	ID        int
+	Thumbnail string
+	Ready     bool
+}
+
+func (h *PoolHandler) ListPools(w http.ResponseWriter, r *http.Request) {
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid page request", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.svc.ListPools(r.Context(), pageReq)
+	if err != nil {
+		http.Error(w, "Failed to list pools", http.StatusInternalServerError)
+		return
+	}
+
+	isUser := false
+	userID, ok := middleware.GetUserID(r)
+	if ok && userID != 0 {
+		isUser = true
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "pools.html", struct {
+		Pools  []pools.Pool
+		Nav    pagination.Nav
+		IsUser bool
+		CSRF   string
+	}{
+		Pools:  page.Items,
+		Nav:    pagination.NewNav(r.URL, page.Prev, page.Next),
+		IsUser: isUser,
+		CSRF:   middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ViewPool shows a pool's posts in pool order, with the controls to rearrange it for those who can.
+func (h *PoolHandler) ViewPool(w http.ResponseWriter, r *http.Request) {
+	poolID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	pool, err := h.svc.GetPool(r.Context(), poolID)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Error getting pool", http.StatusInternalServerError)
+		return
+	}
+
+	poolPosts, err := h.svc.ListPoolPosts(r.Context(), poolID)
+	if err != nil {
+		http.Error(w, "Error listing posts", http.StatusInternalServerError)
+		return
+	}
+
+	isUser := false
+	userID, ok := middleware.GetUserID(r)
+	if ok && userID != 0 {
+		isUser = true
+	}
+
+	canEdit, err := h.svc.CanEdit(r.Context(), pool, userID)
+	if err != nil {
+		http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]poolEntry, len(poolPosts))
+	for i := range poolPosts {
+		entries[i] = poolEntry{
+			ID:        poolPosts[i].ID,
+			Thumbnail: poolPosts[i].Filename + constant.ThumbnailExt,
+			Ready:     poolPosts[i].Ready(),
+		}
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "pool.html", struct {
+		Pool    *pools.Pool
+		Posts   []poolEntry
+		IsUser  bool
+		CanEdit bool
+		CSRF    string
+	}{
+		Pool:    pool,
+		Posts:   entries,
+		IsUser:  isUser,
+		CanEdit: canEdit,
+		CSRF:    middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *PoolHandler) CreatePool(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	poolID, err := h.svc.CreatePool(r.Context(), r.FormValue("name"), r.FormValue("description"), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidName) || errors.Is(err, service.ErrDescriptionLength) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to create pool", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/pools/"+strconv.Itoa(poolID), http.StatusSeeOther)
+}
+
+func (h *PoolHandler) EditPool(w http.ResponseWriter, r *http.Request) {
+	userID, poolID, ok := readPoolRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.svc.UpdatePool(r.Context(), poolID, r.FormValue("name"), r.FormValue("description"), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidName) || errors.Is(err, service.ErrDescriptionLength) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writePoolError(w, r, err, "Failed to update pool")
+		return
+	}
+
+	http.Redirect(w, r, "/pools/"+strconv.Itoa(poolID), http.StatusSeeOther)
+}
+
+func (h *PoolHandler) DeletePool(w http.ResponseWriter, r *http.Request) {
+	userID, poolID, ok := readPoolRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeletePool(r.Context(), poolID, userID); err != nil {
+		writePoolError(w, r, err, "Failed to delete pool")
+		return
+	}
+
+	http.Redirect(w, r, "/pools", http.StatusSeeOther)
+}
+
+// AddPost puts the post at the end of the pool picked on its page, then goes back to the post.
+func (h *PoolHandler) AddPost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	poolID, err := strconv.Atoi(r.FormValue("pool"))
+	if err != nil {
+		http.Error(w, "Error getting pool id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.svc.AddPost(r.Context(), poolID, postID, userID)
+	if err != nil && !errors.Is(err, pools.ErrAlreadyInPool) {
+		writePoolError(w, r, err, "Failed to add post to pool")
+		return
+	}
+
+	http.Redirect(w, r, "/view/posts/"+strconv.Itoa(postID), http.StatusSeeOther)
+}
+
+func (h *PoolHandler) RemovePost(w http.ResponseWriter, r *http.Request) {
+	userID, poolID, ok := readPoolRequest(w, r)
+	if !ok {
+		return
+	}
+
+	postID, err := strconv.Atoi(r.FormValue("post"))
+	if err != nil {
+		http.Error(w, "Error getting post id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.RemovePost(r.Context(), poolID, postID, userID); err != nil {
+		writePoolError(w, r, err, "Failed to remove post from pool")
+		return
+	}
+
+	http.Redirect(w, r, "/pools/"+strconv.Itoa(poolID), http.StatusSeeOther)
+}
+
+// ReorderPosts saves the order the pool page was dragged into, given as every post ID in the pool.
+func (h *PoolHandler) ReorderPosts(w http.ResponseWriter, r *http.Request) {
+	userID, poolID, ok := readPoolRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	postIDs := make([]int, len(r.PostForm["post"]))
+	for i, value := range r.PostForm["post"] {
+		postID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Error getting post id", http.StatusBadRequest)
+			return
+		}
+		postIDs[i] = postID
+	}
+
+	err := h.svc.ReorderPosts(r.Context(), poolID, postIDs, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrDuplicatePost) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, pools.ErrOrderChanged) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writePoolError(w, r, err, "Failed to reorder pool")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func readPoolRequest(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	poolID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return 0, 0, false
+	}
+	return userID, poolID, true
+}
+
+func writePoolError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, myErrors.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, service.ErrNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
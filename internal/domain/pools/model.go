@@ -0,0 +1,33 @@
+package pools
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrAlreadyInPool = errors.New("post is already in this pool")
+var ErrOrderChanged = errors.New("the pool changed since it was loaded, reload and try again")
+
+type Pool struct {
+	ID          int
+	Name        string
+	Description string
+	OwnerID     int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	PostCount int
+	// Cover is the thumbnail filename of the first post, empty until that post is processed
+	Cover string
+}
+
+// Membership places a post within one of the pools holding it. Index counts from 1, and PrevID and
+// NextID are 0 at either end of the pool.
+type Membership struct {
+	PoolID   int
+	PoolName string
+	Index    int
+	Count    int
+	PrevID   int
+	NextID   int
+}
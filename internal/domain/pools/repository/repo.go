@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"goserv/ent/gen"
+	entPool "goserv/ent/gen/pool"
+	entEntry "goserv/ent/gen/poolentry"
+	"goserv/internal/domain/pools"
+	"goserv/internal/domain/posts"
+	"goserv/internal/static/constant"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"slices"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+)
+
+type Pool interface {
+	CreatePool(ctx context.Context, pool *pools.Pool) (int, error)
+	GetPool(ctx context.Context, poolID int) (*pools.Pool, error)
+	ListPools(ctx context.Context, page pagination.PageRequest) (pagination.Page[pools.Pool], error)
+	ListUserPools(ctx context.Context, userID int) ([]pools.Pool, error)
+	UpdatePool(ctx context.Context, poolID int, name string, description string) error
+	DeletePool(ctx context.Context, poolID int) error
+	ListPoolPosts(ctx context.Context, poolID int) ([]posts.Post, error)
+	AddPost(ctx context.Context, poolID int, postID int) error
+	RemovePost(ctx context.Context, poolID int, postID int) error
+	ReorderPosts(ctx context.Context, poolID int, postIDs []int) error
+	ListMemberships(ctx context.Context, postID int) ([]pools.Membership, error)
+}
+
+type poolRepository struct {
+	client *gen.Client
+}
+
+func NewPoolRepository(client *gen.Client) *poolRepository {
+	return &poolRepository{client: client}
+}
+
+func (repo *poolRepository) CreatePool(ctx context.Context, pool *pools.Pool) (int, error) {
+	saved, err := repo.client.Pool.
+		Create().
+		SetName(pool.Name).
+		SetDescription(pool.Description).
+		SetUserID(pool.OwnerID).
+		Save(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return saved.ID, nil
+}
+
+func (repo *poolRepository) GetPool(ctx context.Context, poolID int) (*pools.Pool, error) {
+	pool, err := repo.client.Pool.Query().Where(entPool.IDEQ(poolID)).Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	result := toDomainPool(pool)
+	if err := repo.fillSummary(ctx, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (repo *poolRepository) ListPools(ctx context.Context, page pagination.PageRequest) (pagination.Page[pools.Pool], error) {
+	page = page.Normalize()
+	cursor, hasCursor, err := page.Cursor()
+	if err != nil {
+		return pagination.Page[pools.Pool]{}, err
+	}
+
+	query := repo.client.Pool.Query()
+	ascending := page.Ascending(cursor)
+	if hasCursor {
+		if ascending {
+			query = query.Where(entPool.IDGT(cursor.ID))
+		} else {
+			query = query.Where(entPool.IDLT(cursor.ID))
+		}
+	}
+	if ascending {
+		query = query.Order(entPool.ByID())
+	} else {
+		query = query.Order(entPool.ByID(sql.OrderDesc()))
+	}
+
+	entPools, err := query.Limit(page.Limit + 1).All(ctx)
+	if err != nil {
+		return pagination.Page[pools.Pool]{}, err
+	}
+
+	result := pagination.NewPage(toDomainPools(entPools), page, cursor, hasCursor, func(pool pools.Pool) int {
+		return pool.ID
+	})
+	for i := range result.Items {
+		if err := repo.fillSummary(ctx, &result.Items[i]); err != nil {
+			return pagination.Page[pools.Pool]{}, err
+		}
+	}
+	return result, nil
+}
+
+// ListUserPools returns the pools a user owns by name, without their summaries.
+func (repo *poolRepository) ListUserPools(ctx context.Context, userID int) ([]pools.Pool, error) {
+	entPools, err := repo.client.Pool.Query().Where(entPool.UserIDEQ(userID)).Order(entPool.ByName()).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toDomainPools(entPools), nil
+}
+
+// fillSummary counts a pool's posts and finds its cover.
+func (repo *poolRepository) fillSummary(ctx context.Context, pool *pools.Pool) error {
+	count, err := repo.client.PoolEntry.Query().Where(entEntry.PoolIDEQ(pool.ID)).Count(ctx)
+	if err != nil {
+		return err
+	}
+	pool.PostCount = count
+	if count == 0 {
+		return nil
+	}
+
+	first, err := repo.client.PoolEntry.
+		Query().
+		Where(entEntry.PoolIDEQ(pool.ID)).
+		Order(entEntry.ByPosition(), entEntry.ByID()).
+		WithPost().
+		First(ctx)
+	if err != nil {
+		return err
+	}
+	if post := first.Edges.Post; post != nil && enum.ProcessingState(post.ProcessingState) == enum.ProcessingReady {
+		pool.Cover = post.Filename + constant.ThumbnailExt
+	}
+	return nil
+}
+
+func (repo *poolRepository) UpdatePool(ctx context.Context, poolID int, name string, description string) error {
+	err := repo.client.Pool.UpdateOneID(poolID).SetName(name).SetDescription(description).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func (repo *poolRepository) DeletePool(ctx context.Context, poolID int) error {
+	err := repo.client.Pool.DeleteOneID(poolID).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+// ListPoolPosts returns the pool's posts in pool order.
+func (repo *poolRepository) ListPoolPosts(ctx context.Context, poolID int) ([]posts.Post, error) {
+	entries, err := repo.client.PoolEntry.
+		Query().
+		Where(entEntry.PoolIDEQ(poolID)).
+		Order(entEntry.ByPosition(), entEntry.ByID()).
+		WithPost().
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]posts.Post, 0, len(entries))
+	for _, entry := range entries {
+		post := entry.Edges.Post
+		if post == nil {
+			continue
+		}
+		result = append(result, posts.Post{
+			ID:              post.ID,
+			Title:           post.Title,
+			MediaType:       enum.MediaType(post.MediaType),
+			Filename:        post.Filename,
+			FileExt:         post.FileExt,
+			OwnerID:         post.UserOwns,
+			ProcessingState: enum.ProcessingState(post.ProcessingState),
+		})
+	}
+	return result, nil
+}
+
+// AddPost puts the post at the end of the pool.
+func (repo *poolRepository) AddPost(ctx context.Context, poolID int, postID int) error {
+	return withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		exists, err := tx.PoolEntry.Query().Where(entEntry.PoolIDEQ(poolID), entEntry.PostIDEQ(postID)).Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return pools.ErrAlreadyInPool
+		}
+
+		last, err := tx.PoolEntry.Query().Where(entEntry.PoolIDEQ(poolID)).Order(entEntry.ByPosition(sql.OrderDesc())).First(ctx)
+		position := 1
+		if err == nil {
+			position = last.Position + 1
+		} else if !gen.IsNotFound(err) {
+			return err
+		}
+
+		err = tx.PoolEntry.Create().SetPoolID(poolID).SetPostID(postID).SetPosition(position).Exec(ctx)
+		if err != nil {
+			if gen.IsConstraintError(err) {
+				// the pool or post went away, or another request added the post first
+				return errors.ErrNotFound
+			}
+			return err
+		}
+		return touchPool(ctx, tx, poolID)
+	})
+}
+
+func (repo *poolRepository) RemovePost(ctx context.Context, poolID int, postID int) error {
+	return withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		removed, err := tx.PoolEntry.Delete().Where(entEntry.PoolIDEQ(poolID), entEntry.PostIDEQ(postID)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			return errors.ErrNotFound
+		}
+		return touchPool(ctx, tx, poolID)
+	})
+}
+
+// ReorderPosts numbers the pool's posts in the order given, which must hold every post in the pool once.
+func (repo *poolRepository) ReorderPosts(ctx context.Context, poolID int, postIDs []int) error {
+	return withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		entries, err := tx.PoolEntry.Query().Where(entEntry.PoolIDEQ(poolID)).All(ctx)
+		if err != nil {
+			return err
+		}
+		if len(entries) != len(postIDs) {
+			return pools.ErrOrderChanged
+		}
+
+		entryIDs := make(map[int]int, len(entries))
+		for _, entry := range entries {
+			entryIDs[entry.PostID] = entry.ID
+		}
+		for i, postID := range postIDs {
+			entryID, ok := entryIDs[postID]
+			if !ok {
+				return pools.ErrOrderChanged
+			}
+			if err := tx.PoolEntry.UpdateOneID(entryID).SetPosition(i + 1).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return touchPool(ctx, tx, poolID)
+	})
+}
+
+// ListMemberships finds the pools a post is in along with its neighbours in each.
+func (repo *poolRepository) ListMemberships(ctx context.Context, postID int) ([]pools.Membership, error) {
+	entries, err := repo.client.PoolEntry.Query().Where(entEntry.PostIDEQ(postID)).WithPool().All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships := make([]pools.Membership, 0, len(entries))
+	for _, entry := range entries {
+		order, err := repo.client.PoolEntry.
+			Query().
+			Where(entEntry.PoolIDEQ(entry.PoolID)).
+			Order(entEntry.ByPosition(), entEntry.ByID()).
+			Select(entEntry.FieldPostID).
+			Ints(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		index := slices.Index(order, postID)
+		if index == -1 || entry.Edges.Pool == nil {
+			continue
+		}
+		membership := pools.Membership{
+			PoolID:   entry.PoolID,
+			PoolName: entry.Edges.Pool.Name,
+			Index:    index + 1,
+			Count:    len(order),
+		}
+		if index > 0 {
+			membership.PrevID = order[index-1]
+		}
+		if index < len(order)-1 {
+			membership.NextID = order[index+1]
+		}
+		memberships = append(memberships, membership)
+	}
+
+	slices.SortFunc(memberships, func(a pools.Membership, b pools.Membership) int {
+		return a.PoolID - b.PoolID
+	})
+	return memberships, nil
+}
+
+// touchPool marks the pool as changed when only its entries were.
+func touchPool(ctx context.Context, tx *gen.Tx, poolID int) error {
+	err := tx.Pool.UpdateOneID(poolID).SetUpdatedAt(time.Now()).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func withTx(ctx context.Context, client *gen.Client, fn func(tx *gen.Tx) error) error {
+	tx, err := client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func toDomainPool(pool *gen.Pool) pools.Pool {
+	return pools.Pool{
+		ID:          pool.ID,
+		Name:        pool.Name,
+		Description: pool.Description,
+		OwnerID:     pool.UserID,
+		CreatedAt:   pool.CreatedAt,
+		UpdatedAt:   pool.UpdatedAt,
+	}
+}
+
+func toDomainPools(entPools []*gen.Pool) []pools.Pool {
+	result := make([]pools.Pool, len(entPools))
+	for i := range entPools {
+		result[i] = toDomainPool(entPools[i])
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"goserv/internal/domain/pools"
+	"goserv/internal/domain/posts"
+	"goserv/internal/utils/pagination"
+)
+
+type PoolMock struct {
+	CreatePoolFunc      func(ctx context.Context, pool *pools.Pool) (int, error)
+	GetPoolFunc         func(ctx context.Context, poolID int) (*pools.Pool, error)
+	ListPoolsFunc       func(ctx context.Context, page pagination.PageRequest) (pagination.Page[pools.Pool], error)
+	ListUserPoolsFunc   func(ctx context.Context, userID int) ([]pools.Pool, error)
+	UpdatePoolFunc      func(ctx context.Context, poolID int, name string, description string) error
+	DeletePoolFunc      func(ctx context.Context, poolID int) error
+	ListPoolPostsFunc   func(ctx context.Context, poolID int) ([]posts.Post, error)
+	AddPostFunc         func(ctx context.Context, poolID int, postID int) error
+	RemovePostFunc      func(ctx context.Context, poolID int, postID int) error
+	ReorderPostsFunc    func(ctx context.Context, poolID int, postIDs []int) error
+	ListMembershipsFunc func(ctx context.Context, postID int) ([]pools.Membership, error)
+}
+
+func (m *PoolMock) CreatePool(ctx context.Context, pool *pools.Pool) (int, error) {
+	return m.CreatePoolFunc(ctx, pool)
+}
+
+func (m *PoolMock) GetPool(ctx context.Context, poolID int) (*pools.Pool, error) {
+	return m.GetPoolFunc(ctx, poolID)
+}
+
+func (m *PoolMock) ListPools(ctx context.Context, page pagination.PageRequest) (pagination.Page[pools.Pool], error) {
+	return m.ListPoolsFunc(ctx, page)
+}
+
+func (m *PoolMock) ListUserPools(ctx context.Context, userID int) ([]pools.Pool, error) {
+	return m.ListUserPoolsFunc(ctx, userID)
+}
+
+func (m *PoolMock) UpdatePool(ctx context.Context, poolID int, name string, description string) error {
+	return m.UpdatePoolFunc(ctx, poolID, name, description)
+}
+
+func (m *PoolMock) DeletePool(ctx context.Context, poolID int) error {
+	return m.DeletePoolFunc(ctx, poolID)
+}
+
+func (m *PoolMock) ListPoolPosts(ctx context.Context, poolID int) ([]posts.Post, error) {
+	return m.ListPoolPostsFunc(ctx, poolID)
+}
+
+func (m *PoolMock) AddPost(ctx context.Context, poolID int, postID int) error {
+	return m.AddPostFunc(ctx, poolID, postID)
+}
+
+func (m *PoolMock) RemovePost(ctx context.Context, poolID int, postID int) error {
+	return m.RemovePostFunc(ctx, poolID, postID)
+}
+
+func (m *PoolMock) ReorderPosts(ctx context.Context, poolID int, postIDs []int) error {
+	return m.ReorderPostsFunc(ctx, poolID, postIDs)
+}
+
+func (m *PoolMock) ListMemberships(ctx context.Context, postID int) ([]pools.Membership, error) {
+	return m.ListMembershipsFunc(ctx, postID)
+}
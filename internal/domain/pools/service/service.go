@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goserv/internal/domain/pools"
+	"goserv/internal/domain/pools/repository"
+	"goserv/internal/domain/posts"
+	uRepo "goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/pagination"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidName = fmt.Errorf("pool name must be 1 to %d characters", maxNameLength)
+var ErrDescriptionLength = fmt.Errorf("pool description can be at most %d characters", maxDescriptionLength)
+var ErrNotAllowed = errors.New("only the pool's owner can change it")
+var ErrDuplicatePost = errors.New("a post can only appear once in the new order")
+
+const maxNameLength = 100
+const maxDescriptionLength = 2000
+
+type PoolService struct {
+	repo     repository.Pool
+	userRepo uRepo.User
+}
+
+func NewPoolService(repo repository.Pool, userRepo uRepo.User) *PoolService {
+	return &PoolService{repo: repo, userRepo: userRepo}
+}
+
+func (s *PoolService) CreatePool(ctx context.Context, name string, description string, userID int) (int, error) {
+	name, description, err := cleanPoolText(name, description)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.CreatePool(ctx, &pools.Pool{Name: name, Description: description, OwnerID: userID})
+}
+
+func (s *PoolService) GetPool(ctx context.Context, poolID int) (*pools.Pool, error) {
+	return s.repo.GetPool(ctx, poolID)
+}
+
+func (s *PoolService) ListPools(ctx context.Context, page pagination.PageRequest) (pagination.Page[pools.Pool], error) {
+	return s.repo.ListPools(ctx, page)
+}
+
+func (s *PoolService) ListUserPools(ctx context.Context, userID int) ([]pools.Pool, error) {
+	return s.repo.ListUserPools(ctx, userID)
+}
+
+func (s *PoolService) ListPoolPosts(ctx context.Context, poolID int) ([]posts.Post, error) {
+	return s.repo.ListPoolPosts(ctx, poolID)
+}
+
+// ListMemberships returns where the post sits in each pool holding it, for stepping through a pool
+// from the post's page.
+func (s *PoolService) ListMemberships(ctx context.Context, postID int) ([]pools.Membership, error) {
+	return s.repo.ListMemberships(ctx, postID)
+}
+
+func (s *PoolService) UpdatePool(ctx context.Context, poolID int, name string, description string, userID int) error {
+	name, description, err := cleanPoolText(name, description)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCanEdit(ctx, poolID, userID); err != nil {
+		return err
+	}
+	return s.repo.UpdatePool(ctx, poolID, name, description)
+}
+
+// DeletePool removes the pool but leaves its posts alone.
+func (s *PoolService) DeletePool(ctx context.Context, poolID int, userID int) error {
+	if err := s.checkCanEdit(ctx, poolID, userID); err != nil {
+		return err
+	}
+	return s.repo.DeletePool(ctx, poolID)
+}
+
+func (s *PoolService) AddPost(ctx context.Context, poolID int, postID int, userID int) error {
+	if err := s.checkCanEdit(ctx, poolID, userID); err != nil {
+		return err
+	}
+	return s.repo.AddPost(ctx, poolID, postID)
+}
+
+func (s *PoolService) RemovePost(ctx context.Context, poolID int, postID int, userID int) error {
+	if err := s.checkCanEdit(ctx, poolID, userID); err != nil {
+		return err
+	}
+	return s.repo.RemovePost(ctx, poolID, postID)
+}
+
+// ReorderPosts puts the pool's posts in the order given. The order has to name every post in the pool,
+// so a page that is out of date cannot drop posts added since it was loaded.
+func (s *PoolService) ReorderPosts(ctx context.Context, poolID int, postIDs []int, userID int) error {
+	sorted := slices.Clone(postIDs)
+	slices.Sort(sorted)
+	if len(slices.Compact(sorted)) != len(postIDs) {
+		return ErrDuplicatePost
+	}
+	if err := s.checkCanEdit(ctx, poolID, userID); err != nil {
+		return err
+	}
+	return s.repo.ReorderPosts(ctx, poolID, postIDs)
+}
+
+// CanEdit reports whether the user may change the pool, which its owner and anyone allowed to edit any
+// post can.
+func (s *PoolService) CanEdit(ctx context.Context, pool *pools.Pool, userID int) (bool, error) {
+	if userID == 0 {
+		return false, nil
+	}
+	if pool.OwnerID == userID {
+		return true, nil
+	}
+	perms, err := s.userRepo.GetPermissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(perms, enum.PermPostEditAny), nil
+}
+
+func (s *PoolService) checkCanEdit(ctx context.Context, poolID int, userID int) error {
+	pool, err := s.repo.GetPool(ctx, poolID)
+	if err != nil {
+		return err
+	}
+	allowed, err := s.CanEdit(ctx, pool, userID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+func cleanPoolText(name string, description string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	description = strings.TrimSpace(strings.ReplaceAll(description, "\r\n", "\n"))
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", "", ErrInvalidName
+	}
+	if utf8.RuneCountInString(description) > maxDescriptionLength {
+		return "", "", ErrDescriptionLength
+	}
+	return name, description, nil
+}
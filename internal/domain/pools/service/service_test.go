@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"goserv/internal/domain/pools"
+	"goserv/internal/domain/pools/repository"
+	uRepo "goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolService_CreatePool(t *testing.T) {
+	type args struct {
+		name        string
+		description string
+	}
+	type want struct {
+		pool *pools.Pool
+		err  error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "trims name and description",
+			args: args{name: "  Japan 2023 ", description: "Tokyo\r\nKyoto\n"},
+			want: want{pool: &pools.Pool{Name: "Japan 2023", Description: "Tokyo\nKyoto", OwnerID: 3}, err: nil},
+		},
+		{
+			name: "blank name",
+			args: args{name: "   ", description: ""},
+			want: want{pool: nil, err: ErrInvalidName},
+		},
+		{
+			name: "name too long",
+			args: args{name: strings.Repeat("é", maxNameLength+1), description: ""},
+			want: want{pool: nil, err: ErrInvalidName},
+		},
+		{
+			name: "description too long",
+			args: args{name: "Trip", description: strings.Repeat("a", maxDescriptionLength+1)},
+			want: want{pool: nil, err: ErrDescriptionLength},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var created *pools.Pool
+			poolRepo := &repository.PoolMock{
+				CreatePoolFunc: func(ctx context.Context, pool *pools.Pool) (int, error) {
+					created = pool
+					return 1, nil
+				},
+			}
+
+			service := NewPoolService(poolRepo, nil)
+
+			_, err := service.CreatePool(context.Background(), test.args.name, test.args.description, 3)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.pool, created)
+		})
+	}
+}
+
+func TestPoolService_AddPost(t *testing.T) {
+	type args struct {
+		userID int
+		perms  []enum.Permission
+	}
+	type want struct {
+		added bool
+		err   error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{name: "owner", args: args{userID: 1, perms: nil}, want: want{added: true, err: nil}},
+		{name: "editor", args: args{userID: 2, perms: []enum.Permission{enum.PermPostEditAny}}, want: want{added: true, err: nil}},
+		{name: "someone else", args: args{userID: 2, perms: []enum.Permission{enum.PermPostCreate}}, want: want{added: false, err: ErrNotAllowed}},
+		{name: "logged out", args: args{userID: 0, perms: nil}, want: want{added: false, err: ErrNotAllowed}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			added := false
+			poolRepo := &repository.PoolMock{
+				GetPoolFunc: func(ctx context.Context, poolID int) (*pools.Pool, error) {
+					return &pools.Pool{ID: poolID, OwnerID: 1}, nil
+				},
+				AddPostFunc: func(ctx context.Context, poolID int, postID int) error {
+					added = true
+					return nil
+				},
+			}
+			userRepo := &uRepo.UserMock{
+				GetPermissionsFunc: func(ctx context.Context, userID int) ([]enum.Permission, error) {
+					return test.args.perms, nil
+				},
+			}
+
+			service := NewPoolService(poolRepo, userRepo)
+
+			err := service.AddPost(context.Background(), 5, 9, test.args.userID)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.added, added)
+		})
+	}
+}
+
+func TestPoolService_ReorderPosts(t *testing.T) {
+	type args struct {
+		postIDs []int
+	}
+	type want struct {
+		order []int
+		err   error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{name: "new order", args: args{postIDs: []int{3, 1, 2}}, want: want{order: []int{3, 1, 2}, err: nil}},
+		{name: "post twice", args: args{postIDs: []int{3, 1, 3}}, want: want{order: nil, err: ErrDuplicatePost}},
+		{name: "pool changed", args: args{postIDs: []int{1, 2}}, want: want{order: []int{1, 2}, err: pools.ErrOrderChanged}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var order []int
+			poolRepo := &repository.PoolMock{
+				GetPoolFunc: func(ctx context.Context, poolID int) (*pools.Pool, error) {
+					return &pools.Pool{ID: poolID, OwnerID: 1}, nil
+				},
+				ReorderPostsFunc: func(ctx context.Context, poolID int, postIDs []int) error {
+					order = postIDs
+					if len(postIDs) != 3 {
+						return pools.ErrOrderChanged
+					}
+					return nil
+				},
+			}
+
+			service := NewPoolService(poolRepo, nil)
+
+			err := service.ReorderPosts(context.Background(), 5, test.args.postIDs, 1)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.order, order)
+		})
+	}
+}
+
+func TestPoolService_DeletePool_NotFound(t *testing.T) {
+	deleted := false
+	poolRepo := &repository.PoolMock{
+		GetPoolFunc: func(ctx context.Context, poolID int) (*pools.Pool, error) {
+			return nil, myErrors.ErrNotFound
+		},
+		DeletePoolFunc: func(ctx context.Context, poolID int) error {
+			deleted = true
+			return nil
+		},
+	}
+
+	service := NewPoolService(poolRepo, nil)
+
+	err := service.DeletePool(context.Background(), 5, 1)
+	assert.ErrorIs(t, err, myErrors.ErrNotFound)
+	assert.False(t, deleted)
+}
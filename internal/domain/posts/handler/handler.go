@@ -1,43 +1,61 @@
 package handler
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"goserv/internal/domain/pools"
+	poolService "goserv/internal/domain/pools/service"
 	"goserv/internal/domain/posts"
+	"goserv/internal/domain/posts/search"
 	pService "goserv/internal/domain/posts/service"
 	"goserv/internal/domain/tags"
 	tService "goserv/internal/domain/tags/service"
+	uRepo "goserv/internal/domain/users/repository"
 	"goserv/internal/middleware"
 	"goserv/internal/static/constant"
 	"goserv/internal/static/enum"
 	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
 	"goserv/internal/utils/validate"
 	"html/template"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type ResponseEntry struct {
 	Filename string
 	FileExt  string
 	ID       int
+	Ready    bool
 }
 
 type PostHandler struct {
-	postSvc *pService.PostService
-	tagSvc  *tService.TagService
-	tmpl    *template.Template
+	postSvc  *pService.PostService
+	tagSvc   *tService.TagService
+	poolSvc  *poolService.PoolService
+	userRepo uRepo.User
+	tmpl     *template.Template
 }
 
 func NewPostHandler(
 	postSvc *pService.PostService,
 	tagSvc *tService.TagService,
+	poolSvc *poolService.PoolService,
+	userRepo uRepo.User,
 	tmpl *template.Template,
 ) *PostHandler {
 	return &PostHandler{
-		postSvc: postSvc,
-		tagSvc:  tagSvc,
-		tmpl:    tmpl,
+		postSvc:  postSvc,
+		tagSvc:   tagSvc,
+		poolSvc:  poolSvc,
+		userRepo: userRepo,
+		tmpl:     tmpl,
 	}
 }
 
@@ -63,9 +81,12 @@ func (h *PostHandler) ViewAddPost(w http.ResponseWriter, r *http.Request) {
 		TypeImage  string
 		TypeVideo  string
 		TypeAudio  string
+		TypeBook   string
 		ImageExts  []string
 		VideoExts  []string
 		AudioExts  []string
+		BookExts   []string
+		CSRF       string
 	}{
 		MediaTypes: enum.MediaType("").Values(),
 		GeneralTag: string(enum.TagGeneral),
@@ -75,9 +96,12 @@ func (h *PostHandler) ViewAddPost(w http.ResponseWriter, r *http.Request) {
 		TypeImage:  string(enum.MediaImage),
 		TypeVideo:  string(enum.MediaVideo),
 		TypeAudio:  string(enum.MediaAudio),
+		TypeBook:   string(enum.MediaBook),
 		ImageExts:  constant.GetImageExts(),
 		VideoExts:  constant.GetVideoExts(),
 		AudioExts:  constant.GetAudioExts(),
+		BookExts:   constant.GetBookExts(),
+		CSRF:       middleware.CSRFToken(r),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -99,14 +123,27 @@ func (h *PostHandler) AddPost(w http.ResponseWriter, r *http.Request) {
 
 	title := r.FormValue("title")
 	fileMedia := r.FormValue("media")
-	file, header, err := r.FormFile("file")
-	if err != nil {
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
 		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
 
-	if !validate.IsValidFileType(header.Filename, enum.MediaType(fileMedia)) {
+	// a book can also be uploaded as its page images, which are put in an archive for it
+	bookPages := false
+	if len(files) > 1 || enum.MediaType(fileMedia) == enum.MediaBook && !validate.IsValidFileType(files[0].Filename, enum.MediaBook) {
+		if enum.MediaType(fileMedia) != enum.MediaBook {
+			http.Error(w, "Only books can be uploaded as several files", http.StatusBadRequest)
+			return
+		}
+		for _, header := range files {
+			if !validate.IsValidFileType(header.Filename, enum.MediaImage) {
+				http.Error(w, "Book pages must be images", http.StatusBadRequest)
+				return
+			}
+		}
+		bookPages = true
+	} else if !validate.IsValidFileType(files[0].Filename, enum.MediaType(fileMedia)) {
 		http.Error(w, "Invalid file extension uploaded", http.StatusBadRequest)
 		return
 	}
@@ -117,9 +154,25 @@ func (h *PostHandler) AddPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post := &posts.Post{Title: title, MediaType: enum.MediaType(fileMedia), Filename: header.Filename, Tags: tags}
-	err = h.postSvc.AddPost(r.Context(), post, file, userID)
+	post := &posts.Post{Title: title, MediaType: enum.MediaType(fileMedia), Filename: files[0].Filename, Tags: tags}
+	var err error
+	if bookPages {
+		_, err = h.postSvc.AddBookPages(r.Context(), post, files, userID)
+	} else {
+		err = h.addPostFile(r, post, files[0], userID)
+	}
 	if err != nil {
+		var dupErr *posts.DuplicateError
+		if errors.As(err, &dupErr) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, `This file has already been uploaded. <a href="/view/posts/%d">View the existing post</a>`, dupErr.PostID)
+			return
+		}
+		if errors.Is(err, pService.ErrTooManyPages) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to add post", http.StatusInternalServerError)
 		return
 	}
@@ -127,20 +180,139 @@ func (h *PostHandler) AddPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/profile/uploads", http.StatusSeeOther)
 }
 
+func (h *PostHandler) addPostFile(r *http.Request, post *posts.Post, header *multipart.FileHeader, userID int) error {
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = h.postSvc.AddPost(r.Context(), post, file, userID)
+	return err
+}
+
+func (h *PostHandler) ViewEditPost(w http.ResponseWriter, r *http.Request) {
+	postID, ok := middleware.GetPostID(r)
+	if !ok {
+		http.Error(w, "Error reading post ID", http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.postSvc.GetPost(r.Context(), postID)
+	if err != nil {
+		http.Error(w, "Error getting post", http.StatusInternalServerError)
+		return
+	}
+
+	tagMap, err := h.tagSvc.SeperateTagTypes(r.Context(), post.Tags)
+	if err != nil {
+		http.Error(w, "Error handling tags", http.StatusInternalServerError)
+		return
+	}
+
+	tagList, err := h.tagSvc.ListTags(r.Context())
+	if err != nil {
+		http.Error(w, "Error getting tags", http.StatusInternalServerError)
+		return
+	}
+
+	peopleList, err := h.tagSvc.ListPeopleTags(r.Context())
+	if err != nil {
+		http.Error(w, "Error getting people", http.StatusInternalServerError)
+		return
+	}
+
+	currentTags, err := json.Marshal(tagMap[enum.TagGeneral])
+	if err != nil {
+		http.Error(w, "Error handling tags", http.StatusInternalServerError)
+		return
+	}
+
+	currentPeople, err := json.Marshal(tagMap[enum.TagPeople])
+	if err != nil {
+		http.Error(w, "Error handling tags", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "edit.html", struct {
+		ID            int
+		Title         string
+		MediaType     string
+		MediaTypes    []string
+		GeneralTag    string
+		PeopleTag     string
+		TagList       []tags.Tag
+		PeopleList    []tags.Tag
+		CurrentTags   string
+		CurrentPeople string
+		CSRF          string
+	}{
+		ID:            post.ID,
+		Title:         post.Title,
+		MediaType:     string(post.MediaType),
+		MediaTypes:    enum.MediaType("").Values(),
+		GeneralTag:    string(enum.TagGeneral),
+		PeopleTag:     string(enum.TagPeople),
+		TagList:       tagList,
+		PeopleList:    peopleList,
+		CurrentTags:   string(currentTags),
+		CurrentPeople: string(currentPeople),
+		CSRF:          middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *PostHandler) EditPost(w http.ResponseWriter, r *http.Request) {
+	postID, ok := middleware.GetPostID(r)
+	if !ok {
+		http.Error(w, "Error reading post ID", http.StatusBadRequest)
+		return
+	}
+
+	tags, ok := middleware.GetTags(r)
+	if !ok {
+		http.Error(w, "Error reading tags", http.StatusInternalServerError)
+		return
+	}
+
+	post, err := h.postSvc.GetPost(r.Context(), postID)
+	if err != nil {
+		http.Error(w, "Error getting post", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.postSvc.UpdatePost(r.Context(), post, r.FormValue("title"), enum.MediaType(r.FormValue("media")), tags)
+	if err != nil {
+		if errors.Is(err, pService.ErrInvalidMediaType) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update post", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/view/posts/"+strconv.Itoa(postID), http.StatusSeeOther)
+}
+
 func (h *PostHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := h.postSvc.ListPosts(r.Context())
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Error listing posts", http.StatusInternalServerError)
+		http.Error(w, "Invalid page request", http.StatusBadRequest)
 		return
 	}
 
-	content := make([]ResponseEntry, len(posts))
-	for i := range posts {
-		content[i] = ResponseEntry{
-			Filename: posts[i].Filename,
-			FileExt:  constant.ThumbnailExt,
-			ID:       posts[i].ID,
+	query := r.URL.Query().Get("q")
+	page, err := h.postSvc.ListPosts(r.Context(), query, pageReq)
+	if err != nil {
+		if errors.Is(err, search.ErrSyntax) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		http.Error(w, "Error listing posts", http.StatusInternalServerError)
+		return
 	}
 
 	isUser := false
@@ -151,10 +323,16 @@ func (h *PostHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
 
 	err = h.tmpl.ExecuteTemplate(w, "list.html", struct {
 		Posts  []ResponseEntry
+		Nav    pagination.Nav
 		IsUser bool
+		Query  string
+		Order  string
 	}{
-		Posts:  content,
+		Posts:  toResponseEntries(page.Items),
+		Nav:    pagination.NewNav(r.URL, page.Prev, page.Next),
 		IsUser: isUser,
+		Query:  query,
+		Order:  string(pageReq.Order),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -191,28 +369,82 @@ func (h *PostHandler) ViewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var pages []bookPage
+	startPage := 1
+	if post.MediaType == enum.MediaBook && post.Ready() {
+		pages, startPage, err = h.bookReader(r, post, userID)
+		if err != nil {
+			http.Error(w, "Error reading pages", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	memberships, err := h.poolSvc.ListMemberships(r.Context(), postID)
+	if err != nil {
+		http.Error(w, "Error getting pools", http.StatusInternalServerError)
+		return
+	}
+	var userPools []pools.Pool
+	canEdit := false
+	if isUser {
+		userPools, err = h.poolSvc.ListUserPools(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Error getting pools", http.StatusInternalServerError)
+			return
+		}
+		canEdit, err = middleware.CanModifyPost(r.Context(), h.userRepo, post, userID, enum.PermPostEditAny)
+		if err != nil {
+			http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	err = h.tmpl.ExecuteTemplate(w, "view.html", struct {
 		Filename  string
 		FileExt   string
 		ID        int
 		IsUser    bool
 		IsFav     bool
+		CanEdit   bool
+		State     string
 		People    []tags.Tag
 		Tags      []tags.Tag
 		Type      string
 		TypeImage string
 		TypeVideo string
+		TypeAudio string
+		TypeBook  string
+		Audio     audioDetails
+		Pages     []bookPage
+		StartPage int
+		Pools     []pools.Membership
+		UserPools []pools.Pool
+		Pending   string
+		Failed    string
+		CSRF      string
 	}{
 		Filename:  post.Filename,
 		FileExt:   post.FileExt[1:],
 		ID:        postID,
 		IsUser:    isUser,
 		IsFav:     isFav,
+		CanEdit:   canEdit,
+		State:     string(post.ProcessingState),
 		People:    tagMap[enum.TagPeople],
 		Tags:      tagMap[enum.TagGeneral],
 		Type:      string(post.MediaType),
 		TypeImage: string(enum.MediaImage),
 		TypeVideo: string(enum.MediaVideo),
+		TypeAudio: string(enum.MediaAudio),
+		TypeBook:  string(enum.MediaBook),
+		Audio:     toAudioDetails(post),
+		Pages:     pages,
+		StartPage: startPage,
+		Pools:     memberships,
+		UserPools: userPools,
+		Pending:   string(enum.ProcessingPending),
+		Failed:    string(enum.ProcessingFailed),
+		CSRF:      middleware.CSRFToken(r),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -226,23 +458,26 @@ func (h *PostHandler) ListUserPosts(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 	}
 
-	posts, err := h.postSvc.ListUserPosts(r.Context(), userID)
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Failed to list posts", http.StatusInternalServerError)
+		http.Error(w, "Invalid page request", http.StatusBadRequest)
 		return
 	}
 
-	content := make([]ResponseEntry, len(posts))
-	for i := range posts {
-		content[i] = ResponseEntry{
-			Filename: posts[i].Filename,
-			FileExt:  constant.ThumbnailExt,
-			ID:       posts[i].ID,
-		}
+	page, err := h.postSvc.ListUserPosts(r.Context(), userID, pageReq)
+	if err != nil {
+		http.Error(w, "Failed to list posts", http.StatusInternalServerError)
+		return
 	}
 
-	err = h.tmpl.ExecuteTemplate(w, "uploads.html", struct{ Posts []ResponseEntry }{
-		Posts: content,
+	err = h.tmpl.ExecuteTemplate(w, "uploads.html", struct {
+		Posts []ResponseEntry
+		Nav   pagination.Nav
+		CSRF  string
+	}{
+		Posts: toResponseEntries(page.Items),
+		Nav:   pagination.NewNav(r.URL, page.Prev, page.Next),
+		CSRF:  middleware.CSRFToken(r),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -256,23 +491,24 @@ func (h *PostHandler) ListUserFavs(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 	}
 
-	posts, err := h.postSvc.ListUserFavs(r.Context(), userID)
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Failed to list posts", http.StatusInternalServerError)
+		http.Error(w, "Invalid page request", http.StatusBadRequest)
 		return
 	}
 
-	content := make([]ResponseEntry, len(posts))
-	for i := range posts {
-		content[i] = ResponseEntry{
-			Filename: posts[i].Filename,
-			FileExt:  constant.ThumbnailExt,
-			ID:       posts[i].ID,
-		}
+	page, err := h.postSvc.ListUserFavs(r.Context(), userID, pageReq)
+	if err != nil {
+		http.Error(w, "Failed to list posts", http.StatusInternalServerError)
+		return
 	}
 
-	err = h.tmpl.ExecuteTemplate(w, "favourites.html", struct{ Posts []ResponseEntry }{
-		Posts: content,
+	err = h.tmpl.ExecuteTemplate(w, "favourites.html", struct {
+		Posts []ResponseEntry
+		Nav   pagination.Nav
+	}{
+		Posts: toResponseEntries(page.Items),
+		Nav:   pagination.NewNav(r.URL, page.Prev, page.Next),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -287,13 +523,17 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filename, fileExt, ok := middleware.GetCompleteFilename(r)
-	if !ok {
-		http.Error(w, "Error reading filename", http.StatusInternalServerError)
+	post, err := h.postSvc.GetPost(r.Context(), postID)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Error getting post", http.StatusInternalServerError)
 		return
 	}
 
-	err := h.postSvc.DeletePost(r.Context(), postID, filename, fileExt)
+	err = h.postSvc.DeletePost(r.Context(), post)
 	if err != nil {
 		http.Error(w, "Error deleting post", http.StatusInternalServerError)
 		return
@@ -341,3 +581,101 @@ func (h *PostHandler) UnfavouritePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type bookPage struct {
+	Number    int
+	Image     string
+	Thumbnail string
+}
+
+// bookReader lists a book's pages and the one to open it at, which is where the user left off.
+func (h *PostHandler) bookReader(r *http.Request, post *posts.Post, userID int) ([]bookPage, int, error) {
+	pages, err := h.postSvc.ListPages(r.Context(), post.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	startPage, err := h.postSvc.ReadingPosition(r.Context(), userID, post.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reader := make([]bookPage, len(pages))
+	for i, page := range pages {
+		name := posts.PageName(post.Hash(), page.Number)
+		reader[i] = bookPage{Number: page.Number, Image: name + page.FileExt, Thumbnail: name + constant.ThumbnailExt}
+	}
+	return reader, min(startPage, max(len(reader), 1)), nil
+}
+
+// SaveReadingPosition remembers the page of a book the user has reached, for the reader to open at next time.
+func (h *PostHandler) SaveReadingPosition(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil {
+		http.Error(w, "Invalid page", http.StatusBadRequest)
+		return
+	}
+
+	err = h.postSvc.SaveReadingPosition(r.Context(), userID, postID, page)
+	if err != nil {
+		if errors.Is(err, pService.ErrInvalidPage) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error saving reading position", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// audioDetails is an audio post's metadata ready for display, with anything unknown left empty.
+type audioDetails struct {
+	Title     string
+	Artist    string
+	Album     string
+	Duration  string
+	Bitrate   string
+	Thumbnail string
+}
+
+func toAudioDetails(post *posts.Post) audioDetails {
+	details := audioDetails{
+		Title:  post.Audio.Title,
+		Artist: post.Audio.Artist,
+		Album:  post.Audio.Album,
+	}
+	if post.Audio.Duration > 0 {
+		seconds := int(post.Audio.Duration.Round(time.Second).Seconds())
+		details.Duration = fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+	}
+	if post.Audio.Bitrate > 0 {
+		details.Bitrate = fmt.Sprintf("%d kbps", post.Audio.Bitrate/1000)
+	}
+	if post.Ready() {
+		details.Thumbnail = post.Filename + constant.ThumbnailExt
+	}
+	return details
+}
+
+func toResponseEntries(posts []posts.Post) []ResponseEntry {
+	content := make([]ResponseEntry, len(posts))
+	for i := range posts {
+		content[i] = ResponseEntry{
+			Filename: posts[i].Filename,
+			FileExt:  constant.ThumbnailExt,
+			ID:       posts[i].ID,
+			Ready:    posts[i].Ready(),
+		}
+	}
+	return content
+}
@@ -1,10 +1,34 @@
 package posts
 
 import (
+	"errors"
+	"fmt"
 	"goserv/internal/domain/tags"
+	"goserv/internal/static/constant"
 	"goserv/internal/static/enum"
+	"path"
+	"strings"
+	"time"
 )
 
+var ErrDuplicate = errors.New("content already uploaded")
+
+// DuplicateError is returned when an upload matches the content of an existing post.
+type DuplicateError struct {
+	PostID int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%v as post %d", ErrDuplicate, e.PostID)
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// HashLen is the length of the hex content hash every stored filename starts with.
+const HashLen = 64
+
 type Post struct {
 	ID        int
 	Title     string
@@ -13,5 +37,72 @@ type Post struct {
 	FileExt   string
 	OwnerID   int
 
+	ContentHash     string
+	ProcessingState enum.ProcessingState
+
+	// Audio is only filled in for audio posts, once they have been processed
+	Audio AudioMeta
+
 	Tags []tags.Tag
 }
+
+// AudioMeta is what could be read from an audio file's tags and stream. Anything missing is left zero.
+type AudioMeta struct {
+	Title    string
+	Artist   string
+	Album    string
+	Duration time.Duration
+	// Bitrate is in bits per second
+	Bitrate int
+}
+
+// Page is one image of a book post. Pages are numbered from 1 in reading order.
+type Page struct {
+	Number  int
+	FileExt string
+}
+
+// PageName is the stored name of a book page. Pages go by the content hash rather than the post's
+// filename, so renaming the post leaves them where they are.
+func PageName(hash string, number int) string {
+	return fmt.Sprintf("%s-%04d", hash, number)
+}
+
+// StorageName builds the stored filename for a post from its content hash and title.
+// Path separators are dropped from the title so the file always stays in its hash directory.
+func StorageName(hash string, title string) string {
+	title = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return -1
+		}
+		return r
+	}, title)
+	return hash + strings.ReplaceAll(title, "..", "")
+}
+
+// Ready reports whether the post's thumbnails have been made.
+func (p *Post) Ready() bool {
+	return p.ProcessingState == "" || p.ProcessingState == enum.ProcessingReady
+}
+
+func (p *Post) Hash() string {
+	return p.Filename[:HashLen]
+}
+
+// ContentKey is where a post's file is kept in blob storage, spread over directories by hash prefix.
+func ContentKey(filename string, fileExt string) string {
+	return path.Join("content", filename[0:2], filename[2:4], filename+fileExt)
+}
+
+func ThumbnailKey(filename string) string {
+	return path.Join("thumbnails", filename[0:2], filename[2:4], filename+constant.ThumbnailExt)
+}
+
+func PageKey(hash string, number int, fileExt string) string {
+	name := PageName(hash, number)
+	return path.Join("pages", name[0:2], name[2:4], name+fileExt)
+}
+
+func PageThumbnailKey(hash string, number int) string {
+	return ThumbnailKey(PageName(hash, number))
+}
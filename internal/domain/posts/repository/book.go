@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"goserv/ent/gen"
+	entPage "goserv/ent/gen/page"
+	entProgress "goserv/ent/gen/readingprogress"
+	"goserv/internal/domain/posts"
+	"goserv/internal/utils/errors"
+)
+
+// ListPages returns a book post's pages in reading order.
+func (repo *postRepository) ListPages(ctx context.Context, postID int) ([]posts.Page, error) {
+	entPages, err := repo.client.Page.Query().Where(entPage.PostIDEQ(postID)).Order(entPage.ByNumber()).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]posts.Page, len(entPages))
+	for i := range entPages {
+		pages[i] = posts.Page{Number: entPages[i].Number, FileExt: entPages[i].FileExt}
+	}
+	return pages, nil
+}
+
+// SetPages replaces a book post's pages, so processing the post again does not leave old pages behind.
+func (repo *postRepository) SetPages(ctx context.Context, postID int, pages []posts.Page) error {
+	tx, err := repo.client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Page.Delete().Where(entPage.PostIDEQ(postID)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	creates := make([]*gen.PageCreate, len(pages))
+	for i := range pages {
+		creates[i] = tx.Page.Create().SetPostID(postID).SetNumber(pages[i].Number).SetFileExt(pages[i].FileExt)
+	}
+	if err := tx.Page.CreateBulk(creates...).Exec(ctx); err != nil {
+		tx.Rollback()
+		if gen.IsConstraintError(err) {
+			// the post was deleted while it was being processed
+			return errors.ErrNotFound
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (repo *postRepository) GetReadingProgress(ctx context.Context, userID int, postID int) (int, error) {
+	progress, err := repo.client.ReadingProgress.
+		Query().
+		Where(entProgress.UserIDEQ(userID), entProgress.PostIDEQ(postID)).
+		Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return 0, errors.ErrNotFound
+		}
+		return 0, err
+	}
+	return progress.Page, nil
+}
+
+// SaveReadingProgress records the page the user is on, creating the row the first time they read the post.
+func (repo *postRepository) SaveReadingProgress(ctx context.Context, userID int, postID int, page int) error {
+	updated, err := repo.client.ReadingProgress.
+		Update().
+		Where(entProgress.UserIDEQ(userID), entProgress.PostIDEQ(postID)).
+		SetPage(page).
+		Save(ctx)
+	if err != nil || updated > 0 {
+		return err
+	}
+
+	err = repo.client.ReadingProgress.
+		Create().
+		SetUserID(userID).
+		SetPostID(postID).
+		SetPage(page).
+		Exec(ctx)
+	if gen.IsConstraintError(err) {
+		// either another tab saved first, which is as good as this page, or the post is gone
+		return nil
+	}
+	return err
+}
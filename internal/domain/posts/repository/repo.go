@@ -2,25 +2,41 @@ package repository
 
 import (
 	"context"
+	stdErrors "errors"
 	"goserv/ent/gen"
 	entPost "goserv/ent/gen/post"
 	entUser "goserv/ent/gen/user"
 	"goserv/internal/domain/posts"
+	"goserv/internal/domain/posts/search"
 	"goserv/internal/domain/tags"
 	"goserv/internal/static/enum"
 	"goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	"github.com/lib/pq"
 )
 
 type Post interface {
 	AddPost(ctx context.Context, post *posts.Post, userID int) (int, error)
 	DeletePost(ctx context.Context, postID int) error
 	GetPost(ctx context.Context, postID int) (*posts.Post, error)
-	ListPosts(ctx context.Context) ([]posts.Post, error)
-	ListUserPosts(ctx context.Context, userID int) ([]posts.Post, error)
-	ListUserFavs(ctx context.Context, userID int) ([]posts.Post, error)
+	ListPosts(ctx context.Context, query search.Node, page pagination.PageRequest) (pagination.Page[posts.Post], error)
+	ListUserPosts(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error)
+	ListUserFavs(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error)
+	CountUserPosts(ctx context.Context, userID int) (int, error)
 	FavouritePost(ctx context.Context, postID int, userID int) error
 	UnfavouritePost(ctx context.Context, postID int, userID int) error
 	GetPostWithFavouriteStatus(ctx context.Context, postID int, userID int) (*posts.Post, bool, error)
+	UpdatePost(ctx context.Context, post *posts.Post) error
+	GetPostByHash(ctx context.Context, contentHash string) (*posts.Post, error)
+	SetProcessingState(ctx context.Context, postID int, state enum.ProcessingState) error
+	SetAudioMeta(ctx context.Context, postID int, meta posts.AudioMeta) error
+	ListPages(ctx context.Context, postID int) ([]posts.Page, error)
+	SetPages(ctx context.Context, postID int, pages []posts.Page) error
+	GetReadingProgress(ctx context.Context, userID int, postID int) (int, error)
+	SaveReadingProgress(ctx context.Context, userID int, postID int, page int) error
 }
 
 type postRepository struct {
@@ -44,14 +60,99 @@ func (repo *postRepository) AddPost(ctx context.Context, post *posts.Post, userI
 		SetFilename(post.Filename).
 		SetFileExt(post.FileExt).
 		SetOwnerID(userID).
+		SetNillableContentHash(nilIfEmpty(post.ContentHash)).
+		SetNillableProcessingState(nilIfEmptyState(post.ProcessingState)).
 		AddTagIDs(tagIDs...).
 		Save(ctx)
 	if err != nil {
+		if isDuplicatePost(err) {
+			return 0, posts.ErrDuplicate
+		}
 		return 0, err
 	}
 	return savedPost.ID, nil
 }
 
+// isDuplicatePost reports whether err is a post's filename or content hash already being taken. The
+// filename starts with the content hash, so either unique index failing means the same content. Other
+// constraint errors, such as the owner having been deleted, are not duplicates.
+func isDuplicatePost(err error) bool {
+	var pqErr *pq.Error
+	if !gen.IsConstraintError(err) || !stdErrors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "23505" && (pqErr.Constraint == "posts_filename_key" || pqErr.Constraint == "posts_content_hash_key")
+}
+
+func (repo *postRepository) GetPostByHash(ctx context.Context, contentHash string) (*posts.Post, error) {
+	post, err := repo.client.Post.Query().Where(entPost.ContentHashEQ(contentHash)).Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return &toDomainPosts([]*gen.Post{post})[0], nil
+}
+
+func nilIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func nilIfEmptyState(state enum.ProcessingState) *entPost.ProcessingState {
+	if state == "" {
+		return nil
+	}
+	entState := entPost.ProcessingState(state)
+	return &entState
+}
+
+func (repo *postRepository) SetProcessingState(ctx context.Context, postID int, state enum.ProcessingState) error {
+	err := repo.client.Post.UpdateOneID(postID).SetProcessingState(entPost.ProcessingState(state)).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func (repo *postRepository) SetAudioMeta(ctx context.Context, postID int, meta posts.AudioMeta) error {
+	err := repo.client.Post.
+		UpdateOneID(postID).
+		SetAudioTitle(meta.Title).
+		SetArtist(meta.Artist).
+		SetAlbum(meta.Album).
+		SetDurationMs(meta.Duration.Milliseconds()).
+		SetBitrate(meta.Bitrate).
+		Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func (repo *postRepository) UpdatePost(ctx context.Context, post *posts.Post) error {
+	tagIDs := make([]int, len(post.Tags))
+	for i := range post.Tags {
+		tagIDs[i] = post.Tags[i].ID
+	}
+
+	err := repo.client.Post.
+		UpdateOneID(post.ID).
+		SetTitle(post.Title).
+		SetMediaType(entPost.MediaType(post.MediaType)).
+		SetFilename(post.Filename).
+		ClearTags().
+		AddTagIDs(tagIDs...).
+		Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
 func (repo *postRepository) DeletePost(ctx context.Context, postID int) error {
 	return repo.client.Post.DeleteOneID(postID).Exec(ctx)
 }
@@ -83,31 +184,71 @@ func (repo *postRepository) GetPost(ctx context.Context, postID int) (*posts.Pos
 		FileExt:   post.FileExt,
 		OwnerID:   post.UserOwns,
 
+		ProcessingState: enum.ProcessingState(post.ProcessingState),
+
+		Audio: toDomainAudio(post),
+
 		Tags: domainTags,
 	}
 	return result, nil
 }
 
-func (repo *postRepository) ListPosts(ctx context.Context) ([]posts.Post, error) {
-	entPosts, err := repo.client.Post.Query().All(ctx)
+func (repo *postRepository) ListPosts(ctx context.Context, query search.Node, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	postQuery := repo.client.Post.Query()
+	if query != nil {
+		pred, err := searchPredicate(query)
+		if err != nil {
+			return pagination.Page[posts.Post]{}, err
+		}
+		postQuery = postQuery.Where(pred)
+	}
+	return listPage(ctx, postQuery, page)
+}
+
+func (repo *postRepository) ListUserPosts(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	return listPage(ctx, repo.client.User.Query().Where(entUser.IDEQ(userID)).QueryOwns(), page)
+}
+
+func (repo *postRepository) ListUserFavs(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	return listPage(ctx, repo.client.User.Query().Where(entUser.IDEQ(userID)).QueryFavourites(), page)
+}
+
+func (repo *postRepository) CountUserPosts(ctx context.Context, userID int) (int, error) {
+	return repo.client.Post.Query().Where(entPost.UserOwnsEQ(userID)).Count(ctx)
+}
+
+func listPage(ctx context.Context, query *gen.PostQuery, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	page = page.Normalize()
+	cursor, hasCursor, err := page.Cursor()
 	if err != nil {
-		return nil, err
+		return pagination.Page[posts.Post]{}, err
 	}
-	returnPosts := make([]posts.Post, len(entPosts))
-	for i := range entPosts {
-		returnPosts[i] = posts.Post{
-			ID:        entPosts[i].ID,
-			Title:     entPosts[i].Title,
-			MediaType: enum.MediaType(entPosts[i].MediaType),
-			Filename:  entPosts[i].Filename,
-			FileExt:   entPosts[i].FileExt,
+
+	ascending := page.Ascending(cursor)
+	if hasCursor {
+		if ascending {
+			query = query.Where(entPost.IDGT(cursor.ID))
+		} else {
+			query = query.Where(entPost.IDLT(cursor.ID))
 		}
 	}
-	return returnPosts, err
+	if ascending {
+		query = query.Order(entPost.ByID())
+	} else {
+		query = query.Order(entPost.ByID(sql.OrderDesc()))
+	}
+
+	entPosts, err := query.Limit(page.Limit + 1).All(ctx)
+	if err != nil {
+		return pagination.Page[posts.Post]{}, err
+	}
+
+	return pagination.NewPage(toDomainPosts(entPosts), page, cursor, hasCursor, func(post posts.Post) int {
+		return post.ID
+	}), nil
 }
 
-func (repo *postRepository) ListUserPosts(ctx context.Context, userID int) ([]posts.Post, error) {
-	entPosts, err := repo.client.User.Query().Where(entUser.IDEQ(userID)).QueryOwns().All(ctx)
+func toDomainPosts(entPosts []*gen.Post) []posts.Post {
 	returnPosts := make([]posts.Post, len(entPosts))
 	for i := range entPosts {
 		returnPosts[i] = posts.Post{
@@ -116,24 +257,27 @@ func (repo *postRepository) ListUserPosts(ctx context.Context, userID int) ([]po
 			MediaType: enum.MediaType(entPosts[i].MediaType),
 			Filename:  entPosts[i].Filename,
 			FileExt:   entPosts[i].FileExt,
+			OwnerID:   entPosts[i].UserOwns,
+
+			ProcessingState: enum.ProcessingState(entPosts[i].ProcessingState),
+
+			Audio: toDomainAudio(entPosts[i]),
+		}
+		if entPosts[i].ContentHash != nil {
+			returnPosts[i].ContentHash = *entPosts[i].ContentHash
 		}
 	}
-	return returnPosts, err
+	return returnPosts
 }
 
-func (repo *postRepository) ListUserFavs(ctx context.Context, userID int) ([]posts.Post, error) {
-	entPosts, err := repo.client.User.Query().Where(entUser.IDEQ(userID)).QueryFavourites().All(ctx)
-	returnPosts := make([]posts.Post, len(entPosts))
-	for i := range entPosts {
-		returnPosts[i] = posts.Post{
-			ID:        entPosts[i].ID,
-			Title:     entPosts[i].Title,
-			MediaType: enum.MediaType(entPosts[i].MediaType),
-			Filename:  entPosts[i].Filename,
-			FileExt:   entPosts[i].FileExt,
-		}
+func toDomainAudio(post *gen.Post) posts.AudioMeta {
+	return posts.AudioMeta{
+		Title:    post.AudioTitle,
+		Artist:   post.Artist,
+		Album:    post.Album,
+		Duration: time.Duration(post.DurationMs) * time.Millisecond,
+		Bitrate:  post.Bitrate,
 	}
-	return returnPosts, err
 }
 
 func (repo *postRepository) FavouritePost(ctx context.Context, postID int, userID int) error {
@@ -177,6 +321,10 @@ func (repo *postRepository) GetPostWithFavouriteStatus(ctx context.Context, post
 		FileExt:   post.FileExt,
 		OwnerID:   post.UserOwns,
 
+		ProcessingState: enum.ProcessingState(post.ProcessingState),
+
+		Audio: toDomainAudio(post),
+
 		Tags: domainTags,
 	}
 	return result, len(post.Edges.FavouritedBy) > 0, nil
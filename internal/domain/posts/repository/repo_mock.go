@@ -3,18 +3,30 @@ package repository
 import (
 	"context"
 	"goserv/internal/domain/posts"
+	"goserv/internal/domain/posts/search"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/pagination"
 )
 
 type PostMock struct {
 	AddPostFunc                    func(ctx context.Context, post *posts.Post, userID int) (int, error)
 	DeletePostFunc                 func(ctx context.Context, postID int) error
 	GetPostFunc                    func(ctx context.Context, postID int) (*posts.Post, error)
-	ListPostsFunc                  func(ctx context.Context) ([]posts.Post, error)
-	ListUserPostsFunc              func(ctx context.Context, userID int) ([]posts.Post, error)
-	ListUserFavsFunc               func(ctx context.Context, userID int) ([]posts.Post, error)
+	ListPostsFunc                  func(ctx context.Context, query search.Node, page pagination.PageRequest) (pagination.Page[posts.Post], error)
+	ListUserPostsFunc              func(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error)
+	ListUserFavsFunc               func(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error)
+	CountUserPostsFunc             func(ctx context.Context, userID int) (int, error)
 	FavouritePostFunc              func(ctx context.Context, postID int, userID int) error
 	UnfavouritePostFunc            func(ctx context.Context, postID int, userID int) error
 	GetPostWithFavouriteStatusFunc func(ctx context.Context, postID int, userID int) (*posts.Post, bool, error)
+	UpdatePostFunc                 func(ctx context.Context, post *posts.Post) error
+	GetPostByHashFunc              func(ctx context.Context, contentHash string) (*posts.Post, error)
+	SetProcessingStateFunc         func(ctx context.Context, postID int, state enum.ProcessingState) error
+	SetAudioMetaFunc               func(ctx context.Context, postID int, meta posts.AudioMeta) error
+	ListPagesFunc                  func(ctx context.Context, postID int) ([]posts.Page, error)
+	SetPagesFunc                   func(ctx context.Context, postID int, pages []posts.Page) error
+	GetReadingProgressFunc         func(ctx context.Context, userID int, postID int) (int, error)
+	SaveReadingProgressFunc        func(ctx context.Context, userID int, postID int, page int) error
 }
 
 func (m *PostMock) AddPost(ctx context.Context, post *posts.Post, userID int) (int, error) {
@@ -29,16 +41,20 @@ func (m *PostMock) GetPost(ctx context.Context, postID int) (*posts.Post, error)
 	return m.GetPostFunc(ctx, postID)
 }
 
-func (m *PostMock) ListPosts(ctx context.Context) ([]posts.Post, error) {
-	return m.ListPostsFunc(ctx)
+func (m *PostMock) ListPosts(ctx context.Context, query search.Node, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	return m.ListPostsFunc(ctx, query, page)
 }
 
-func (m *PostMock) ListUserPosts(ctx context.Context, userID int) ([]posts.Post, error) {
-	return m.ListUserPostsFunc(ctx, userID)
+func (m *PostMock) ListUserPosts(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	return m.ListUserPostsFunc(ctx, userID, page)
 }
 
-func (m *PostMock) ListUserFavs(ctx context.Context, userID int) ([]posts.Post, error) {
-	return m.ListUserFavsFunc(ctx, userID)
+func (m *PostMock) ListUserFavs(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	return m.ListUserFavsFunc(ctx, userID, page)
+}
+
+func (m *PostMock) CountUserPosts(ctx context.Context, userID int) (int, error) {
+	return m.CountUserPostsFunc(ctx, userID)
 }
 
 func (m *PostMock) FavouritePost(ctx context.Context, postID int, userID int) error {
@@ -52,3 +68,35 @@ func (m *PostMock) UnfavouritePost(ctx context.Context, postID int, userID int)
 func (m *PostMock) GetPostWithFavouriteStatus(ctx context.Context, postID int, userID int) (*posts.Post, bool, error) {
 	return m.GetPostWithFavouriteStatusFunc(ctx, postID, userID)
 }
+
+func (m *PostMock) UpdatePost(ctx context.Context, post *posts.Post) error {
+	return m.UpdatePostFunc(ctx, post)
+}
+
+func (m *PostMock) GetPostByHash(ctx context.Context, contentHash string) (*posts.Post, error) {
+	return m.GetPostByHashFunc(ctx, contentHash)
+}
+
+func (m *PostMock) SetProcessingState(ctx context.Context, postID int, state enum.ProcessingState) error {
+	return m.SetProcessingStateFunc(ctx, postID, state)
+}
+
+func (m *PostMock) SetAudioMeta(ctx context.Context, postID int, meta posts.AudioMeta) error {
+	return m.SetAudioMetaFunc(ctx, postID, meta)
+}
+
+func (m *PostMock) ListPages(ctx context.Context, postID int) ([]posts.Page, error) {
+	return m.ListPagesFunc(ctx, postID)
+}
+
+func (m *PostMock) SetPages(ctx context.Context, postID int, pages []posts.Page) error {
+	return m.SetPagesFunc(ctx, postID, pages)
+}
+
+func (m *PostMock) GetReadingProgress(ctx context.Context, userID int, postID int) (int, error) {
+	return m.GetReadingProgressFunc(ctx, userID, postID)
+}
+
+func (m *PostMock) SaveReadingProgress(ctx context.Context, userID int, postID int, page int) error {
+	return m.SaveReadingProgressFunc(ctx, userID, postID, page)
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"fmt"
+	entPost "goserv/ent/gen/post"
+	"goserv/ent/gen/predicate"
+	entTag "goserv/ent/gen/tag"
+	"goserv/internal/domain/posts/search"
+)
+
+func searchPredicate(node search.Node) (predicate.Post, error) {
+	switch n := node.(type) {
+	case search.And:
+		preds, err := searchPredicates(n.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		return entPost.And(preds...), nil
+	case search.Or:
+		preds, err := searchPredicates(n.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		return entPost.Or(preds...), nil
+	case search.Not:
+		pred, err := searchPredicate(n.Node)
+		if err != nil {
+			return nil, err
+		}
+		return entPost.Not(pred), nil
+	case search.Tag:
+		tagPreds := []predicate.Tag{entTag.NameEqualFold(n.Name)}
+		if n.Type != "" {
+			tagPreds = append(tagPreds, entTag.TagTypeEQ(entTag.TagType(n.Type)))
+		}
+		return entPost.HasTagsWith(tagPreds...), nil
+	case search.Title:
+		return entPost.TitleContainsFold(n.Text), nil
+	default:
+		return nil, fmt.Errorf("unsupported search node %T", node)
+	}
+}
+
+func searchPredicates(nodes []search.Node) ([]predicate.Post, error) {
+	preds := make([]predicate.Post, len(nodes))
+	for i := range nodes {
+		pred, err := searchPredicate(nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		preds[i] = pred
+	}
+	return preds, nil
+}
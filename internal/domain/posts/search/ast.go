@@ -0,0 +1,35 @@
+package search
+
+import "goserv/internal/static/enum"
+
+type Node interface {
+	node()
+}
+
+type And struct {
+	Nodes []Node
+}
+
+type Or struct {
+	Nodes []Node
+}
+
+type Not struct {
+	Node Node
+}
+
+// Tag matches posts tagged with Name. An empty Type matches a tag of any type.
+type Tag struct {
+	Name string
+	Type enum.TagType
+}
+
+type Title struct {
+	Text string
+}
+
+func (And) node()   {}
+func (Or) node()    {}
+func (Not) node()   {}
+func (Tag) node()   {}
+func (Title) node() {}
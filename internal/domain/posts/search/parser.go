@@ -0,0 +1,280 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"goserv/internal/static/enum"
+	"strings"
+	"unicode"
+)
+
+var ErrSyntax = errors.New("invalid search query")
+
+const (
+	qualifierPeople  = "people"
+	qualifierGeneral = "general"
+	qualifierTitle   = "title"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokNot
+	tokAnd
+	tokOr
+	tokTerm
+)
+
+type token struct {
+	kind      tokenKind
+	qualifier string
+	text      string
+	pos       int
+}
+
+// Parse turns a booru style query such as `beach people:alice -night (sunset OR dusk)`
+// into a tree of nodes. An empty query returns a nil node, which matches every post.
+func Parse(query string) (Node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrSyntax, describe(tok), tok.pos)
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []Node{first}
+	for p.peek().kind == tokOr {
+		p.next()
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return Or{Nodes: nodes}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []Node{first}
+	for {
+		switch p.peek().kind {
+		case tokEOF, tokRParen, tokOr:
+			if len(nodes) == 1 {
+				return first, nil
+			}
+			return And{Nodes: nodes}, nil
+		case tokAnd:
+			p.next()
+		}
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("%w: missing closing parenthesis for position %d", ErrSyntax, tok.pos)
+		}
+		return node, nil
+	case tokTerm:
+		return termNode(tok)
+	case tokEOF:
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrSyntax)
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrSyntax, describe(tok), tok.pos)
+	}
+}
+
+func termNode(tok token) (Node, error) {
+	if tok.text == "" {
+		return nil, fmt.Errorf("%w: empty term at position %d", ErrSyntax, tok.pos)
+	}
+
+	switch tok.qualifier {
+	case qualifierPeople:
+		return Tag{Name: tok.text, Type: enum.TagPeople}, nil
+	case qualifierGeneral:
+		return Tag{Name: tok.text, Type: enum.TagGeneral}, nil
+	case qualifierTitle:
+		return Title{Text: tok.text}, nil
+	default:
+		return Tag{Name: tok.text}, nil
+	}
+}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, pos: i})
+			i++
+		case r == '-':
+			if i+1 >= len(runes) || unicode.IsSpace(runes[i+1]) || runes[i+1] == ')' {
+				return nil, fmt.Errorf("%w: dangling '-' at position %d", ErrSyntax, i)
+			}
+			tokens = append(tokens, token{kind: tokNot, pos: i})
+			i++
+		default:
+			tok, end, err := lexTerm(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = end
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(runes)}), nil
+}
+
+func lexTerm(runes []rune, start int) (token, int, error) {
+	var text strings.Builder
+	quoteAt := -1
+
+	i := start
+	for i < len(runes) {
+		r := runes[i]
+		if unicode.IsSpace(r) || r == '(' || r == ')' {
+			break
+		}
+
+		if r == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return token{}, 0, fmt.Errorf("%w: unterminated quote at position %d", ErrSyntax, i)
+			}
+			if quoteAt == -1 {
+				quoteAt = text.Len()
+			}
+			text.WriteString(string(runes[i+1 : end]))
+			i = end + 1
+			continue
+		}
+
+		text.WriteRune(r)
+		i++
+	}
+
+	raw := text.String()
+	if quoteAt == -1 {
+		switch raw {
+		case "OR":
+			return token{kind: tokOr, pos: start}, i, nil
+		case "AND":
+			return token{kind: tokAnd, pos: start}, i, nil
+		}
+	}
+
+	tok := token{kind: tokTerm, text: raw, pos: start}
+	if prefix, rest, ok := strings.Cut(raw, ":"); ok && (quoteAt == -1 || len(prefix) < quoteAt) {
+		switch qualifier := strings.ToLower(prefix); qualifier {
+		case qualifierPeople, qualifierGeneral, qualifierTitle:
+			tok.qualifier = qualifier
+			tok.text = rest
+		}
+	}
+	return tok, i, nil
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	case tokNot:
+		return "-"
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokEOF:
+		return "end of query"
+	default:
+		return tok.text
+	}
+}
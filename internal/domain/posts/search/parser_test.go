@@ -0,0 +1,132 @@
+package search
+
+import (
+	"goserv/internal/static/enum"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	type args struct {
+		query string
+	}
+	type want struct {
+		node Node
+		err  error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "empty query",
+			args: args{query: "   "},
+			want: want{node: nil, err: nil},
+		},
+		{
+			name: "single tag",
+			args: args{query: "beach"},
+			want: want{node: Tag{Name: "beach"}, err: nil},
+		},
+		{
+			name: "qualified tags",
+			args: args{query: "people:alice general:sunset"},
+			want: want{
+				node: And{Nodes: []Node{
+					Tag{Name: "alice", Type: enum.TagPeople},
+					Tag{Name: "sunset", Type: enum.TagGeneral},
+				}},
+				err: nil,
+			},
+		},
+		{
+			name: "title with quotes",
+			args: args{query: `title:"summer trip"`},
+			want: want{node: Title{Text: "summer trip"}, err: nil},
+		},
+		{
+			name: "quoted keyword is a tag",
+			args: args{query: `"OR"`},
+			want: want{node: Tag{Name: "OR"}, err: nil},
+		},
+		{
+			name: "full expression",
+			args: args{query: "beach people:alice -night (sunset OR dusk)"},
+			want: want{
+				node: And{Nodes: []Node{
+					Tag{Name: "beach"},
+					Tag{Name: "alice", Type: enum.TagPeople},
+					Not{Node: Tag{Name: "night"}},
+					Or{Nodes: []Node{Tag{Name: "sunset"}, Tag{Name: "dusk"}}},
+				}},
+				err: nil,
+			},
+		},
+		{
+			name: "or binds looser than and",
+			args: args{query: "a b OR c AND d"},
+			want: want{
+				node: Or{Nodes: []Node{
+					And{Nodes: []Node{Tag{Name: "a"}, Tag{Name: "b"}}},
+					And{Nodes: []Node{Tag{Name: "c"}, Tag{Name: "d"}}},
+				}},
+				err: nil,
+			},
+		},
+		{
+			name: "negated group",
+			args: args{query: "-(night OR dark)"},
+			want: want{
+				node: Not{Node: Or{Nodes: []Node{Tag{Name: "night"}, Tag{Name: "dark"}}}},
+				err:  nil,
+			},
+		},
+		{
+			name: "unknown qualifier is part of the tag",
+			args: args{query: "time:12"},
+			want: want{node: Tag{Name: "time:12"}, err: nil},
+		},
+		{
+			name: "missing closing parenthesis",
+			args: args{query: "(beach"},
+			want: want{node: nil, err: ErrSyntax},
+		},
+		{
+			name: "unexpected closing parenthesis",
+			args: args{query: "beach)"},
+			want: want{node: nil, err: ErrSyntax},
+		},
+		{
+			name: "dangling or",
+			args: args{query: "beach OR"},
+			want: want{node: nil, err: ErrSyntax},
+		},
+		{
+			name: "dangling negation",
+			args: args{query: "beach -"},
+			want: want{node: nil, err: ErrSyntax},
+		},
+		{
+			name: "unterminated quote",
+			args: args{query: `title:"summer`},
+			want: want{node: nil, err: ErrSyntax},
+		},
+		{
+			name: "empty qualified term",
+			args: args{query: "people:"},
+			want: want{node: nil, err: ErrSyntax},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, err := Parse(test.args.query)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.node, node)
+		})
+	}
+}
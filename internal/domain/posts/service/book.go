@@ -0,0 +1,254 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"goserv/internal/domain/posts"
+	"goserv/internal/static/constant"
+	"goserv/internal/utils"
+	myErrors "goserv/internal/utils/errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrNoPages = errors.New("archive has no images to use as pages")
+var ErrTooManyPages = fmt.Errorf("a book can have at most %d pages", maxBookPages)
+var ErrPageTooLarge = fmt.Errorf("a page can be at most %d MB", maxPageSize>>20)
+var ErrInvalidPage = errors.New("page is not in this book")
+
+const maxBookPages = 2000
+const maxPageSize = 100 << 20
+
+// AddBookPages uploads images as a book. They are packed in upload order into a CBZ archive, which is
+// stored as the post's content and split into pages when the post is processed.
+func (s *PostService) AddBookPages(ctx context.Context, post *posts.Post, pages []*multipart.FileHeader, userID int) (int, error) {
+	if len(pages) > maxBookPages {
+		return 0, ErrTooManyPages
+	}
+
+	archive, err := os.CreateTemp("tmp", "book-*.cbz")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	if err := writeBookArchive(archive, pages); err != nil {
+		return 0, err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	post.Filename = filepath.Base(archive.Name())
+	return s.AddPost(ctx, post, archive, userID)
+}
+
+// writeBookArchive numbers the pages in the order given. Nothing about the upload goes in the archive
+// besides the images, so the same images always give the same content hash.
+func writeBookArchive(w io.Writer, pages []*multipart.FileHeader) error {
+	archive := zip.NewWriter(w)
+	for i, header := range pages {
+		page, err := header.Open()
+		if err != nil {
+			return err
+		}
+
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:   fmt.Sprintf("%04d%s", i+1, strings.ToLower(filepath.Ext(header.Filename))),
+			Method: zip.Store,
+		})
+		if err == nil {
+			_, err = io.Copy(entry, page)
+		}
+		page.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// processBook stores every image in the book's archive as a page with its own thumbnail. The first
+// page's thumbnail also becomes the post's.
+func (s *PostService) processBook(ctx context.Context, post *posts.Post, srcPath string, thumbnailPath string) error {
+	archive, err := zip.OpenReader(srcPath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	entries := bookEntries(archive.File)
+	if len(entries) == 0 {
+		return ErrNoPages
+	}
+	if len(entries) > maxBookPages {
+		return ErrTooManyPages
+	}
+
+	pages := make([]posts.Page, len(entries))
+	for i := range entries {
+		pages[i] = posts.Page{Number: i + 1, FileExt: strings.ToLower(path.Ext(entries[i].Name))}
+	}
+	// saved first so deleting the post finds pages stored by an attempt that failed part way
+	if err := s.repo.SetPages(ctx, post.ID, pages); err != nil {
+		return err
+	}
+
+	for i := range entries {
+		pageThumbnail := ""
+		if i == 0 {
+			pageThumbnail = thumbnailPath
+		}
+		if err := s.storePage(ctx, post.Hash(), pages[i], entries[i], pageThumbnail); err != nil {
+			return fmt.Errorf("page %d: %w", pages[i].Number, err)
+		}
+	}
+	return nil
+}
+
+// storePage puts a page and its thumbnail in storage. The thumbnail is left at thumbnailPath when one is
+// given, otherwise it is made in a temporary file.
+func (s *PostService) storePage(ctx context.Context, hash string, page posts.Page, entry *zip.File, thumbnailPath string) error {
+	tempFile, err := os.CreateTemp("tmp", "page-*"+page.FileExt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	content, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	// the size in the archive's directory cannot be trusted, so stop reading past the limit
+	written, err := io.Copy(tempFile, io.LimitReader(content, maxPageSize+1))
+	content.Close()
+	tempFile.Close()
+	if err != nil {
+		return err
+	}
+	if written > maxPageSize {
+		return ErrPageTooLarge
+	}
+
+	if thumbnailPath == "" {
+		thumbnailPath = tempFile.Name() + constant.ThumbnailExt
+		defer os.Remove(thumbnailPath)
+	}
+	if err := utils.CreateImageThumbnail(tempFile.Name(), thumbnailPath); err != nil {
+		return err
+	}
+
+	if err := s.putFile(ctx, posts.PageKey(hash, page.Number, page.FileExt), tempFile.Name()); err != nil {
+		return err
+	}
+	return s.putFile(ctx, posts.PageThumbnailKey(hash, page.Number), thumbnailPath)
+}
+
+// bookEntries picks the images out of an archive in page order, skipping directories and the hidden
+// files some archivers add.
+func bookEntries(files []*zip.File) []*zip.File {
+	var entries []*zip.File
+	for _, file := range files {
+		name := file.Name
+		if file.FileInfo().IsDir() || strings.HasPrefix(path.Base(name), ".") || strings.Contains(name, "__MACOSX/") {
+			continue
+		}
+		if !slices.Contains(constant.GetImageExts(), strings.ToLower(path.Ext(name))) {
+			continue
+		}
+		entries = append(entries, file)
+	}
+
+	slices.SortStableFunc(entries, func(a *zip.File, b *zip.File) int {
+		if order := naturalCompare(a.Name, b.Name); order != 0 {
+			return order
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries
+}
+
+// naturalCompare orders names the way pages are numbered, so page2 comes before page10. Letters are
+// compared ignoring case.
+func naturalCompare(a string, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			aNum, bNum := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+			if len(aNum) != len(bNum) {
+				return len(aNum) - len(bNum)
+			}
+			if order := strings.Compare(aNum, bNum); order != 0 {
+				return order
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+
+		aRune, aSize := utf8.DecodeRuneInString(a)
+		bRune, bSize := utf8.DecodeRuneInString(b)
+		if aLower, bLower := unicode.ToLower(aRune), unicode.ToLower(bRune); aLower != bLower {
+			return int(aLower) - int(bLower)
+		}
+		a, b = a[aSize:], b[bSize:]
+	}
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
+
+func (s *PostService) ListPages(ctx context.Context, postID int) ([]posts.Page, error) {
+	return s.repo.ListPages(ctx, postID)
+}
+
+// ReadingPosition is the page the user last reached in a book, or the first page when they have not
+// started it.
+func (s *PostService) ReadingPosition(ctx context.Context, userID int, postID int) (int, error) {
+	if userID == 0 {
+		return 1, nil
+	}
+	page, err := s.repo.GetReadingProgress(ctx, userID, postID)
+	if errors.Is(err, myErrors.ErrNotFound) {
+		return 1, nil
+	}
+	return page, err
+}
+
+func (s *PostService) SaveReadingPosition(ctx context.Context, userID int, postID int, page int) error {
+	pages, err := s.repo.ListPages(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if page < 1 || page > len(pages) {
+		return ErrInvalidPage
+	}
+	return s.repo.SaveReadingProgress(ctx, userID, postID, page)
+}
+
+func (s *PostService) removePages(ctx context.Context, hash string, pages []posts.Page) {
+	for _, page := range pages {
+		for _, key := range []string{posts.PageKey(hash, page.Number, page.FileExt), posts.PageThumbnailKey(hash, page.Number)} {
+			if err := s.blob.Delete(ctx, key); err != nil {
+				log.Printf("Failed to remove file: %s, %v\n", key, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"goserv/internal/domain/posts"
+	"goserv/internal/domain/posts/repository"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/pkg/storage"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostService_ProcessPost_Book(t *testing.T) {
+	type args struct {
+		entries []string
+	}
+	type want struct {
+		pages []posts.Page
+		state enum.ProcessingState
+		err   error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "pages in natural order",
+			args: args{entries: []string{"page10.png", "Page2.PNG", "page1.png", "notes.txt", ".cover.png", "__MACOSX/page1.png", "extras/"}},
+			want: want{
+				pages: []posts.Page{{Number: 1, FileExt: ".png"}, {Number: 2, FileExt: ".png"}, {Number: 3, FileExt: ".png"}},
+				state: enum.ProcessingReady,
+			},
+		},
+		{
+			name: "no images",
+			args: args{entries: []string{"notes.txt"}},
+			want: want{pages: nil, state: "", err: ErrNoPages},
+		},
+	}
+
+	hash := strings.Repeat("cd", 32)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			assert.NoError(t, os.Mkdir("tmp", 0755))
+			contentDir := filepath.Join("content", hash[0:2], hash[2:4])
+			assert.NoError(t, os.MkdirAll(contentDir, 0755))
+			writeTestArchive(t, filepath.Join(contentDir, hash+"album.cbz"), test.args.entries)
+
+			var pages []posts.Page
+			var state enum.ProcessingState
+			postRepo := &repository.PostMock{
+				GetPostFunc: func(ctx context.Context, postID int) (*posts.Post, error) {
+					return &posts.Post{ID: 1, MediaType: enum.MediaBook, Filename: hash + "album", FileExt: ".cbz"}, nil
+				},
+				SetPagesFunc: func(ctx context.Context, postID int, newPages []posts.Page) error {
+					pages = newPages
+					return nil
+				},
+				SetProcessingStateFunc: func(ctx context.Context, postID int, newState enum.ProcessingState) error {
+					state = newState
+					return nil
+				},
+			}
+
+			service := NewPostService(postRepo, storage.NewLocal("."), nil)
+
+			err := service.ProcessPost(context.Background(), 1)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.pages, pages)
+			assert.Equal(t, test.want.state, state)
+
+			for _, page := range test.want.pages {
+				_, statErr := os.Stat(posts.PageKey(hash, page.Number, page.FileExt))
+				assert.NoError(t, statErr)
+				_, statErr = os.Stat(posts.PageThumbnailKey(hash, page.Number))
+				assert.NoError(t, statErr)
+			}
+			_, statErr := os.Stat(posts.ThumbnailKey(hash + "album"))
+			assert.Equal(t, test.want.err == nil, statErr == nil)
+		})
+	}
+}
+
+func TestPostService_SaveReadingPosition(t *testing.T) {
+	type args struct {
+		page int
+	}
+	type want struct {
+		saved bool
+		err   error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{name: "page in the book", args: args{page: 2}, want: want{saved: true, err: nil}},
+		{name: "page zero", args: args{page: 0}, want: want{saved: false, err: ErrInvalidPage}},
+		{name: "past the last page", args: args{page: 3}, want: want{saved: false, err: ErrInvalidPage}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saved := false
+			postRepo := &repository.PostMock{
+				ListPagesFunc: func(ctx context.Context, postID int) ([]posts.Page, error) {
+					return []posts.Page{{Number: 1, FileExt: ".jpg"}, {Number: 2, FileExt: ".jpg"}}, nil
+				},
+				SaveReadingProgressFunc: func(ctx context.Context, userID int, postID int, page int) error {
+					assert.Equal(t, test.args.page, page)
+					saved = true
+					return nil
+				},
+			}
+
+			service := NewPostService(postRepo, nil, nil)
+
+			err := service.SaveReadingPosition(context.Background(), 1, 5, test.args.page)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.saved, saved)
+		})
+	}
+}
+
+func TestPostService_ReadingPosition(t *testing.T) {
+	postRepo := &repository.PostMock{
+		GetReadingProgressFunc: func(ctx context.Context, userID int, postID int) (int, error) {
+			if userID == 1 {
+				return 7, nil
+			}
+			return 0, myErrors.ErrNotFound
+		},
+	}
+	service := NewPostService(postRepo, nil, nil)
+
+	page, err := service.ReadingPosition(context.Background(), 1, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, page)
+
+	page, err = service.ReadingPosition(context.Background(), 2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page)
+
+	page, err = service.ReadingPosition(context.Background(), 0, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page)
+}
+
+func TestNaturalCompare(t *testing.T) {
+	expected := []string{"cover.jpg", "Page1.jpg", "page01b.jpg", "page9.jpg", "page10.jpg"}
+
+	for i := range expected {
+		for j := range expected {
+			order := naturalCompare(expected[i], expected[j])
+			switch {
+			case i < j:
+				assert.Negative(t, order, "%s before %s", expected[i], expected[j])
+			case i > j:
+				assert.Positive(t, order, "%s after %s", expected[i], expected[j])
+			}
+		}
+	}
+}
+
+func writeTestArchive(t *testing.T, path string, entries []string) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range entries {
+		entry, err := archive.Create(name)
+		assert.NoError(t, err)
+		if strings.HasSuffix(strings.ToLower(name), ".png") {
+			assert.NoError(t, png.Encode(entry, image.NewRGBA(image.Rect(0, 0, 8, 8))))
+		}
+	}
+	assert.NoError(t, archive.Close())
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
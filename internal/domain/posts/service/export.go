@@ -0,0 +1,169 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goserv/internal/domain/posts"
+	"goserv/internal/utils/pagination"
+	"goserv/pkg/storage"
+	"io"
+	"log"
+	"time"
+)
+
+// UserExport is everything a user's data export holds, read up front so the archive can be streamed.
+type UserExport struct {
+	Uploads    []posts.Post
+	Favourites []posts.Post
+	CreatedAt  time.Time
+}
+
+type exportTag struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type exportPost struct {
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	MediaType   string      `json:"media_type"`
+	ContentHash string      `json:"content_hash,omitempty"`
+	File        string      `json:"file,omitempty"`
+	Tags        []exportTag `json:"tags"`
+}
+
+// UserExport gathers the user's uploads and favourites along with their tags.
+func (s *PostService) UserExport(ctx context.Context, userID int) (*UserExport, error) {
+	uploads, err := s.allPages(ctx, userID, s.repo.ListUserPosts)
+	if err != nil {
+		return nil, err
+	}
+	favourites, err := s.allPages(ctx, userID, s.repo.ListUserFavs)
+	if err != nil {
+		return nil, err
+	}
+
+	// listings leave tags out, so each post is read again in full
+	for _, list := range [][]posts.Post{uploads, favourites} {
+		for i := range list {
+			post, err := s.repo.GetPost(ctx, list[i].ID)
+			if err != nil {
+				return nil, err
+			}
+			list[i].Tags = post.Tags
+		}
+	}
+	return &UserExport{Uploads: uploads, Favourites: favourites, CreatedAt: time.Now()}, nil
+}
+
+// WriteExport streams the export as a zip archive: the original file of every upload under uploads/,
+// and posts.json and favourites.json describing the posts and their tags. Uploads whose file is gone are left out.
+func (s *PostService) WriteExport(ctx context.Context, w io.Writer, export *UserExport) error {
+	archive := zip.NewWriter(w)
+
+	uploads := make([]exportPost, len(export.Uploads))
+	for i, post := range export.Uploads {
+		uploads[i] = toExportPost(post)
+		name := exportFilename(post)
+
+		ok, err := s.writeUpload(ctx, archive, name, post, export.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if ok {
+			uploads[i].File = name
+		}
+	}
+
+	favourites := make([]exportPost, len(export.Favourites))
+	for i, post := range export.Favourites {
+		favourites[i] = toExportPost(post)
+	}
+
+	if err := writeJSON(archive, "posts.json", uploads, export.CreatedAt); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "favourites.json", favourites, export.CreatedAt); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func (s *PostService) writeUpload(ctx context.Context, archive *zip.Writer, name string, post posts.Post, modified time.Time) (bool, error) {
+	key := posts.ContentKey(post.Filename, post.FileExt)
+	content, err := s.blob.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Leaving %s out of export, file is missing\n", key)
+			return false, nil
+		}
+		return false, err
+	}
+	defer content.Close()
+
+	// media is already compressed, so it is only stored
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func writeJSON(archive *zip.Writer, name string, value any, modified time.Time) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// exportFilename names an upload by post id so titles shared by several posts cannot collide.
+func exportFilename(post posts.Post) string {
+	title := post.Filename[min(posts.HashLen, len(post.Filename)):]
+	if title == "" {
+		return fmt.Sprintf("uploads/%d%s", post.ID, post.FileExt)
+	}
+	return fmt.Sprintf("uploads/%d-%s%s", post.ID, title, post.FileExt)
+}
+
+func toExportPost(post posts.Post) exportPost {
+	result := exportPost{
+		ID:          post.ID,
+		Title:       post.Title,
+		MediaType:   string(post.MediaType),
+		ContentHash: post.ContentHash,
+		Tags:        make([]exportTag, len(post.Tags)),
+	}
+	for i, tag := range post.Tags {
+		result.Tags[i] = exportTag{Name: tag.Name, Type: string(tag.Type)}
+	}
+	return result
+}
+
+func (s *PostService) allPages(
+	ctx context.Context,
+	userID int,
+	list func(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error),
+) ([]posts.Post, error) {
+	var all []posts.Post
+	page := pagination.PageRequest{Limit: pagination.MaxLimit, Order: pagination.OrderOldest}
+	for {
+		result, err := list(ctx, userID, page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		if result.Next == "" {
+			return all, nil
+		}
+		page.After = result.Next
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"goserv/internal/domain/posts"
+	"goserv/internal/domain/posts/repository"
+	"goserv/internal/domain/tags"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/pagination"
+	"goserv/pkg/storage"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostService_UserExport(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	beach := tags.Tag{ID: 3, Name: "beach", Type: enum.TagGeneral}
+
+	uploads := []posts.Post{
+		{ID: 1, Title: "sea", MediaType: enum.MediaImage, Filename: hash + "sea", FileExt: ".png"},
+		{ID: 2, Title: "gone", MediaType: enum.MediaImage, Filename: strings.Repeat("cd", 32) + "gone", FileExt: ".png"},
+	}
+	favourites := []posts.Post{
+		{ID: 7, Title: "sand", MediaType: enum.MediaVideo, Filename: strings.Repeat("ef", 32) + "sand", FileExt: ".mp4"},
+	}
+
+	t.Chdir(t.TempDir())
+	contentDir := filepath.Join("content", hash[0:2], hash[2:4])
+	require.NoError(t, os.MkdirAll(contentDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(contentDir, hash+"sea.png"), []byte("png data"), 0644))
+
+	postRepo := &repository.PostMock{
+		// one post per page so the export has to follow the cursors
+		ListUserPostsFunc: func(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+			assert.Equal(t, pagination.OrderOldest, page.Order)
+			if page.After == "" {
+				return pagination.Page[posts.Post]{Items: uploads[:1], Next: "second"}, nil
+			}
+			return pagination.Page[posts.Post]{Items: uploads[1:]}, nil
+		},
+		ListUserFavsFunc: func(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+			return pagination.Page[posts.Post]{Items: favourites}, nil
+		},
+		GetPostFunc: func(ctx context.Context, postID int) (*posts.Post, error) {
+			return &posts.Post{ID: postID, Tags: []tags.Tag{beach}}, nil
+		},
+	}
+
+	service := NewPostService(postRepo, storage.NewLocal("."), nil)
+
+	export, err := service.UserExport(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Len(t, export.Uploads, 2)
+	assert.Equal(t, []tags.Tag{beach}, export.Favourites[0].Tags)
+
+	var buf bytes.Buffer
+	require.NoError(t, service.WriteExport(context.Background(), &buf, export))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		content, err := file.Open()
+		require.NoError(t, err)
+		files[file.Name], err = io.ReadAll(content)
+		require.NoError(t, err)
+		content.Close()
+	}
+	assert.Len(t, files, 3)
+	assert.Equal(t, "png data", string(files["uploads/1-sea.png"]))
+
+	var exported []exportPost
+	require.NoError(t, json.Unmarshal(files["posts.json"], &exported))
+	assert.Equal(t, []exportPost{
+		{ID: 1, Title: "sea", MediaType: "Image", File: "uploads/1-sea.png", Tags: []exportTag{{Name: "beach", Type: "General"}}},
+		{ID: 2, Title: "gone", MediaType: "Image", Tags: []exportTag{{Name: "beach", Type: "General"}}},
+	}, exported)
+
+	exported = nil
+	require.NoError(t, json.Unmarshal(files["favourites.json"], &exported))
+	assert.Equal(t, []exportPost{
+		{ID: 7, Title: "sand", MediaType: "Video", Tags: []exportTag{{Name: "beach", Type: "General"}}},
+	}, exported)
+}
+
+func TestPostService_DeletePosts(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	userPosts := []posts.Post{
+		{ID: 1, Filename: hash + "one", FileExt: ".png"},
+		{ID: 2, Filename: hash + "two", FileExt: ".png"},
+		{ID: 3, Filename: hash + "three", FileExt: ".png"},
+	}
+
+	t.Chdir(t.TempDir())
+	contentDir := filepath.Join("content", hash[0:2], hash[2:4])
+	require.NoError(t, os.MkdirAll(contentDir, 0755))
+	for _, post := range userPosts {
+		require.NoError(t, os.WriteFile(filepath.Join(contentDir, post.Filename+post.FileExt), nil, 0644))
+	}
+
+	var deleted []int
+	postRepo := &repository.PostMock{
+		DeletePostFunc: func(ctx context.Context, postID int) error {
+			if postID == 2 {
+				return errors.New("test error")
+			}
+			deleted = append(deleted, postID)
+			return nil
+		},
+	}
+
+	service := NewPostService(postRepo, storage.NewLocal("."), nil)
+
+	err := service.DeletePosts(context.Background(), userPosts)
+	assert.EqualError(t, err, "post 2: test error")
+	assert.Equal(t, []int{1, 3}, deleted)
+	assert.NoFileExists(t, filepath.Join(contentDir, hash+"one.png"))
+	assert.FileExists(t, filepath.Join(contentDir, hash+"two.png"))
+	assert.NoFileExists(t, filepath.Join(contentDir, hash+"three.png"))
+}
@@ -5,31 +5,45 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"goserv/internal/domain/jobs"
+	jRepo "goserv/internal/domain/jobs/repository"
 	"goserv/internal/domain/posts"
 	"goserv/internal/domain/posts/repository"
+	"goserv/internal/domain/posts/search"
+	"goserv/internal/domain/tags"
 	"goserv/internal/static/constant"
 	"goserv/internal/static/enum"
 	"goserv/internal/utils"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/storage"
 	"io"
 	"log"
+	"mime"
 	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+var ErrInvalidMediaType = errors.New("file extension does not match media type")
+
 type PostService struct {
 	repo repository.Post
+	blob storage.Blob
+	jobs jRepo.Job
 }
 
-func NewPostService(repo repository.Post) *PostService {
-	return &PostService{repo: repo}
+func NewPostService(repo repository.Post, blob storage.Blob, jobRepo jRepo.Job) *PostService {
+	return &PostService{repo: repo, blob: blob, jobs: jobRepo}
 }
 
-func (s *PostService) AddPost(ctx context.Context, post *posts.Post, content multipart.File, userID int) error {
+func (s *PostService) AddPost(ctx context.Context, post *posts.Post, content multipart.File, userID int) (int, error) {
 	tempFile, err := os.CreateTemp("tmp", "upload-*")
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer os.Remove(tempFile.Name())
 	defer tempFile.Close()
@@ -41,62 +55,181 @@ func (s *PostService) AddPost(ctx context.Context, post *posts.Post, content mul
 	_, err = io.Copy(multiWriter, content)
 	tempFile.Close()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	hashBytes := hasher.Sum(nil)
 	hashHex := hex.EncodeToString(hashBytes)
+	if existing, err := s.repo.GetPostByHash(ctx, hashHex); err == nil {
+		return 0, &posts.DuplicateError{PostID: existing.ID}
+	} else if !errors.Is(err, myErrors.ErrNotFound) {
+		return 0, err
+	}
+
 	ext := strings.ToLower(filepath.Ext(post.Filename))
 	//TODO: add extension validation based on content type
 
-	finalDir := filepath.Join("content", hashHex[0:2], hashHex[2:4])
-	finalName := hashHex + post.Title
-	finalPath := filepath.Join(finalDir, finalName+ext)
-
-	if err := os.MkdirAll(finalDir, 0755); err != nil {
-		return err
+	switch post.MediaType {
+	case enum.MediaImage, enum.MediaVideo, enum.MediaAudio, enum.MediaBook:
+		post.ProcessingState = enum.ProcessingPending
+	default:
+		return 0, errors.New("invalid media type")
 	}
 
+	finalName := posts.StorageName(hashHex, post.Title)
+
 	post.Filename = finalName
 	post.FileExt = ext
+	post.ContentHash = hashHex
 	postID, err := s.repo.AddPost(ctx, post, userID)
 	if err != nil {
-		return err
+		if errors.Is(err, posts.ErrDuplicate) {
+			return 0, s.duplicateOf(ctx, hashHex)
+		}
+		return 0, err
 	}
 
-	if err := os.Rename(tempFile.Name(), finalPath); err != nil {
-		dbErr := s.repo.DeletePost(ctx, postID)
-		if dbErr != nil {
-			log.Printf("Error deleting post from db, %v\n", dbErr)
+	if err := s.putFile(ctx, posts.ContentKey(finalName, ext), tempFile.Name()); err != nil {
+		s.cleanupBadAdd(ctx, postID, post)
+		return 0, err
+	}
+
+	// thumbnails are made by a job worker so a big video does not hold up the upload request
+	if post.ProcessingState == enum.ProcessingPending {
+		if _, err := s.jobs.Enqueue(ctx, &jobs.Job{Kind: jobs.KindProcessPost, PostID: postID}); err != nil {
+			s.cleanupBadAdd(ctx, postID, post)
+			return 0, err
 		}
+	}
+	return postID, nil
+}
+
+// ProcessPost makes the thumbnail for an uploaded post and marks it ready. Audio posts also get their
+// tags read, and books are split into pages.
+func (s *PostService) ProcessPost(ctx context.Context, postID int) error {
+	post, err := s.repo.GetPost(ctx, postID)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			// deleted before it was processed, nothing left to do
+			return nil
+		}
+		return err
+	}
+
+	tempFile, err := os.CreateTemp("tmp", "process-*"+post.FileExt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	content, err := s.blob.Get(ctx, posts.ContentKey(post.Filename, post.FileExt))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tempFile, content)
+	content.Close()
+	tempFile.Close()
+	if err != nil {
 		return err
 	}
 
+	thumbnailPath := tempFile.Name() + constant.ThumbnailExt
+	defer os.Remove(thumbnailPath)
+
 	switch post.MediaType {
 	case enum.MediaImage:
-		if err := utils.CreateImageThumbnail(finalDir, finalName, ext); err != nil {
-			s.cleanupBadAdd(ctx, postID, finalPath)
-			return err
-		}
+		err = utils.CreateImageThumbnail(tempFile.Name(), thumbnailPath)
 	case enum.MediaVideo:
-		if err := utils.ExctractVideoThumbnail(finalName, ext); err != nil {
-			s.cleanupBadAdd(ctx, postID, finalPath)
-			return err
-		}
-	case enum.MediaAudio, enum.MediaBook:
+		err = utils.ExctractVideoThumbnail(tempFile.Name(), thumbnailPath)
+	case enum.MediaAudio:
+		err = s.processAudio(ctx, postID, tempFile.Name(), thumbnailPath)
+	case enum.MediaBook:
+		err = s.processBook(ctx, post, tempFile.Name(), thumbnailPath)
 	default:
-		s.cleanupBadAdd(ctx, postID, finalPath)
-		return errors.New("invalid media type")
+		return s.repo.SetProcessingState(ctx, postID, enum.ProcessingReady)
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+
+	err = s.putFile(ctx, posts.ThumbnailKey(post.Filename), thumbnailPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// ffmpeg can come back without a frame for very short videos
+		log.Printf("No thumbnail was made for post %d\n", postID)
+	} else if err != nil {
+		return err
+	}
+	return s.repo.SetProcessingState(ctx, postID, enum.ProcessingReady)
+}
+
+// processAudio stores the audio's tags and makes its thumbnail from the embedded cover art, or from
+// a drawing of its waveform when there is none.
+func (s *PostService) processAudio(ctx context.Context, postID int, srcPath string, thumbnailPath string) error {
+	info, err := utils.ProbeAudio(srcPath)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.SetAudioMeta(ctx, postID, posts.AudioMeta{
+		Title:    info.Title,
+		Artist:   info.Artist,
+		Album:    info.Album,
+		Duration: info.Duration,
+		Bitrate:  info.Bitrate,
+	})
+	if err != nil {
+		return err
+	}
+
+	if info.HasCover {
+		err := utils.ExtractCoverArt(srcPath, thumbnailPath)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Failed to extract cover art of post %d, drawing waveform: %v\n", postID, err)
+	}
+	return utils.CreateWaveform(srcPath, thumbnailPath)
 }
 
-func (s *PostService) cleanupBadAdd(ctx context.Context, postID int, path string) {
+// MarkProcessingFailed is called once a post's processing job has run out of attempts.
+func (s *PostService) MarkProcessingFailed(ctx context.Context, postID int) {
+	if err := s.repo.SetProcessingState(ctx, postID, enum.ProcessingFailed); err != nil && !errors.Is(err, myErrors.ErrNotFound) {
+		log.Printf("Failed to mark post %d as failed: %v\n", postID, err)
+	}
+}
+
+func (s *PostService) putFile(ctx context.Context, key string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return s.blob.Put(ctx, key, file, mime.TypeByExtension(filepath.Ext(key)))
+}
+
+// duplicateOf reports the post that won a race to store the same content.
+func (s *PostService) duplicateOf(ctx context.Context, contentHash string) error {
+	existing, err := s.repo.GetPostByHash(ctx, contentHash)
+	if err != nil {
+		return posts.ErrDuplicate
+	}
+	return &posts.DuplicateError{PostID: existing.ID}
+}
+
+func (s *PostService) cleanupBadAdd(ctx context.Context, postID int, post *posts.Post) {
 	if dbErr := s.repo.DeletePost(ctx, postID); dbErr != nil {
 		log.Printf("Error deleting post from db, %v\n", dbErr)
 	}
-	if osErr := os.Remove(path); osErr != nil {
-		log.Printf("Error deleting file: %s, %v\n", path, osErr)
+	s.removeFiles(ctx, post.Filename, post.FileExt)
+}
+
+func (s *PostService) removeFiles(ctx context.Context, filename string, fileExt string) {
+	for _, key := range []string{posts.ContentKey(filename, fileExt), posts.ThumbnailKey(filename)} {
+		if err := s.blob.Delete(ctx, key); err != nil {
+			log.Printf("Failed to remove file: %s, %v\n", key, err)
+		}
 	}
 }
 
@@ -104,37 +237,132 @@ func (s *PostService) GetPost(ctx context.Context, postID int) (*posts.Post, err
 	return s.repo.GetPost(ctx, postID)
 }
 
-func (s *PostService) ListPosts(ctx context.Context) ([]posts.Post, error) {
-	return s.repo.ListPosts(ctx)
+func (s *PostService) ListPosts(ctx context.Context, query string, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	node, err := search.Parse(query)
+	if err != nil {
+		return pagination.Page[posts.Post]{}, err
+	}
+	return s.repo.ListPosts(ctx, node, page)
+}
+
+func (s *PostService) ListUserPosts(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	return s.repo.ListUserPosts(ctx, userID, page)
 }
 
-func (s *PostService) ListUserPosts(ctx context.Context, userID int) ([]posts.Post, error) {
-	return s.repo.ListUserPosts(ctx, userID)
+func (s *PostService) ListUserFavs(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+	return s.repo.ListUserFavs(ctx, userID, page)
 }
 
-func (s *PostService) ListUserFavs(ctx context.Context, userID int) ([]posts.Post, error) {
-	return s.repo.ListUserFavs(ctx, userID)
+func (s *PostService) CountUserPosts(ctx context.Context, userID int) (int, error) {
+	return s.repo.CountUserPosts(ctx, userID)
 }
 
-func (s *PostService) DeletePost(ctx context.Context, postID int, filename string, fileExt string) error {
-	err := s.repo.DeletePost(ctx, postID)
+// AllUserPosts returns every post the user uploaded, oldest first.
+func (s *PostService) AllUserPosts(ctx context.Context, userID int) ([]posts.Post, error) {
+	return s.allPages(ctx, userID, s.repo.ListUserPosts)
+}
+
+func (s *PostService) DeletePost(ctx context.Context, post *posts.Post) error {
+	// a book's pages are listed before the rows go with the post
+	var pages []posts.Page
+	if post.MediaType == enum.MediaBook {
+		var err error
+		pages, err = s.repo.ListPages(ctx, post.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.repo.DeletePost(ctx, post.ID)
 	if err != nil {
 		return err
 	}
 
-	dataPath := filepath.Join(filename[0:2], filename[2:4], filename)
-	err = os.Remove(filepath.Join("content", dataPath+fileExt))
-	if err != nil {
-		log.Printf("Failed to remove content file during delete for data: %s, %v\n", dataPath, err)
+	s.removeFiles(ctx, post.Filename, post.FileExt)
+	if len(pages) > 0 {
+		s.removePages(ctx, post.Hash(), pages)
 	}
+	return nil
+}
 
-	err = os.Remove(filepath.Join("thumbnails", dataPath+constant.ThumbnailExt))
+// DeletePosts removes the posts and their files, carrying on past failures so one bad post does not keep the rest.
+func (s *PostService) DeletePosts(ctx context.Context, userPosts []posts.Post) error {
+	var errs []error
+	for _, post := range userPosts {
+		if err := s.DeletePost(ctx, &post); err != nil {
+			errs = append(errs, fmt.Errorf("post %d: %w", post.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// UpdatePost changes a post's title, media type and tags. A new title also renames the
+// stored content and thumbnail files so their names keep matching the post.
+func (s *PostService) UpdatePost(ctx context.Context, post *posts.Post, title string, mediaType enum.MediaType, postTags []tags.Tag) error {
+	if !validate.IsValidFileType(post.Filename+post.FileExt, mediaType) {
+		return ErrInvalidMediaType
+	}
+
+	updated := *post
+	updated.Title = title
+	updated.MediaType = mediaType
+	updated.Filename = posts.StorageName(post.Hash(), title)
+	updated.Tags = postTags
+
+	renamed, err := s.renameStoredFiles(ctx, post.Filename, updated.Filename, post.FileExt)
 	if err != nil {
-		log.Printf("Failed to remove thumbnail file during delete for data: %s, %v\n", dataPath, err)
+		return err
 	}
+
+	if err := s.repo.UpdatePost(ctx, &updated); err != nil {
+		if renamed {
+			if _, undoErr := s.renameStoredFiles(ctx, updated.Filename, post.Filename, post.FileExt); undoErr != nil {
+				log.Printf("Failed to restore files for post %d: %v\n", post.ID, undoErr)
+			}
+		}
+		return err
+	}
+
+	*post = updated
 	return nil
 }
 
+// renameStoredFiles moves the content and thumbnail files for a post, reporting whether anything moved.
+// A missing thumbnail is fine since posts whose processing failed never got one.
+func (s *PostService) renameStoredFiles(ctx context.Context, oldName string, newName string, fileExt string) (bool, error) {
+	if oldName == newName {
+		return false, nil
+	}
+
+	oldContent, newContent := posts.ContentKey(oldName, fileExt), posts.ContentKey(newName, fileExt)
+	if err := s.moveBlob(ctx, oldContent, newContent); err != nil {
+		return false, err
+	}
+
+	err := s.moveBlob(ctx, posts.ThumbnailKey(oldName), posts.ThumbnailKey(newName))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		if undoErr := s.moveBlob(ctx, newContent, oldContent); undoErr != nil {
+			log.Printf("Failed to restore content file: %s, %v\n", oldContent, undoErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// moveBlob copies a blob to its new key before removing the old one, since not every backend can rename.
+func (s *PostService) moveBlob(ctx context.Context, from string, to string) error {
+	content, err := s.blob.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	if err := s.blob.Put(ctx, to, content, mime.TypeByExtension(filepath.Ext(to))); err != nil {
+		return err
+	}
+	return s.blob.Delete(ctx, from)
+}
+
 func (s *PostService) FavouritePost(ctx context.Context, postID int, userID int) error {
 	return s.repo.FavouritePost(ctx, postID, userID)
 }
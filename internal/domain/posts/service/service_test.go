@@ -3,10 +3,22 @@ package service
 import (
 	"context"
 	"errors"
+	"goserv/internal/domain/jobs"
+	jRepo "goserv/internal/domain/jobs/repository"
 	"goserv/internal/domain/posts"
 	"goserv/internal/domain/posts/repository"
+	"goserv/internal/domain/posts/search"
 	"goserv/internal/domain/tags"
+	"goserv/internal/static/constant"
 	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"goserv/pkg/storage"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -81,7 +93,7 @@ func TestPostService_GetPost(t *testing.T) {
 				},
 			}
 
-			service := NewPostService(postRepo)
+			service := NewPostService(postRepo, nil, nil)
 
 			post, err := service.GetPost(context.Background(), test.args.postID)
 			assert.Equal(t, test.want.err, err)
@@ -91,12 +103,18 @@ func TestPostService_GetPost(t *testing.T) {
 }
 
 func TestPostService_ListPosts(t *testing.T) {
+	type args struct {
+		query string
+		page  pagination.PageRequest
+	}
 	type want struct {
-		posts []posts.Post
-		err   error
+		node search.Node
+		page pagination.Page[posts.Post]
+		err  error
 	}
 	type test struct {
 		name string
+		args args
 		want want
 	}
 
@@ -139,16 +157,40 @@ func TestPostService_ListPosts(t *testing.T) {
 	tests := []test{
 		{
 			name: "simple list posts",
+			args: args{query: "", page: pagination.PageRequest{Limit: 2}},
 			want: want{
-				posts: basicPosts,
-				err:   nil,
+				node: nil,
+				page: pagination.Page[posts.Post]{Items: basicPosts, Next: "next"},
+				err:  nil,
+			},
+		},
+		{
+			name: "list posts with query",
+			args: args{query: "tag1 -people:tag2", page: pagination.PageRequest{After: "cursor"}},
+			want: want{
+				node: search.And{Nodes: []search.Node{
+					search.Tag{Name: "tag1"},
+					search.Not{Node: search.Tag{Name: "tag2", Type: enum.TagPeople}},
+				}},
+				page: pagination.Page[posts.Post]{Items: basicPosts, Next: "next"},
+				err:  nil,
 			},
 		},
 		{
 			name: "error list posts",
+			args: args{query: "", page: pagination.PageRequest{Limit: 2}},
 			want: want{
-				posts: nil,
-				err:   errors.New("test error"),
+				node: nil,
+				page: pagination.Page[posts.Post]{},
+				err:  errors.New("test error"),
+			},
+		},
+		{
+			name: "invalid query",
+			args: args{query: "(tag1"},
+			want: want{
+				page: pagination.Page[posts.Post]{},
+				err:  search.ErrSyntax,
 			},
 		},
 	}
@@ -156,16 +198,18 @@ func TestPostService_ListPosts(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			postRepo := &repository.PostMock{
-				ListPostsFunc: func(ctx context.Context) ([]posts.Post, error) {
-					return test.want.posts, test.want.err
+				ListPostsFunc: func(ctx context.Context, query search.Node, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+					assert.Equal(t, test.want.node, query)
+					assert.Equal(t, test.args.page, page)
+					return test.want.page, test.want.err
 				},
 			}
 
-			service := NewPostService(postRepo)
+			service := NewPostService(postRepo, nil, nil)
 
-			posts, err := service.ListPosts(context.Background())
-			assert.Equal(t, test.want.err, err)
-			assert.Equal(t, test.want.posts, posts)
+			page, err := service.ListPosts(context.Background(), test.args.query, test.args.page)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.page, page)
 		})
 	}
 }
@@ -173,10 +217,11 @@ func TestPostService_ListPosts(t *testing.T) {
 func TestPostService_ListUserPosts(t *testing.T) {
 	type args struct {
 		userID int
+		page   pagination.PageRequest
 	}
 	type want struct {
-		posts []posts.Post
-		err   error
+		page pagination.Page[posts.Post]
+		err  error
 	}
 	type test struct {
 		name string
@@ -186,6 +231,7 @@ func TestPostService_ListUserPosts(t *testing.T) {
 
 	basicArgs := args{
 		userID: 1,
+		page:   pagination.PageRequest{Limit: 2, Order: pagination.OrderNewest},
 	}
 
 	basicTags := []tags.Tag{
@@ -229,16 +275,16 @@ func TestPostService_ListUserPosts(t *testing.T) {
 			name: "simple list user posts",
 			args: basicArgs,
 			want: want{
-				posts: basicPosts,
-				err:   nil,
+				page: pagination.Page[posts.Post]{Items: basicPosts, Next: "next"},
+				err:  nil,
 			},
 		},
 		{
 			name: "error list user posts",
 			args: basicArgs,
 			want: want{
-				posts: nil,
-				err:   errors.New("test error"),
+				page: pagination.Page[posts.Post]{},
+				err:  errors.New("test error"),
 			},
 		},
 	}
@@ -246,16 +292,17 @@ func TestPostService_ListUserPosts(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			postRepo := &repository.PostMock{
-				ListUserPostsFunc: func(ctx context.Context, userID int) ([]posts.Post, error) {
-					return test.want.posts, test.want.err
+				ListUserPostsFunc: func(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+					assert.Equal(t, test.args.page, page)
+					return test.want.page, test.want.err
 				},
 			}
 
-			service := NewPostService(postRepo)
+			service := NewPostService(postRepo, nil, nil)
 
-			posts, err := service.ListUserPosts(context.Background(), test.args.userID)
+			page, err := service.ListUserPosts(context.Background(), test.args.userID, test.args.page)
 			assert.Equal(t, test.want.err, err)
-			assert.Equal(t, test.want.posts, posts)
+			assert.Equal(t, test.want.page, page)
 		})
 	}
 }
@@ -263,10 +310,11 @@ func TestPostService_ListUserPosts(t *testing.T) {
 func TestPostService_ListUserFavs(t *testing.T) {
 	type args struct {
 		userID int
+		page   pagination.PageRequest
 	}
 	type want struct {
-		posts []posts.Post
-		err   error
+		page pagination.Page[posts.Post]
+		err  error
 	}
 	type test struct {
 		name string
@@ -276,6 +324,7 @@ func TestPostService_ListUserFavs(t *testing.T) {
 
 	basicArgs := args{
 		userID: 1,
+		page:   pagination.PageRequest{Limit: 2, Order: pagination.OrderNewest},
 	}
 
 	basicTags := []tags.Tag{
@@ -319,16 +368,16 @@ func TestPostService_ListUserFavs(t *testing.T) {
 			name: "simple list user posts",
 			args: basicArgs,
 			want: want{
-				posts: basicPosts,
-				err:   nil,
+				page: pagination.Page[posts.Post]{Items: basicPosts, Next: "next"},
+				err:  nil,
 			},
 		},
 		{
 			name: "error list user posts",
 			args: basicArgs,
 			want: want{
-				posts: nil,
-				err:   errors.New("test error"),
+				page: pagination.Page[posts.Post]{},
+				err:  errors.New("test error"),
 			},
 		},
 	}
@@ -336,16 +385,17 @@ func TestPostService_ListUserFavs(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			postRepo := &repository.PostMock{
-				ListUserFavsFunc: func(ctx context.Context, userID int) ([]posts.Post, error) {
-					return test.want.posts, test.want.err
+				ListUserFavsFunc: func(ctx context.Context, userID int, page pagination.PageRequest) (pagination.Page[posts.Post], error) {
+					assert.Equal(t, test.args.page, page)
+					return test.want.page, test.want.err
 				},
 			}
 
-			service := NewPostService(postRepo)
+			service := NewPostService(postRepo, nil, nil)
 
-			posts, err := service.ListUserFavs(context.Background(), test.args.userID)
+			page, err := service.ListUserFavs(context.Background(), test.args.userID, test.args.page)
 			assert.Equal(t, test.want.err, err)
-			assert.Equal(t, test.want.posts, posts)
+			assert.Equal(t, test.want.page, page)
 		})
 	}
 }
@@ -394,7 +444,7 @@ func TestPostService_FavouritePost(t *testing.T) {
 				},
 			}
 
-			service := NewPostService(postRepo)
+			service := NewPostService(postRepo, nil, nil)
 
 			err := service.FavouritePost(context.Background(), test.args.postID, test.args.userID)
 			assert.Equal(t, test.want.err, err)
@@ -446,7 +496,7 @@ func TestPostService_UnfavouritePost(t *testing.T) {
 				},
 			}
 
-			service := NewPostService(postRepo)
+			service := NewPostService(postRepo, nil, nil)
 
 			err := service.UnfavouritePost(context.Background(), test.args.postID, test.args.userID)
 			assert.Equal(t, test.want.err, err)
@@ -537,7 +587,7 @@ func TestPostService_GetPostWithFavouriteStatus(t *testing.T) {
 				},
 			}
 
-			service := NewPostService(postRepo)
+			service := NewPostService(postRepo, nil, nil)
 
 			post, isFav, err := service.GetPostWithFavouriteStatus(context.Background(), test.args.postID, test.args.userID)
 			assert.Equal(t, test.want.err, err)
@@ -546,3 +596,267 @@ func TestPostService_GetPostWithFavouriteStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestPostService_UpdatePost(t *testing.T) {
+	type args struct {
+		title     string
+		mediaType enum.MediaType
+	}
+	type want struct {
+		filename string
+		repoErr  error
+		err      error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	hash := strings.Repeat("ab", 32)
+
+	tests := []test{
+		{
+			name: "rename title",
+			args: args{title: "new", mediaType: enum.MediaImage},
+			want: want{filename: hash + "new", repoErr: nil, err: nil},
+		},
+		{
+			name: "same title",
+			args: args{title: "old", mediaType: enum.MediaImage},
+			want: want{filename: hash + "old", repoErr: nil, err: nil},
+		},
+		{
+			name: "path in title is flattened",
+			args: args{title: "../up", mediaType: enum.MediaImage},
+			want: want{filename: hash + "up", repoErr: nil, err: nil},
+		},
+		{
+			name: "media type does not fit extension",
+			args: args{title: "new", mediaType: enum.MediaVideo},
+			want: want{filename: hash + "old", repoErr: nil, err: ErrInvalidMediaType},
+		},
+		{
+			name: "repo error restores files",
+			args: args{title: "new", mediaType: enum.MediaImage},
+			want: want{filename: hash + "old", repoErr: errors.New("test error"), err: errors.New("test error")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			contentDir := filepath.Join("content", hash[0:2], hash[2:4])
+			thumbnailDir := filepath.Join("thumbnails", hash[0:2], hash[2:4])
+			assert.NoError(t, os.MkdirAll(contentDir, 0755))
+			assert.NoError(t, os.MkdirAll(thumbnailDir, 0755))
+			assert.NoError(t, os.WriteFile(filepath.Join(contentDir, hash+"old.png"), nil, 0644))
+			assert.NoError(t, os.WriteFile(filepath.Join(thumbnailDir, hash+"old"+constant.ThumbnailExt), nil, 0644))
+
+			var saved *posts.Post
+			postRepo := &repository.PostMock{
+				UpdatePostFunc: func(ctx context.Context, post *posts.Post) error {
+					saved = post
+					return test.want.repoErr
+				},
+			}
+
+			service := NewPostService(postRepo, storage.NewLocal("."), nil)
+
+			post := &posts.Post{ID: 1, Title: "old", MediaType: enum.MediaImage, Filename: hash + "old", FileExt: ".png"}
+			newTags := []tags.Tag{{ID: 3, Name: "beach", Type: enum.TagGeneral}}
+
+			err := service.UpdatePost(context.Background(), post, test.args.title, test.args.mediaType, newTags)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.filename, post.Filename)
+			assert.FileExists(t, filepath.Join(contentDir, test.want.filename+".png"))
+			assert.FileExists(t, filepath.Join(thumbnailDir, test.want.filename+constant.ThumbnailExt))
+			if test.want.err == nil {
+				assert.Equal(t, newTags, saved.Tags)
+				assert.Equal(t, test.args.title, post.Title)
+			}
+		})
+	}
+}
+
+type testFile struct {
+	*strings.Reader
+}
+
+func (testFile) Close() error {
+	return nil
+}
+
+func TestPostService_AddPost(t *testing.T) {
+	type args struct {
+		title     string
+		mediaType enum.MediaType
+		filename  string
+	}
+	type want struct {
+		postID      int
+		existing    *posts.Post
+		addErr      error
+		err         error
+		stored      bool
+		lookupCalls int
+		state       enum.ProcessingState
+		queued      bool
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	// sha256 of "content"
+	hash := "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+	song := args{title: "song", mediaType: enum.MediaAudio, filename: "song.mp3"}
+
+	tests := []test{
+		{
+			name: "new content is queued for processing",
+			args: song,
+			want: want{postID: 4, existing: nil, addErr: nil, err: nil, stored: true, lookupCalls: 1, state: enum.ProcessingPending, queued: true},
+		},
+		{
+			name: "already uploaded",
+			args: song,
+			want: want{postID: 0, existing: &posts.Post{ID: 2}, addErr: nil, err: &posts.DuplicateError{PostID: 2}, stored: false, lookupCalls: 1},
+		},
+		{
+			name: "uploaded at the same time",
+			args: song,
+			want: want{postID: 0, existing: nil, addErr: posts.ErrDuplicate, err: &posts.DuplicateError{PostID: 3}, stored: false, lookupCalls: 2, state: enum.ProcessingPending},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			assert.NoError(t, os.Mkdir("tmp", 0755))
+
+			lookups := 0
+			var state enum.ProcessingState
+			postRepo := &repository.PostMock{
+				GetPostByHashFunc: func(ctx context.Context, contentHash string) (*posts.Post, error) {
+					assert.Equal(t, hash, contentHash)
+					lookups++
+					if lookups == 2 {
+						return &posts.Post{ID: 3}, nil
+					}
+					if test.want.existing != nil {
+						return test.want.existing, nil
+					}
+					return nil, myErrors.ErrNotFound
+				},
+				AddPostFunc: func(ctx context.Context, post *posts.Post, userID int) (int, error) {
+					assert.Equal(t, hash, post.ContentHash)
+					state = post.ProcessingState
+					return test.want.postID, test.want.addErr
+				},
+			}
+			queued := false
+			jobRepo := &jRepo.JobMock{
+				EnqueueFunc: func(ctx context.Context, job *jobs.Job) (int, error) {
+					assert.Equal(t, jobs.Job{Kind: jobs.KindProcessPost, PostID: test.want.postID}, *job)
+					queued = true
+					return 1, nil
+				},
+			}
+
+			service := NewPostService(postRepo, storage.NewLocal("."), jobRepo)
+
+			post := &posts.Post{Title: test.args.title, MediaType: test.args.mediaType, Filename: test.args.filename}
+			postID, err := service.AddPost(context.Background(), post, testFile{strings.NewReader("content")}, 1)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.postID, postID)
+			assert.Equal(t, test.want.lookupCalls, lookups)
+			assert.True(t, errors.Is(err, posts.ErrDuplicate) == (test.want.err != nil))
+			assert.Equal(t, test.want.state, state)
+			assert.Equal(t, test.want.queued, queued)
+
+			_, statErr := os.Stat(filepath.Join("content", hash[0:2], hash[2:4], hash+"song.mp3"))
+			assert.Equal(t, test.want.stored, statErr == nil)
+		})
+	}
+}
+
+func TestPostService_ProcessPost(t *testing.T) {
+	type args struct {
+		postID int
+	}
+	type want struct {
+		state     enum.ProcessingState
+		thumbnail bool
+		err       error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	hash := strings.Repeat("ab", 32)
+
+	tests := []test{
+		{
+			name: "image gets a thumbnail",
+			args: args{postID: 1},
+			want: want{state: enum.ProcessingReady, thumbnail: true, err: nil},
+		},
+		{
+			name: "post was deleted",
+			args: args{postID: 2},
+			want: want{state: "", thumbnail: false, err: nil},
+		},
+		{
+			name: "content is missing",
+			args: args{postID: 3},
+			want: want{state: "", thumbnail: false, err: storage.ErrNotFound},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			assert.NoError(t, os.Mkdir("tmp", 0755))
+			contentDir := filepath.Join("content", hash[0:2], hash[2:4])
+			assert.NoError(t, os.MkdirAll(contentDir, 0755))
+			writeTestPNG(t, filepath.Join(contentDir, hash+"pic.png"))
+
+			var state enum.ProcessingState
+			postRepo := &repository.PostMock{
+				GetPostFunc: func(ctx context.Context, postID int) (*posts.Post, error) {
+					switch postID {
+					case 1:
+						return &posts.Post{ID: 1, MediaType: enum.MediaImage, Filename: hash + "pic", FileExt: ".png"}, nil
+					case 3:
+						return &posts.Post{ID: 3, MediaType: enum.MediaImage, Filename: hash + "gone", FileExt: ".png"}, nil
+					}
+					return nil, myErrors.ErrNotFound
+				},
+				SetProcessingStateFunc: func(ctx context.Context, postID int, newState enum.ProcessingState) error {
+					state = newState
+					return nil
+				},
+			}
+
+			service := NewPostService(postRepo, storage.NewLocal("."), nil)
+
+			err := service.ProcessPost(context.Background(), test.args.postID)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.state, state)
+
+			_, statErr := os.Stat(filepath.Join("thumbnails", hash[0:2], hash[2:4], hash+"pic"+constant.ThumbnailExt))
+			assert.Equal(t, test.want.thumbnail, statErr == nil)
+		})
+	}
+}
+
+func writeTestPNG(t *testing.T, path string) {
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.NoError(t, png.Encode(file, image.NewRGBA(image.Rect(0, 0, 8, 8))))
+}
@@ -0,0 +1,27 @@
+package sessions
+
+import (
+	"net"
+	"net/http"
+)
+
+const maxUserAgentLength = 256
+
+// Client describes where a login came from, as shown on the sessions page.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func ClientFromRequest(r *http.Request) Client {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return Client{UserAgent: userAgent, IP: ip}
+}
@@ -0,0 +1,54 @@
+package sessions
+
+import (
+	"net/http"
+	"time"
+)
+
+const CookieName = "id"
+
+// PendingCookieName holds a signed Pending between the password and second factor steps of a login.
+const PendingCookieName = "login_pending"
+
+// OIDCFlowCookieName carries a signed OIDCFlow while the user is away at the provider.
+const OIDCFlowCookieName = "oidc_flow"
+
+func NewCookie(sessionID string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    sessionID,
+		Path:     "/",
+		HttpOnly: true,
+		Expires:  expires,
+	}
+}
+
+func NewPendingCookie(token string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     PendingCookieName,
+		Value:    token,
+		Path:     "/login",
+		HttpOnly: true,
+		Expires:  expires,
+	}
+}
+
+func ClearPendingCookie() *http.Cookie {
+	return &http.Cookie{Name: PendingCookieName, Path: "/login", MaxAge: -1}
+}
+
+func NewOIDCFlowCookie(token string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     OIDCFlowCookieName,
+		Value:    token,
+		Path:     "/login/oidc",
+		HttpOnly: true,
+		// the provider sends the user back with a cross-site top-level GET
+		SameSite: http.SameSiteLaxMode,
+		Expires:  expires,
+	}
+}
+
+func ClearOIDCFlowCookie() *http.Cookie {
+	return &http.Cookie{Name: OIDCFlowCookieName, Path: "/login/oidc", MaxAge: -1}
+}
@@ -1,21 +1,29 @@
 package handler
 
 import (
+	"errors"
+	"goserv/internal/domain/sessions"
 	"goserv/internal/domain/sessions/service"
 	"goserv/internal/domain/users"
+	uService "goserv/internal/domain/users/service"
 	"goserv/internal/middleware"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/pkg/totp"
 	"html/template"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type SessionHandler struct {
-	svc  *service.SessionService
-	tmpl *template.Template
+	svc     *service.SessionService
+	oidcSvc *service.OIDCService
+	userSvc *uService.UserService
+	tmpl    *template.Template
 }
 
-func NewSessionHandler(svc *service.SessionService, tmpl *template.Template) *SessionHandler {
-	return &SessionHandler{svc: svc, tmpl: tmpl}
+func NewSessionHandler(svc *service.SessionService, oidcSvc *service.OIDCService, userSvc *uService.UserService, tmpl *template.Template) *SessionHandler {
+	return &SessionHandler{svc: svc, oidcSvc: oidcSvc, userSvc: userSvc, tmpl: tmpl}
 }
 
 func (h *SessionHandler) DisplayLogin(w http.ResponseWriter, r *http.Request) {
@@ -25,8 +33,14 @@ func (h *SessionHandler) DisplayLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.tmpl.ExecuteTemplate(w, "login.html", struct{ User *users.User }{
-		User: &users.User{},
+	err := h.tmpl.ExecuteTemplate(w, "login.html", struct {
+		User      *users.User
+		Providers []service.OIDCProvider
+		CSRF      string
+	}{
+		User:      &users.User{},
+		Providers: h.oidcSvc.Providers(),
+		CSRF:      middleware.CSRFToken(r),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -38,26 +52,217 @@ func (h *SessionHandler) Login(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 
-	sessionID, err := h.svc.Login(r.Context(), username, password)
+	session, err := h.svc.Login(r.Context(), username, password, sessions.ClientFromRequest(r))
 	if err != nil {
+		if redirectSecondFactor(w, r, err) {
+			return
+		}
+		if writeThrottled(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			http.Error(w, "This account has been disabled", http.StatusForbidden)
+			return
+		}
 		http.Error(w, "Error logging in", http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "id",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
+	http.SetCookie(w, sessions.NewCookie(session.ID, session.ExpiresAt))
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// DisplaySecondFactor asks a pending login for its TOTP code, or has it set up an authenticator
+// first when an admin requires one.
+func (h *SessionHandler) DisplaySecondFactor(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.pending(w, r)
+	if !ok {
+		return
+	}
+
+	if pending.Enroll {
+		h.renderEnroll(w, r, pending, "")
+		return
+	}
+	h.renderSecondFactor(w, r, "")
+}
+
+func (h *SessionHandler) SecondFactor(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.pending(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.svc.LoginSecondFactor(r.Context(), pending, r.FormValue("code"), sessions.ClientFromRequest(r))
+	if err != nil {
+		if writeThrottled(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCode) {
+			h.renderSecondFactor(w, r, "Invalid code")
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			http.Error(w, "This account has been disabled", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, sessions.ErrInvalidPending) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, sessions.ClearPendingCookie())
+	http.SetCookie(w, sessions.NewCookie(session.ID, session.ExpiresAt))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// EnrollSecondFactor confirms the authenticator of a pending login that had to set one up,
+// then logs it in and shows the recovery codes.
+func (h *SessionHandler) EnrollSecondFactor(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.pending(w, r)
+	if !ok {
+		return
+	}
+	if !pending.Enroll {
+		http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	codes, err := h.userSvc.EnableTOTP(r.Context(), pending.UserID, r.FormValue("code"))
+	if err != nil {
+		if errors.Is(err, uService.ErrWrongCode) {
+			h.renderEnroll(w, r, pending, err.Error())
+			return
+		}
+		if errors.Is(err, uService.ErrTOTPEnabled) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Error enabling two-factor login", http.StatusInternalServerError)
+		return
+	}
+
+	session, err := h.svc.CompleteEnrollment(r.Context(), pending, sessions.ClientFromRequest(r))
+	if err != nil {
+		if errors.Is(err, service.ErrAccountDisabled) {
+			http.Error(w, "This account has been disabled", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, sessions.ClearPendingCookie())
+	http.SetCookie(w, sessions.NewCookie(session.ID, session.ExpiresAt))
+
+	err = h.tmpl.ExecuteTemplate(w, "totp_recovery.html", struct {
+		Codes    []string
+		Continue string
+	}{
+		Codes:    codes,
+		Continue: "/",
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// redirectSecondFactor sends a login that still needs a second factor on to /login/2fa.
+func redirectSecondFactor(w http.ResponseWriter, r *http.Request, err error) bool {
+	var secondFactor *sessions.SecondFactorError
+	if !errors.As(err, &secondFactor) {
+		return false
+	}
+	http.SetCookie(w, sessions.NewPendingCookie(secondFactor.Token, secondFactor.Pending.ExpiresAt))
+	http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+	return true
+}
+
+// pending reads the pending login cookie, sending the user back to /login when it is missing or expired.
+func (h *SessionHandler) pending(w http.ResponseWriter, r *http.Request) (*sessions.Pending, bool) {
+	cookie, err := r.Cookie(sessions.PendingCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, false
+	}
+
+	pending, err := h.svc.ParsePending(cookie.Value)
+	if err != nil {
+		http.SetCookie(w, sessions.ClearPendingCookie())
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, false
+	}
+	return pending, true
+}
+
+func (h *SessionHandler) renderSecondFactor(w http.ResponseWriter, r *http.Request, message string) {
+	err := h.tmpl.ExecuteTemplate(w, "login_2fa.html", struct {
+		Error string
+		CSRF  string
+	}{
+		Error: message,
+		CSRF:  middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *SessionHandler) renderEnroll(w http.ResponseWriter, r *http.Request, pending *sessions.Pending, message string) {
+	user, err := h.userSvc.GetByUserID(r.Context(), pending.UserID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := h.userSvc.BeginTOTP(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Error setting up two-factor login", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "totp_setup.html", struct {
+		Action   string
+		Secret   string
+		URI      string
+		Required bool
+		Error    string
+		CSRF     string
+	}{
+		Action:   "/login/2fa/enable",
+		Secret:   secret,
+		URI:      totp.ProvisioningURI(r.Host, user.Username, secret),
+		Required: true,
+		Error:    message,
+		CSRF:     middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// writeThrottled answers 429 when err is a lockout from failed logins.
+func writeThrottled(w http.ResponseWriter, err error) bool {
+	var throttled *sessions.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+	http.Error(w, "Too many failed logins, try again in "+throttled.RetryAfter.Round(time.Second).String(), http.StatusTooManyRequests)
+	return true
+}
+
 func (h *SessionHandler) DisplayLogout(w http.ResponseWriter, r *http.Request) {
-	err := h.tmpl.ExecuteTemplate(w, "logout.html", struct{ User *users.User }{
+	err := h.tmpl.ExecuteTemplate(w, "logout.html", struct {
+		User *users.User
+		CSRF string
+	}{
 		User: &users.User{},
+		CSRF: middleware.CSRFToken(r),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -71,7 +276,7 @@ func (h *SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie, err := r.Cookie("id")
+	cookie, err := r.Cookie(sessions.CookieName)
 	if err != nil {
 		http.Error(w, "Failed to read cookie", http.StatusUnauthorized)
 		return
@@ -87,3 +292,103 @@ func (h *SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, cookie)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+type sessionEntry struct {
+	Handle     string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	Current    bool
+}
+
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(sessions.CookieName)
+	if err != nil {
+		http.Error(w, "Failed to read cookie", http.StatusUnauthorized)
+		return
+	}
+
+	userSessions, err := h.svc.ListSessions(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error listing sessions", http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]sessionEntry, len(userSessions))
+	for i := range userSessions {
+		entries[i] = sessionEntry{
+			Handle:     userSessions[i].Handle(),
+			UserAgent:  userSessions[i].UserAgent,
+			IP:         userSessions[i].IP,
+			CreatedAt:  userSessions[i].CreatedAt,
+			LastSeenAt: userSessions[i].LastSeenAt,
+			Current:    userSessions[i].ID == cookie.Value,
+		}
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "sessions.html", struct {
+		Sessions []sessionEntry
+		CSRF     string
+	}{
+		Sessions: entries,
+		CSRF:     middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	sessionID, err := h.svc.RevokeSession(r.Context(), userID, r.FormValue("session"))
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+
+	if cookie, err := r.Cookie(sessions.CookieName); err == nil && cookie.Value == sessionID {
+		cookie.MaxAge = -1
+		cookie.Path = "/"
+		http.SetCookie(w, cookie)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile/sessions", http.StatusSeeOther)
+}
+
+func (h *SessionHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(sessions.CookieName)
+	if err != nil {
+		http.Error(w, "Failed to read cookie", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := h.svc.RevokeOtherSessions(r.Context(), userID, cookie.Value); err != nil {
+		http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/profile/sessions", http.StatusSeeOther)
+}
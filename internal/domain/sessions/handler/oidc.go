@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"goserv/internal/domain/sessions"
+	"goserv/internal/domain/sessions/service"
+	"goserv/internal/domain/users"
+	"goserv/internal/middleware"
+	"goserv/pkg/oidc"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BeginOIDC sends the user to the provider named in the URL. Users who are already logged in
+// link the external account to theirs instead.
+func (h *SessionHandler) BeginOIDC(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+	providerName := chi.URLParam(r, "provider")
+
+	redirect, err := h.oidcSvc.Begin(r.Context(), providerName, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to start %s login: %v\n", providerName, err)
+		http.Error(w, "Login provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, sessions.NewOIDCFlowCookie(redirect.Token, redirect.ExpiresAt))
+	http.Redirect(w, r, redirect.URL, http.StatusFound)
+}
+
+// OIDCCallback is where the provider sends the user back to with an authorization code.
+func (h *SessionHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessions.OIDCFlowCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	http.SetCookie(w, sessions.ClearOIDCFlowCookie())
+
+	flow, err := h.oidcSvc.ParseFlow(cookie.Value)
+	if err != nil || flow.Provider != chi.URLParam(r, "provider") {
+		http.Error(w, sessions.ErrInvalidFlow.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		log.Printf("Provider %s turned down a login: %s %s\n", flow.Provider, reason, query.Get("error_description"))
+		http.Error(w, "Login was cancelled at the provider", http.StatusUnauthorized)
+		return
+	}
+
+	if flow.UserID != 0 {
+		h.linkOIDC(w, r, flow)
+		return
+	}
+
+	session, err := h.oidcSvc.Login(r.Context(), flow, query.Get("state"), query.Get("code"), sessions.ClientFromRequest(r))
+	if err != nil {
+		if redirectSecondFactor(w, r, err) {
+			return
+		}
+		writeOIDCError(w, flow, err)
+		return
+	}
+
+	http.SetCookie(w, sessions.NewCookie(session.ID, session.ExpiresAt))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *SessionHandler) linkOIDC(w http.ResponseWriter, r *http.Request, flow *sessions.OIDCFlow) {
+	userID, _ := middleware.GetUserID(r)
+	query := r.URL.Query()
+
+	if err := h.oidcSvc.Link(r.Context(), flow, query.Get("state"), query.Get("code"), userID); err != nil {
+		writeOIDCError(w, flow, err)
+		return
+	}
+	http.Redirect(w, r, "/profile/identities", http.StatusSeeOther)
+}
+
+func writeOIDCError(w http.ResponseWriter, flow *sessions.OIDCFlow, err error) {
+	var providerErr *oidc.Error
+	switch {
+	case errors.Is(err, sessions.ErrInvalidFlow):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrNoLinkedAccount):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrAccountDisabled):
+		http.Error(w, "This account has been disabled", http.StatusForbidden)
+	case errors.Is(err, users.ErrIdentityTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &providerErr), errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrDiscovery):
+		log.Printf("Failed %s login: %v\n", flow.Provider, err)
+		http.Error(w, "Error logging in with the provider", http.StatusBadGateway)
+	default:
+		log.Printf("Failed %s login: %v\n", flow.Provider, err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+	}
+}
+
+type identityEntry struct {
+	Provider    service.OIDCProvider
+	Linked      bool
+	Email       string
+	LastLoginAt string
+}
+
+// ListIdentities shows which providers the user can log in with and lets them link the others.
+func (h *SessionHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	identities, err := h.oidcSvc.ListIdentities(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error listing linked accounts", http.StatusInternalServerError)
+		return
+	}
+
+	providers := h.oidcSvc.Providers()
+	entries := make([]identityEntry, len(providers))
+	for i := range providers {
+		entries[i] = identityEntry{Provider: providers[i]}
+		for j := range identities {
+			if identities[j].Provider != providers[i].Name {
+				continue
+			}
+			entries[i].Linked = true
+			entries[i].Email = identities[j].Email
+			if identities[j].LastLoginAt != nil {
+				entries[i].LastLoginAt = identities[j].LastLoginAt.Format("2006-01-02 15:04")
+			}
+		}
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "identities.html", struct {
+		Identities []identityEntry
+	}{
+		Identities: entries,
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
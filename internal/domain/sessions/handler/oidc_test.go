@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"goserv/internal/domain/sessions"
+	"goserv/internal/domain/sessions/repository"
+	"goserv/internal/domain/sessions/service"
+	tokenRepo "goserv/internal/domain/tokens/repository"
+	"goserv/internal/domain/users"
+	uRepo "goserv/internal/domain/users/repository"
+	"goserv/internal/middleware"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/pkg/oidc"
+	"goserv/pkg/oidc/oidctest"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOIDCLogin drives a browser through the whole external login against a fake provider:
+// a first visit signs up, a second logs the same account back in, and a logged in user links another account.
+func TestOIDCLogin(t *testing.T) {
+	provider := oidctest.NewServer()
+	defer provider.Close()
+
+	accounts := map[int]*users.User{
+		1: {ID: 1, Username: "bob", TOTPEnabled: true},
+	}
+	identities := []users.Identity{{ID: 1, UserID: 1, Provider: "family", Subject: "bob-sub"}}
+	stored := map[string]sessions.Session{}
+
+	userRepo := &uRepo.UserMock{
+		GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+			if user, ok := accounts[userID]; ok {
+				return user, nil
+			}
+			return nil, myErrors.ErrNotFound
+		},
+		GetByEmailFunc: func(ctx context.Context, email string) (*users.User, error) {
+			return nil, myErrors.ErrNotFound
+		},
+	}
+	identityRepo := &uRepo.IdentityMock{
+		GetIdentityFunc: func(ctx context.Context, provider string, subject string) (*users.Identity, error) {
+			for i := range identities {
+				if identities[i].Provider == provider && identities[i].Subject == subject {
+					return &identities[i], nil
+				}
+			}
+			return nil, myErrors.ErrNotFound
+		},
+		ListUserIdentitiesFunc: func(ctx context.Context, userID int) ([]users.Identity, error) {
+			var found []users.Identity
+			for i := range identities {
+				if identities[i].UserID == userID {
+					found = append(found, identities[i])
+				}
+			}
+			return found, nil
+		},
+		AddIdentityFunc: func(ctx context.Context, identity *users.Identity) error {
+			identities = append(identities, *identity)
+			return nil
+		},
+		CreateUserFunc: func(ctx context.Context, user *users.User, identity *users.Identity) (int, error) {
+			created := *user
+			created.ID = len(accounts) + 1
+			accounts[created.ID] = &created
+			identity.UserID = created.ID
+			identities = append(identities, *identity)
+			return created.ID, nil
+		},
+		TouchIdentityFunc: func(ctx context.Context, identityID int, now time.Time) error {
+			return nil
+		},
+	}
+	sessionRepo := &repository.SessionMock{
+		LoginFunc: func(ctx context.Context, session *sessions.Session) error {
+			stored[session.ID] = *session
+			return nil
+		},
+		GetSessionFunc: func(ctx context.Context, sessionID string) (*sessions.Session, error) {
+			if session, ok := stored[sessionID]; ok {
+				return &session, nil
+			}
+			return nil, myErrors.ErrNotFound
+		},
+	}
+	throttleRepo := &repository.ThrottleMock{
+		DeleteThrottleFunc: func(ctx context.Context, kind string, key string) error {
+			return nil
+		},
+	}
+
+	router := chi.NewRouter()
+	app := httptest.NewServer(router)
+	defer app.Close()
+
+	client, err := oidc.NewClient(oidc.Config{
+		Issuer:       provider.Issuer,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  app.URL + "/login/oidc/family/callback",
+	}, provider.Client())
+	require.NoError(t, err)
+
+	secret := []byte("secret")
+	policy := sessions.Policy{Absolute: 24 * time.Hour, Idle: time.Hour}
+	sessionSvc := service.NewSessionService(sessionRepo, userRepo, throttleRepo, policy, sessions.LoginLimits{}, secret)
+	oidcSvc := service.NewOIDCService(sessionSvc, userRepo, identityRepo, []service.OIDCProvider{
+		{Name: "family", DisplayName: "Family", AllowSignup: true, Client: client},
+	}, users.RegistrationOpen, users.RoleViewer, secret)
+	tmpl := template.Must(template.New("identities.html").Parse(`{{range .Identities}}{{.Provider.Name}}:{{.Linked}}{{end}}`))
+	h := NewSessionHandler(sessionSvc, oidcSvc, nil, tmpl)
+
+	checkMiddleware := middleware.AuthCheckMiddleware(sessionRepo, &tokenRepo.TokenMock{}, policy)
+	authMiddleware := middleware.AuthRestrictMiddleware(sessionRepo, &tokenRepo.TokenMock{}, policy)
+	router.With(checkMiddleware).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserID(r)
+		io.WriteString(w, accounts[userID].Username)
+	})
+	router.Get("/login/2fa", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "second factor")
+	})
+	router.With(checkMiddleware).Get("/login/oidc/{provider}", h.BeginOIDC)
+	router.With(checkMiddleware).Get("/login/oidc/{provider}/callback", h.OIDCCallback)
+	router.With(authMiddleware).Get("/profile/identities", h.ListIdentities)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar}
+
+	visit := func(path string) (string, string) {
+		res, err := browser.Get(app.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.Request.URL.Path, string(body)
+	}
+
+	// first visit creates the account
+	provider.SetUser(oidctest.User{Subject: "alice-sub", PreferredUsername: "alice"})
+	path, body := visit("/login/oidc/family")
+	assert.Equal(t, "/", path)
+	assert.Equal(t, "alice", body)
+	assert.Len(t, accounts, 2)
+
+	// logging in again finds the same account
+	jar, _ = cookiejar.New(nil)
+	browser.Jar = jar
+	path, body = visit("/login/oidc/family")
+	assert.Equal(t, "/", path)
+	assert.Equal(t, "alice", body)
+	assert.Len(t, accounts, 2)
+
+	// a logged in user links the provider account instead of logging in
+	provider.SetUser(oidctest.User{Subject: "alice-work"})
+	path, body = visit("/login/oidc/family")
+	assert.Equal(t, "/profile/identities", path)
+	assert.Equal(t, "family:true", body)
+	assert.Len(t, identities, 3)
+	assert.Equal(t, 2, identities[2].UserID)
+
+	// accounts with two-factor login still need their code
+	jar, _ = cookiejar.New(nil)
+	browser.Jar = jar
+	provider.SetUser(oidctest.User{Subject: "bob-sub"})
+	path, _ = visit("/login/oidc/family")
+	assert.Equal(t, "/login/2fa", path)
+
+	// a callback without the flow cookie goes back to the login page
+	res, err := (&http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}).Get(app.URL + "/login/oidc/family/callback?state=x&code=y")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusSeeOther, res.StatusCode)
+	assert.Equal(t, "/login", res.Header.Get("Location"))
+}
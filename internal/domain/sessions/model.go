@@ -1,6 +1,50 @@
 package sessions
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// renewAfter limits how often a sliding session is written back while it is in use.
+const renewAfter = time.Minute
+
 type Session struct {
-	ID     string
-	UserID int
+	ID         string
+	UserID     int
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	IP         string
+}
+
+// Handle identifies a session on the sessions page without exposing the session ID itself.
+func (s *Session) Handle() string {
+	sum := sha256.Sum256([]byte(s.ID))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// Policy holds the session timeouts. A session ends after Idle without use,
+// and never lives past Absolute from when it was created.
+type Policy struct {
+	Absolute time.Duration
+	Idle     time.Duration
+}
+
+func (p Policy) ExpiresAt(createdAt time.Time, now time.Time) time.Time {
+	absolute := createdAt.Add(p.Absolute)
+	idle := now.Add(p.Idle)
+	if idle.Before(absolute) {
+		return idle
+	}
+	return absolute
+}
+
+func (p Policy) NeedsRenewal(session *Session, now time.Time) bool {
+	return now.Sub(session.LastSeenAt) >= renewAfter && session.ExpiresAt.Before(p.ExpiresAt(session.CreatedAt, now))
 }
@@ -0,0 +1,95 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_ExpiresAt(t *testing.T) {
+	type args struct {
+		createdAt time.Time
+		now       time.Time
+	}
+	type test struct {
+		name string
+		args args
+		want time.Time
+	}
+
+	policy := Policy{Absolute: 24 * time.Hour, Idle: time.Hour}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []test{
+		{
+			name: "new session ends after idle timeout",
+			args: args{createdAt: created, now: created},
+			want: created.Add(time.Hour),
+		},
+		{
+			name: "activity slides the idle timeout",
+			args: args{createdAt: created, now: created.Add(5 * time.Hour)},
+			want: created.Add(6 * time.Hour),
+		},
+		{
+			name: "absolute timeout caps renewal",
+			args: args{createdAt: created, now: created.Add(23*time.Hour + 30*time.Minute)},
+			want: created.Add(24 * time.Hour),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, policy.ExpiresAt(test.args.createdAt, test.args.now))
+		})
+	}
+}
+
+func TestPolicy_NeedsRenewal(t *testing.T) {
+	type test struct {
+		name    string
+		session Session
+		now     time.Time
+		want    bool
+	}
+
+	policy := Policy{Absolute: 24 * time.Hour, Idle: time.Hour}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []test{
+		{
+			name:    "recently seen",
+			session: Session{CreatedAt: created, LastSeenAt: created, ExpiresAt: created.Add(time.Hour)},
+			now:     created.Add(10 * time.Second),
+			want:    false,
+		},
+		{
+			name:    "seen a while ago",
+			session: Session{CreatedAt: created, LastSeenAt: created, ExpiresAt: created.Add(time.Hour)},
+			now:     created.Add(10 * time.Minute),
+			want:    true,
+		},
+		{
+			name:    "already at absolute limit",
+			session: Session{CreatedAt: created, LastSeenAt: created.Add(23 * time.Hour), ExpiresAt: created.Add(24 * time.Hour)},
+			now:     created.Add(23*time.Hour + 30*time.Minute),
+			want:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, policy.NeedsRenewal(&test.session, test.now))
+		})
+	}
+}
+
+func TestSession_Expired(t *testing.T) {
+	expires := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	session := Session{ExpiresAt: expires}
+
+	assert.False(t, session.Expired(expires.Add(-time.Second)))
+	assert.True(t, session.Expired(expires))
+	assert.True(t, session.Expired(expires.Add(time.Second)))
+}
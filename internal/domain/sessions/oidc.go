@@ -0,0 +1,61 @@
+package sessions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// OIDCFlowTTL is how long a user has to log in at the provider before the flow expires.
+const OIDCFlowTTL = 10 * time.Minute
+
+var ErrInvalidFlow = errors.New("external login expired, please try again")
+
+// OIDCFlow is an external login waiting for the provider to send the user back. Verifier is the
+// PKCE secret behind the code challenge, and UserID is set when a logged in user links an account.
+type OIDCFlow struct {
+	Provider  string    `json:"p"`
+	State     string    `json:"s"`
+	Nonce     string    `json:"n"`
+	Verifier  string    `json:"v"`
+	UserID    int       `json:"u,omitempty"`
+	ExpiresAt time.Time `json:"e"`
+}
+
+// Sign encodes the flow as "<payload>.<hmac>" so it can be kept in a cookie.
+func (f OIDCFlow) Sign(secret []byte) string {
+	raw, _ := json.Marshal(f)
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + flowMAC(secret, payload)
+}
+
+func ParseOIDCFlow(secret []byte, token string, now time.Time) (*OIDCFlow, error) {
+	payload, mac, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(flowMAC(secret, payload))) {
+		return nil, ErrInvalidFlow
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidFlow
+	}
+
+	var flow OIDCFlow
+	if err := json.Unmarshal(raw, &flow); err != nil {
+		return nil, ErrInvalidFlow
+	}
+	if !now.Before(flow.ExpiresAt) {
+		return nil, ErrInvalidFlow
+	}
+	return &flow, nil
+}
+
+func flowMAC(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("oidc-flow:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
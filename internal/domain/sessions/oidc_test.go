@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOIDCFlow(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	flow := OIDCFlow{Provider: "family", State: "state", Nonce: "nonce", Verifier: "verifier", UserID: 3, ExpiresAt: now.Add(OIDCFlowTTL)}
+	token := flow.Sign(secret)
+
+	type args struct {
+		secret []byte
+		token  string
+		now    time.Time
+	}
+	type want struct {
+		flow *OIDCFlow
+		err  error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "valid token",
+			args: args{secret: secret, token: token, now: now},
+			want: want{flow: &flow, err: nil},
+		},
+		{
+			name: "expired",
+			args: args{secret: secret, token: token, now: now.Add(OIDCFlowTTL)},
+			want: want{flow: nil, err: ErrInvalidFlow},
+		},
+		{
+			name: "other secret",
+			args: args{secret: []byte("other"), token: token, now: now},
+			want: want{flow: nil, err: ErrInvalidFlow},
+		},
+		{
+			name: "pending login token",
+			args: args{secret: secret, token: Pending{UserID: 3, ExpiresAt: now.Add(PendingTTL)}.Sign(secret), now: now},
+			want: want{flow: nil, err: ErrInvalidFlow},
+		},
+		{
+			name: "garbage",
+			args: args{secret: secret, token: "nothing", now: now},
+			want: want{flow: nil, err: ErrInvalidFlow},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flow, err := ParseOIDCFlow(test.args.secret, test.args.token, test.args.now)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.flow, flow)
+		})
+	}
+}
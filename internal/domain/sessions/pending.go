@@ -0,0 +1,80 @@
+package sessions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PendingTTL is how long a user has to enter their second factor after the password was accepted.
+const PendingTTL = 5 * time.Minute
+
+var ErrSecondFactor = errors.New("second factor required")
+var ErrInvalidPending = errors.New("login expired, please log in again")
+
+// Pending is a login that passed the password check and waits for a TOTP code. Enroll is set
+// when the account must set up an authenticator before it may log in.
+type Pending struct {
+	UserID    int
+	Enroll    bool
+	ExpiresAt time.Time
+}
+
+// SecondFactorError is returned by a password login that still needs a second step. Token
+// carries the signed Pending to the second step.
+type SecondFactorError struct {
+	Pending Pending
+	Token   string
+}
+
+func (e *SecondFactorError) Error() string {
+	return ErrSecondFactor.Error()
+}
+
+func (e *SecondFactorError) Is(target error) bool {
+	return target == ErrSecondFactor
+}
+
+// Sign encodes the pending login as "<payload>.<hmac>" so it can be handed to the client.
+func (p Pending) Sign(secret []byte) string {
+	enroll := 0
+	if p.Enroll {
+		enroll = 1
+	}
+	payload := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d:%d", p.UserID, enroll, p.ExpiresAt.Unix()))
+	return payload + "." + pendingMAC(secret, payload)
+}
+
+func ParsePending(secret []byte, token string, now time.Time) (*Pending, error) {
+	payload, mac, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(pendingMAC(secret, payload))) {
+		return nil, ErrInvalidPending
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidPending
+	}
+
+	var userID, enroll int
+	var expires int64
+	if _, err := fmt.Sscanf(string(raw), "%d:%d:%d", &userID, &enroll, &expires); err != nil {
+		return nil, ErrInvalidPending
+	}
+
+	pending := &Pending{UserID: userID, Enroll: enroll == 1, ExpiresAt: time.Unix(expires, 0)}
+	if !now.Before(pending.ExpiresAt) {
+		return nil, ErrInvalidPending
+	}
+	return pending, nil
+}
+
+func pendingMAC(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("pending-login:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
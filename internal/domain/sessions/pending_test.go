@@ -0,0 +1,65 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePending(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	token := Pending{UserID: 7, Enroll: true, ExpiresAt: now.Add(PendingTTL)}.Sign(secret)
+
+	type args struct {
+		secret []byte
+		token  string
+		now    time.Time
+	}
+	type want struct {
+		pending *Pending
+		err     error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "valid token",
+			args: args{secret: secret, token: token, now: now},
+			want: want{pending: &Pending{UserID: 7, Enroll: true, ExpiresAt: time.Unix(now.Add(PendingTTL).Unix(), 0)}, err: nil},
+		},
+		{
+			name: "expired",
+			args: args{secret: secret, token: token, now: now.Add(PendingTTL)},
+			want: want{pending: nil, err: ErrInvalidPending},
+		},
+		{
+			name: "other secret",
+			args: args{secret: []byte("other"), token: token, now: now},
+			want: want{pending: nil, err: ErrInvalidPending},
+		},
+		{
+			name: "tampered payload",
+			args: args{secret: secret, token: "x" + token, now: now},
+			want: want{pending: nil, err: ErrInvalidPending},
+		},
+		{
+			name: "garbage",
+			args: args{secret: secret, token: "nothing", now: now},
+			want: want{pending: nil, err: ErrInvalidPending},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pending, err := ParsePending(test.args.secret, test.args.token, test.args.now)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.pending, pending)
+		})
+	}
+}
@@ -5,13 +5,17 @@ import (
 	"goserv/ent/gen"
 	entSession "goserv/ent/gen/session"
 	"goserv/internal/domain/sessions"
+	"time"
 )
 
 type Session interface {
 	Login(ctx context.Context, session *sessions.Session) error
 	Logout(ctx context.Context, sessionID string) error
-	//GetUsernameBySessionID(ctx context.Context, sessionID string) (string, error)
-	GetUserIDBySessionID(ctx context.Context, sessionID string) (int, error)
+	GetSession(ctx context.Context, sessionID string) (*sessions.Session, error)
+	RenewSession(ctx context.Context, sessionID string, lastSeenAt time.Time, expiresAt time.Time) error
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	ListUserSessions(ctx context.Context, userID int) ([]sessions.Session, error)
+	DeleteUserSessions(ctx context.Context, userID int, exceptID string) (int, error)
 }
 
 type sessionRepo struct {
@@ -23,7 +27,15 @@ func NewSessionRepository(client *gen.Client) *sessionRepo {
 }
 
 func (repo *sessionRepo) Login(ctx context.Context, session *sessions.Session) error {
-	_, err := repo.client.Session.Create().SetID(session.ID).SetUserID(session.UserID).Save(ctx)
+	_, err := repo.client.Session.Create().
+		SetID(session.ID).
+		SetUserID(session.UserID).
+		SetCreatedAt(session.CreatedAt).
+		SetLastSeenAt(session.LastSeenAt).
+		SetExpiresAt(session.ExpiresAt).
+		SetUserAgent(session.UserAgent).
+		SetIP(session.IP).
+		Save(ctx)
 	return err
 }
 
@@ -32,16 +44,58 @@ func (repo *sessionRepo) Logout(ctx context.Context, sessionID string) error {
 	return err
 }
 
-// func (repo *sessionRepo) GetUsernameBySessionID(ctx context.Context, sessionID string) (string, error) {
-// 	var username string
-// 	err := repo.db.Where("session_id = ?", sessionID).First(&username).Error
-// 	return username, err
-// }
-
-func (repo *sessionRepo) GetUserIDBySessionID(ctx context.Context, sessionID string) (int, error) {
+func (repo *sessionRepo) GetSession(ctx context.Context, sessionID string) (*sessions.Session, error) {
 	session, err := repo.client.Session.Query().Where(entSession.IDEQ(sessionID)).Only(ctx)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	return toDomainSession(session), nil
+}
+
+func (repo *sessionRepo) RenewSession(ctx context.Context, sessionID string, lastSeenAt time.Time, expiresAt time.Time) error {
+	return repo.client.Session.UpdateOneID(sessionID).
+		SetLastSeenAt(lastSeenAt).
+		SetExpiresAt(expiresAt).
+		Exec(ctx)
+}
+
+func (repo *sessionRepo) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	return repo.client.Session.Delete().Where(entSession.ExpiresAtLTE(now)).Exec(ctx)
+}
+
+func (repo *sessionRepo) ListUserSessions(ctx context.Context, userID int) ([]sessions.Session, error) {
+	entSessions, err := repo.client.Session.Query().
+		Where(entSession.UserIDEQ(userID)).
+		Order(gen.Desc(entSession.FieldLastSeenAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userSessions := make([]sessions.Session, len(entSessions))
+	for i, session := range entSessions {
+		userSessions[i] = *toDomainSession(session)
+	}
+	return userSessions, nil
+}
+
+// DeleteUserSessions removes every session of the user apart from exceptID, which may be empty.
+func (repo *sessionRepo) DeleteUserSessions(ctx context.Context, userID int, exceptID string) (int, error) {
+	query := repo.client.Session.Delete().Where(entSession.UserIDEQ(userID))
+	if exceptID != "" {
+		query = query.Where(entSession.IDNEQ(exceptID))
+	}
+	return query.Exec(ctx)
+}
+
+func toDomainSession(session *gen.Session) *sessions.Session {
+	return &sessions.Session{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
 	}
-	return session.UserID, nil
 }
@@ -3,12 +3,17 @@ package repository
 import (
 	"context"
 	"goserv/internal/domain/sessions"
+	"time"
 )
 
 type SessionMock struct {
-	LoginFunc                func(ctx context.Context, session *sessions.Session) error
-	LogoutFunc               func(ctx context.Context, sessionID string) error
-	GetUserIDBySessionIDFunc func(ctx context.Context, sessionID string) (int, error)
+	LoginFunc              func(ctx context.Context, session *sessions.Session) error
+	LogoutFunc             func(ctx context.Context, sessionID string) error
+	GetSessionFunc         func(ctx context.Context, sessionID string) (*sessions.Session, error)
+	RenewSessionFunc       func(ctx context.Context, sessionID string, lastSeenAt time.Time, expiresAt time.Time) error
+	DeleteExpiredFunc      func(ctx context.Context, now time.Time) (int, error)
+	ListUserSessionsFunc   func(ctx context.Context, userID int) ([]sessions.Session, error)
+	DeleteUserSessionsFunc func(ctx context.Context, userID int, exceptID string) (int, error)
 }
 
 func (m *SessionMock) Login(ctx context.Context, session *sessions.Session) error {
@@ -19,6 +24,22 @@ func (m *SessionMock) Logout(ctx context.Context, sessionID string) error {
 	return m.LogoutFunc(ctx, sessionID)
 }
 
-func (m *SessionMock) GetUserIDBySessionID(ctx context.Context, sessionID string) (int, error) {
-	return m.GetUserIDBySessionIDFunc(ctx, sessionID)
+func (m *SessionMock) GetSession(ctx context.Context, sessionID string) (*sessions.Session, error) {
+	return m.GetSessionFunc(ctx, sessionID)
+}
+
+func (m *SessionMock) RenewSession(ctx context.Context, sessionID string, lastSeenAt time.Time, expiresAt time.Time) error {
+	return m.RenewSessionFunc(ctx, sessionID, lastSeenAt, expiresAt)
+}
+
+func (m *SessionMock) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	return m.DeleteExpiredFunc(ctx, now)
+}
+
+func (m *SessionMock) ListUserSessions(ctx context.Context, userID int) ([]sessions.Session, error) {
+	return m.ListUserSessionsFunc(ctx, userID)
+}
+
+func (m *SessionMock) DeleteUserSessions(ctx context.Context, userID int, exceptID string) (int, error) {
+	return m.DeleteUserSessionsFunc(ctx, userID, exceptID)
 }
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"goserv/ent/gen"
+	entThrottle "goserv/ent/gen/loginthrottle"
+	"goserv/internal/domain/sessions"
+	"goserv/internal/utils/errors"
+	"time"
+)
+
+type Throttle interface {
+	GetThrottle(ctx context.Context, kind string, key string) (*sessions.Throttle, error)
+	AddFailure(ctx context.Context, kind string, key string, now time.Time, resetBefore time.Time) (*sessions.Throttle, error)
+	ExtendLock(ctx context.Context, kind string, key string, until time.Time) error
+	DeleteThrottle(ctx context.Context, kind string, key string) error
+	ListLocked(ctx context.Context, kind string, now time.Time) ([]sessions.Throttle, error)
+	DeleteStaleThrottles(ctx context.Context, now time.Time, before time.Time) (int, error)
+}
+
+type throttleRepo struct {
+	client *gen.Client
+}
+
+func NewThrottleRepository(client *gen.Client) *throttleRepo {
+	return &throttleRepo{client: client}
+}
+
+func (repo *throttleRepo) GetThrottle(ctx context.Context, kind string, key string) (*sessions.Throttle, error) {
+	throttle, err := repo.client.LoginThrottle.
+		Query().
+		Where(entThrottle.KindEQ(kind), entThrottle.KeyEQ(key)).
+		Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return toDomainThrottle(throttle), nil
+}
+
+// AddFailure counts one more failed login for the kind and key, starting the count over when the last
+// failure was before resetBefore. The increment happens in the database, so failures racing each other
+// are all counted, and the row comes back as it was after this failure.
+func (repo *throttleRepo) AddFailure(ctx context.Context, kind string, key string, now time.Time, resetBefore time.Time) (*sessions.Throttle, error) {
+	_, err := repo.client.LoginThrottle.
+		Update().
+		Where(entThrottle.KindEQ(kind), entThrottle.KeyEQ(key), entThrottle.LastFailureAtLT(resetBefore)).
+		SetFailures(0).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the row can be created or deleted by other requests between finding it and changing it
+	for range 3 {
+		id, err := repo.client.LoginThrottle.
+			Query().
+			Where(entThrottle.KindEQ(kind), entThrottle.KeyEQ(key)).
+			OnlyID(ctx)
+		if gen.IsNotFound(err) {
+			throttle, err := repo.client.LoginThrottle.
+				Create().
+				SetKind(kind).
+				SetKey(key).
+				SetFailures(1).
+				SetLastFailureAt(now).
+				Save(ctx)
+			if gen.IsConstraintError(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return toDomainThrottle(throttle), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		throttle, err := repo.client.LoginThrottle.
+			UpdateOneID(id).
+			AddFailures(1).
+			SetLastFailureAt(now).
+			Save(ctx)
+		if gen.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return toDomainThrottle(throttle), nil
+	}
+	return nil, fmt.Errorf("login throttle for %s %q kept changing", kind, key)
+}
+
+// ExtendLock locks the kind and key until the given time, unless they are already locked for longer.
+func (repo *throttleRepo) ExtendLock(ctx context.Context, kind string, key string, until time.Time) error {
+	_, err := repo.client.LoginThrottle.
+		Update().
+		Where(
+			entThrottle.KindEQ(kind),
+			entThrottle.KeyEQ(key),
+			entThrottle.Or(entThrottle.LockedUntilIsNil(), entThrottle.LockedUntilLT(until)),
+		).
+		SetLockedUntil(until).
+		Save(ctx)
+	return err
+}
+
+func (repo *throttleRepo) DeleteThrottle(ctx context.Context, kind string, key string) error {
+	_, err := repo.client.LoginThrottle.
+		Delete().
+		Where(entThrottle.KindEQ(kind), entThrottle.KeyEQ(key)).
+		Exec(ctx)
+	return err
+}
+
+func (repo *throttleRepo) ListLocked(ctx context.Context, kind string, now time.Time) ([]sessions.Throttle, error) {
+	entThrottles, err := repo.client.LoginThrottle.
+		Query().
+		Where(entThrottle.KindEQ(kind), entThrottle.LockedUntilGT(now)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	throttles := make([]sessions.Throttle, len(entThrottles))
+	for i := range entThrottles {
+		throttles[i] = *toDomainThrottle(entThrottles[i])
+	}
+	return throttles, nil
+}
+
+// DeleteStaleThrottles removes throttles that are no longer locked and saw no failure since before.
+func (repo *throttleRepo) DeleteStaleThrottles(ctx context.Context, now time.Time, before time.Time) (int, error) {
+	return repo.client.LoginThrottle.
+		Delete().
+		Where(
+			entThrottle.LastFailureAtLT(before),
+			entThrottle.Or(entThrottle.LockedUntilIsNil(), entThrottle.LockedUntilLT(now)),
+		).
+		Exec(ctx)
+}
+
+func toDomainThrottle(throttle *gen.LoginThrottle) *sessions.Throttle {
+	return &sessions.Throttle{
+		Kind:          throttle.Kind,
+		Key:           throttle.Key,
+		Failures:      throttle.Failures,
+		LastFailureAt: throttle.LastFailureAt,
+		LockedUntil:   throttle.LockedUntil,
+	}
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"goserv/internal/domain/sessions"
+	"time"
+)
+
+type ThrottleMock struct {
+	GetThrottleFunc          func(ctx context.Context, kind string, key string) (*sessions.Throttle, error)
+	AddFailureFunc           func(ctx context.Context, kind string, key string, now time.Time, resetBefore time.Time) (*sessions.Throttle, error)
+	ExtendLockFunc           func(ctx context.Context, kind string, key string, until time.Time) error
+	DeleteThrottleFunc       func(ctx context.Context, kind string, key string) error
+	ListLockedFunc           func(ctx context.Context, kind string, now time.Time) ([]sessions.Throttle, error)
+	DeleteStaleThrottlesFunc func(ctx context.Context, now time.Time, before time.Time) (int, error)
+}
+
+func (m *ThrottleMock) GetThrottle(ctx context.Context, kind string, key string) (*sessions.Throttle, error) {
+	return m.GetThrottleFunc(ctx, kind, key)
+}
+
+func (m *ThrottleMock) AddFailure(ctx context.Context, kind string, key string, now time.Time, resetBefore time.Time) (*sessions.Throttle, error) {
+	return m.AddFailureFunc(ctx, kind, key, now, resetBefore)
+}
+
+func (m *ThrottleMock) ExtendLock(ctx context.Context, kind string, key string, until time.Time) error {
+	return m.ExtendLockFunc(ctx, kind, key, until)
+}
+
+func (m *ThrottleMock) DeleteThrottle(ctx context.Context, kind string, key string) error {
+	return m.DeleteThrottleFunc(ctx, kind, key)
+}
+
+func (m *ThrottleMock) ListLocked(ctx context.Context, kind string, now time.Time) ([]sessions.Throttle, error) {
+	return m.ListLockedFunc(ctx, kind, now)
+}
+
+func (m *ThrottleMock) DeleteStaleThrottles(ctx context.Context, now time.Time, before time.Time) (int, error) {
+	return m.DeleteStaleThrottlesFunc(ctx, now, before)
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"goserv/internal/domain/sessions"
+	"goserv/internal/domain/users"
+	uRepo "goserv/internal/domain/users/repository"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/oidc"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownProvider = errors.New("unknown login provider")
+var ErrNoLinkedAccount = errors.New("no account is linked to this login, log in with your password and link it from your profile")
+
+// signupAttempts is how many numbered variants of a taken username are tried for a new account.
+const signupAttempts = 10
+
+// maxSignupUsernameLen leaves room for the number added to a taken username.
+const maxSignupUsernameLen = validate.MaxUsernameLen - 2
+
+// OIDCProvider is an OpenID Connect provider users may log in with. Name appears in URLs and is
+// stored with linked identities, so it must not change once users have linked accounts.
+type OIDCProvider struct {
+	Name        string
+	DisplayName string
+	// AllowSignup creates an account for unknown users instead of turning them away. It only applies
+	// while registration is open, as the provider cannot hand over an invite code.
+	AllowSignup bool
+	Client      *oidc.Client
+}
+
+// OIDCRedirect sends the user to their provider. Token is the signed flow to keep until they return.
+type OIDCRedirect struct {
+	URL       string
+	Token     string
+	ExpiresAt time.Time
+}
+
+type OIDCService struct {
+	sessionSvc   *SessionService
+	userRepo     uRepo.User
+	identityRepo uRepo.Identity
+	providers    []OIDCProvider
+	// registrationMode is one of the users.Registration modes, signups are refused unless it is open
+	registrationMode string
+	// defaultRole is given to users created on their first external login
+	defaultRole string
+	secret      []byte
+	now         func() time.Time
+}
+
+func NewOIDCService(
+	sessionSvc *SessionService,
+	userRepo uRepo.User,
+	identityRepo uRepo.Identity,
+	providers []OIDCProvider,
+	registrationMode string,
+	defaultRole string,
+	secret []byte,
+) *OIDCService {
+	return &OIDCService{
+		sessionSvc:       sessionSvc,
+		userRepo:         userRepo,
+		identityRepo:     identityRepo,
+		providers:        providers,
+		registrationMode: registrationMode,
+		defaultRole:      defaultRole,
+		secret:           secret,
+		now:              time.Now,
+	}
+}
+
+func (s *OIDCService) Providers() []OIDCProvider {
+	return s.providers
+}
+
+func (s *OIDCService) provider(name string) (*OIDCProvider, error) {
+	for i := range s.providers {
+		if s.providers[i].Name == name {
+			return &s.providers[i], nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// Begin starts an external login with the named provider. A non-zero linkUserID links the external
+// account to that user when they come back instead of logging in.
+func (s *OIDCService) Begin(ctx context.Context, providerName string, linkUserID int) (*OIDCRedirect, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := sessions.OIDCFlow{
+		Provider:  provider.Name,
+		State:     oidc.RandomString(),
+		Nonce:     oidc.RandomString(),
+		Verifier:  oidc.RandomString(),
+		UserID:    linkUserID,
+		ExpiresAt: s.now().Add(sessions.OIDCFlowTTL),
+	}
+	authURL, err := provider.Client.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCRedirect{URL: authURL, Token: flow.Sign(s.secret), ExpiresAt: flow.ExpiresAt}, nil
+}
+
+// ParseFlow checks a token handed out by Begin.
+func (s *OIDCService) ParseFlow(token string) (*sessions.OIDCFlow, error) {
+	return sessions.ParseOIDCFlow(s.secret, token, s.now())
+}
+
+// Login finishes an external login with the state and code the provider sent the user back with.
+// Unknown users get an account when the provider allows signups and registration is open. Accounts with two-factor login
+// get a *sessions.SecondFactorError like a password login does.
+func (s *OIDCService) Login(ctx context.Context, flow *sessions.OIDCFlow, state string, code string, client sessions.Client) (*sessions.Session, error) {
+	if flow.UserID != 0 {
+		return nil, sessions.ErrInvalidFlow
+	}
+
+	provider, claims, err := s.claims(ctx, flow, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	identity, err := s.identityRepo.GetIdentity(ctx, provider.Name, claims.Subject)
+	if err != nil && !errors.Is(err, myErrors.ErrNotFound) {
+		return nil, err
+	}
+
+	var user *users.User
+	if identity != nil {
+		if err := s.identityRepo.TouchIdentity(ctx, identity.ID, now); err != nil {
+			log.Printf("Failed to update last login of identity %d: %v\n", identity.ID, err)
+		}
+		user, err = s.userRepo.GetByUserID(ctx, identity.UserID)
+	} else {
+		if !provider.AllowSignup {
+			log.Printf("Refused %s login for unlinked subject %q\n", provider.Name, claims.Subject)
+			return nil, ErrNoLinkedAccount
+		}
+		if s.registrationMode != users.RegistrationOpen {
+			log.Printf("Refused %s signup for subject %q while registration is %s\n", provider.Name, claims.Subject, s.registrationMode)
+			return nil, ErrNoLinkedAccount
+		}
+		user, err = s.signup(ctx, provider, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.sessionSvc.loginUser(ctx, user, client, now)
+}
+
+// Link finishes an external login started by userID, attaching the external account to them.
+func (s *OIDCService) Link(ctx context.Context, flow *sessions.OIDCFlow, state string, code string, userID int) error {
+	if flow.UserID == 0 || flow.UserID != userID {
+		return sessions.ErrInvalidFlow
+	}
+
+	provider, claims, err := s.claims(ctx, flow, state, code)
+	if err != nil {
+		return err
+	}
+
+	identity, err := s.identityRepo.GetIdentity(ctx, provider.Name, claims.Subject)
+	if err == nil {
+		if identity.UserID != userID {
+			return users.ErrIdentityTaken
+		}
+		return nil
+	}
+	if !errors.Is(err, myErrors.ErrNotFound) {
+		return err
+	}
+
+	log.Printf("Linking %s subject %q to user %d\n", provider.Name, claims.Subject, userID)
+	return s.identityRepo.AddIdentity(ctx, &users.Identity{
+		UserID:   userID,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+}
+
+func (s *OIDCService) ListIdentities(ctx context.Context, userID int) ([]users.Identity, error) {
+	return s.identityRepo.ListUserIdentities(ctx, userID)
+}
+
+func (s *OIDCService) claims(ctx context.Context, flow *sessions.OIDCFlow, state string, code string) (*OIDCProvider, *oidc.Claims, error) {
+	if subtle.ConstantTimeCompare([]byte(state), []byte(flow.State)) != 1 {
+		return nil, nil, sessions.ErrInvalidFlow
+	}
+
+	provider, err := s.provider(flow.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := provider.Client.Login(ctx, code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return provider, claims, nil
+}
+
+// signup creates a user for an external account nobody has linked yet. The username comes from the
+// provider, numbered when it is taken, and a verified email is kept unless another user has it.
+func (s *OIDCService) signup(ctx context.Context, provider *OIDCProvider, claims *oidc.Claims) (*users.User, error) {
+	user := &users.User{Role: s.defaultRole}
+	if email := strings.ToLower(strings.TrimSpace(claims.Email)); email != "" && claims.EmailVerified {
+		_, err := s.userRepo.GetByEmail(ctx, email)
+		if errors.Is(err, myErrors.ErrNotFound) {
+			user.Email = email
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	base := signupUsername(claims)
+	for i := 1; i <= signupAttempts; i++ {
+		user.Username = base
+		if i > 1 {
+			user.Username = base + strconv.Itoa(i)
+		}
+
+		identity := &users.Identity{Provider: provider.Name, Subject: claims.Subject, Email: claims.Email}
+		userID, err := s.identityRepo.CreateUser(ctx, user, identity)
+		if errors.Is(err, users.ErrUsernameTaken) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Created user %d %q for %s subject %q\n", userID, user.Username, provider.Name, claims.Subject)
+		return s.userRepo.GetByUserID(ctx, userID)
+	}
+	return nil, users.ErrUsernameTaken
+}
+
+// signupUsername picks a username from the provider's preferred username or the email's local part,
+// keeping only the characters usernames allow. Names that still break the rules become "user".
+func signupUsername(claims *oidc.Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			b.WriteRune(r)
+		}
+		if b.Len() == maxSignupUsernameLen {
+			break
+		}
+	}
+	if validate.Username(b.String()) != nil {
+		return "user"
+	}
+	return b.String()
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"goserv/internal/domain/sessions"
+	"goserv/internal/domain/sessions/repository"
+	"goserv/internal/domain/users"
+	uRepo "goserv/internal/domain/users/repository"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/pkg/oidc"
+	"goserv/pkg/oidc/oidctest"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oidcFixture is an OIDCService talking to a fake provider, with users and identities kept in memory.
+type oidcFixture struct {
+	svc        *OIDCService
+	provider   *oidctest.Server
+	users      map[int]*users.User
+	identities []users.Identity
+}
+
+func newOIDCFixture(t *testing.T, allowSignup bool, registrationMode string) *oidcFixture {
+	provider := oidctest.NewServer()
+	t.Cleanup(provider.Close)
+
+	client, err := oidc.NewClient(oidc.Config{
+		Issuer:       provider.Issuer,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://localhost:8080/login/oidc/family/callback",
+	}, provider.Client())
+	require.NoError(t, err)
+
+	f := &oidcFixture{
+		provider: provider,
+		users: map[int]*users.User{
+			1: {ID: 1, Username: "alice", Email: "alice@example.com", Role: users.RoleUploader},
+			2: {ID: 2, Username: "bob", Role: users.RoleUploader, TOTPEnabled: true},
+			3: {ID: 3, Username: "carol", Role: users.RoleUploader, Disabled: true},
+		},
+		identities: []users.Identity{
+			{ID: 1, UserID: 1, Provider: "family", Subject: "alice-sub"},
+			{ID: 2, UserID: 2, Provider: "family", Subject: "bob-sub"},
+			{ID: 3, UserID: 3, Provider: "family", Subject: "carol-sub"},
+		},
+	}
+
+	userRepo := &uRepo.UserMock{
+		GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+			user, ok := f.users[userID]
+			if !ok {
+				return nil, myErrors.ErrNotFound
+			}
+			return user, nil
+		},
+		GetByEmailFunc: func(ctx context.Context, email string) (*users.User, error) {
+			for _, user := range f.users {
+				if user.Email == email {
+					return user, nil
+				}
+			}
+			return nil, myErrors.ErrNotFound
+		},
+	}
+	identityRepo := &uRepo.IdentityMock{
+		GetIdentityFunc: func(ctx context.Context, provider string, subject string) (*users.Identity, error) {
+			for i := range f.identities {
+				if f.identities[i].Provider == provider && f.identities[i].Subject == subject {
+					return &f.identities[i], nil
+				}
+			}
+			return nil, myErrors.ErrNotFound
+		},
+		AddIdentityFunc: func(ctx context.Context, identity *users.Identity) error {
+			identity.ID = len(f.identities) + 1
+			f.identities = append(f.identities, *identity)
+			return nil
+		},
+		CreateUserFunc: func(ctx context.Context, user *users.User, identity *users.Identity) (int, error) {
+			for _, existing := range f.users {
+				if existing.Username == user.Username {
+					return 0, users.ErrUsernameTaken
+				}
+			}
+			created := *user
+			created.ID = len(f.users) + 1
+			f.users[created.ID] = &created
+			identity.UserID = created.ID
+			identity.ID = len(f.identities) + 1
+			f.identities = append(f.identities, *identity)
+			return created.ID, nil
+		},
+		TouchIdentityFunc: func(ctx context.Context, identityID int, now time.Time) error {
+			return nil
+		},
+	}
+	sessionRepo := &repository.SessionMock{
+		LoginFunc: func(ctx context.Context, session *sessions.Session) error {
+			return nil
+		},
+	}
+
+	sessionSvc := NewSessionService(sessionRepo, userRepo, newThrottleMock(), testPolicy, testLimits, testSecret)
+	providers := []OIDCProvider{{Name: "family", DisplayName: "Family", AllowSignup: allowSignup, Client: client}}
+	f.svc = NewOIDCService(sessionSvc, userRepo, identityRepo, providers, registrationMode, users.RoleViewer, testSecret)
+	return f
+}
+
+// authorize starts a flow and lets the fake provider log subject in, returning the flow and the callback query.
+func (f *oidcFixture) authorize(t *testing.T, user oidctest.User, linkUserID int) (*sessions.OIDCFlow, url.Values) {
+	f.provider.SetUser(user)
+
+	redirect, err := f.svc.Begin(context.Background(), "family", linkUserID)
+	require.NoError(t, err)
+	flow, err := f.svc.ParseFlow(redirect.Token)
+	require.NoError(t, err)
+
+	browser := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := browser.Get(redirect.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(t, err)
+	return flow, location.Query()
+}
+
+func TestOIDCService_Login(t *testing.T) {
+	type args struct {
+		user         oidctest.User
+		allowSignup  bool
+		registration string
+		state        string
+	}
+	type want struct {
+		userID   int
+		username string
+		email    string
+		role     string
+		err      error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "linked user",
+			args: args{user: oidctest.User{Subject: "alice-sub"}},
+			want: want{userID: 1, username: "alice", email: "alice@example.com", role: users.RoleUploader, err: nil},
+		},
+		{
+			name: "unlinked user without signups",
+			args: args{user: oidctest.User{Subject: "dave-sub", PreferredUsername: "dave"}},
+			want: want{err: ErrNoLinkedAccount},
+		},
+		{
+			name: "signup",
+			args: args{user: oidctest.User{Subject: "dave-sub", PreferredUsername: "dave", Email: "Dave@example.com", EmailVerified: true}, allowSignup: true},
+			want: want{userID: 4, username: "dave", email: "dave@example.com", role: users.RoleViewer, err: nil},
+		},
+		{
+			name: "signup with taken username and email",
+			args: args{user: oidctest.User{Subject: "other-alice", PreferredUsername: "alice", Email: "alice@example.com", EmailVerified: true}, allowSignup: true},
+			want: want{userID: 4, username: "alice2", email: "", role: users.RoleViewer, err: nil},
+		},
+		{
+			name: "signup while registration is closed",
+			args: args{user: oidctest.User{Subject: "dave-sub", PreferredUsername: "dave"}, allowSignup: true, registration: users.RegistrationClosed},
+			want: want{err: ErrNoLinkedAccount},
+		},
+		{
+			name: "signup while registration is invite only",
+			args: args{user: oidctest.User{Subject: "dave-sub", PreferredUsername: "dave"}, allowSignup: true, registration: users.RegistrationInvite},
+			want: want{err: ErrNoLinkedAccount},
+		},
+		{
+			name: "linked user while registration is closed",
+			args: args{user: oidctest.User{Subject: "alice-sub"}, registration: users.RegistrationClosed},
+			want: want{userID: 1, username: "alice", email: "alice@example.com", role: users.RoleUploader, err: nil},
+		},
+		{
+			name: "signup keeps unverified email out",
+			args: args{user: oidctest.User{Subject: "erin-sub", Email: "erin+photos@example.com"}, allowSignup: true},
+			want: want{userID: 4, username: "erinphotos", email: "", role: users.RoleViewer, err: nil},
+		},
+		{
+			name: "two-factor user",
+			args: args{user: oidctest.User{Subject: "bob-sub"}},
+			want: want{err: sessions.ErrSecondFactor},
+		},
+		{
+			name: "disabled user",
+			args: args{user: oidctest.User{Subject: "carol-sub"}},
+			want: want{err: ErrAccountDisabled},
+		},
+		{
+			name: "wrong state",
+			args: args{user: oidctest.User{Subject: "alice-sub"}, state: "forged"},
+			want: want{err: sessions.ErrInvalidFlow},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registration := test.args.registration
+			if registration == "" {
+				registration = users.RegistrationOpen
+			}
+			f := newOIDCFixture(t, test.args.allowSignup, registration)
+			flow, callback := f.authorize(t, test.args.user, 0)
+
+			state := callback.Get("state")
+			if test.args.state != "" {
+				state = test.args.state
+			}
+			session, err := f.svc.Login(context.Background(), flow, state, callback.Get("code"), sessions.Client{IP: "127.0.0.1"})
+			if test.want.err != nil {
+				assert.ErrorIs(t, err, test.want.err)
+				assert.Nil(t, session)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want.userID, session.UserID)
+			assert.Equal(t, test.want.username, f.users[session.UserID].Username)
+			assert.Equal(t, test.want.email, f.users[session.UserID].Email)
+			assert.Equal(t, test.want.role, f.users[session.UserID].Role)
+		})
+	}
+}
+
+func TestOIDCService_Link(t *testing.T) {
+	type args struct {
+		user     oidctest.User
+		flowUser int
+		userID   int
+	}
+	type want struct {
+		identities int
+		err        error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "new identity",
+			args: args{user: oidctest.User{Subject: "alice-other", Email: "alice@family.example"}, flowUser: 1, userID: 1},
+			want: want{identities: 4, err: nil},
+		},
+		{
+			name: "already linked to the user",
+			args: args{user: oidctest.User{Subject: "alice-sub"}, flowUser: 1, userID: 1},
+			want: want{identities: 3, err: nil},
+		},
+		{
+			name: "linked to someone else",
+			args: args{user: oidctest.User{Subject: "bob-sub"}, flowUser: 1, userID: 1},
+			want: want{identities: 3, err: users.ErrIdentityTaken},
+		},
+		{
+			name: "flow of another user",
+			args: args{user: oidctest.User{Subject: "alice-other"}, flowUser: 2, userID: 1},
+			want: want{identities: 3, err: sessions.ErrInvalidFlow},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newOIDCFixture(t, false, users.RegistrationOpen)
+			flow, callback := f.authorize(t, test.args.user, test.args.flowUser)
+
+			err := f.svc.Link(context.Background(), flow, callback.Get("state"), callback.Get("code"), test.args.userID)
+			if test.want.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.want.err)
+			}
+			assert.Len(t, f.identities, test.want.identities)
+		})
+	}
+}
+
+func TestOIDCService_BeginUnknownProvider(t *testing.T) {
+	f := newOIDCFixture(t, false, users.RegistrationOpen)
+
+	redirect, err := f.svc.Begin(context.Background(), "elsewhere", 0)
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+	assert.Nil(t, redirect)
+}
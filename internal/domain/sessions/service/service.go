@@ -7,39 +7,291 @@ import (
 	"errors"
 	"goserv/internal/domain/sessions"
 	"goserv/internal/domain/sessions/repository"
+	"goserv/internal/domain/users"
 	uRepo "goserv/internal/domain/users/repository"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/pkg/totp"
+	"log"
+	"time"
 )
 
+var ErrAccountDisabled = errors.New("account is disabled")
+var ErrInvalidCode = errors.New("invalid two-factor code")
+
+// totpSkew accepts codes from one time step either side of the server clock.
+const totpSkew = 1
+
 type SessionService struct {
-	repo     repository.Session
-	userRepo uRepo.User
+	repo         repository.Session
+	userRepo     uRepo.User
+	throttleRepo repository.Throttle
+	policy       sessions.Policy
+	limits       sessions.LoginLimits
+	// secret signs pending two-factor logins
+	secret []byte
+	now    func() time.Time
 }
 
-func NewSessionService(repo repository.Session, userRepo uRepo.User) *SessionService {
-	return &SessionService{repo: repo, userRepo: userRepo}
+func NewSessionService(
+	repo repository.Session,
+	userRepo uRepo.User,
+	throttleRepo repository.Throttle,
+	policy sessions.Policy,
+	limits sessions.LoginLimits,
+	secret []byte,
+) *SessionService {
+	return &SessionService{
+		repo:         repo,
+		userRepo:     userRepo,
+		throttleRepo: throttleRepo,
+		policy:       policy,
+		limits:       limits,
+		secret:       secret,
+		now:          time.Now,
+	}
 }
 
-func (s *SessionService) Login(ctx context.Context, username string, password string) (string, error) {
+// Login checks the password unless the account or client IP is locked out after failed attempts,
+// in which case a *sessions.ThrottledError is returned without looking at the password.
+// Accounts with two-factor login get a *sessions.SecondFactorError instead of a session.
+func (s *SessionService) Login(ctx context.Context, username string, password string, client sessions.Client) (*sessions.Session, error) {
+	now := s.now()
+	if err := s.checkThrottles(ctx, username, client.IP, now); err != nil {
+		log.Printf("Blocked login for %q from %s: %v\n", username, client.IP, err)
+		return nil, err
+	}
+
 	user, isMatch, err := s.userRepo.CheckPassword(ctx, username, password)
 	if err != nil || !isMatch {
-		return "", errors.New("invalid credentials")
+		log.Printf("Failed login for %q from %s\n", username, client.IP)
+		s.recordFailure(ctx, username, client.IP, now)
+		return nil, errors.New("invalid credentials")
+	}
+	return s.loginUser(ctx, user, client, now)
+}
+
+// loginUser finishes a login whose first factor was accepted, by password or by an external provider.
+func (s *SessionService) loginUser(ctx context.Context, user *users.User, client sessions.Client, now time.Time) (*sessions.Session, error) {
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	if user.TOTPEnabled || user.TOTPRequired {
+		pending := sessions.Pending{UserID: user.ID, Enroll: !user.TOTPEnabled, ExpiresAt: now.Add(sessions.PendingTTL)}
+		return nil, &sessions.SecondFactorError{Pending: pending, Token: pending.Sign(s.secret)}
 	}
 
-	// generateID and save session
-	sessionID := generateSessionID()
-	session := &sessions.Session{ID: sessionID, UserID: user.ID}
-	err = s.repo.Login(ctx, session)
+	s.resetThrottle(ctx, user.Username)
+	return s.startSession(ctx, user.ID, client, now)
+}
+
+// ParsePending checks a token handed out with a *sessions.SecondFactorError.
+func (s *SessionService) ParsePending(token string) (*sessions.Pending, error) {
+	return sessions.ParsePending(s.secret, token, s.now())
+}
+
+// LoginSecondFactor finishes a pending login with a TOTP code or an unused recovery code.
+// Wrong codes count towards the same throttles as wrong passwords.
+func (s *SessionService) LoginSecondFactor(ctx context.Context, pending *sessions.Pending, code string, client sessions.Client) (*sessions.Session, error) {
+	if pending.Enroll {
+		return nil, sessions.ErrInvalidPending
+	}
+
+	user, err := s.userRepo.GetByUserID(ctx, pending.UserID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	now := s.now()
+	if err := s.checkThrottles(ctx, user.Username, client.IP, now); err != nil {
+		log.Printf("Blocked second factor for %q from %s: %v\n", user.Username, client.IP, err)
+		return nil, err
+	}
+
+	ok, err := s.verifyCode(ctx, user.ID, code, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		log.Printf("Failed second factor for %q from %s\n", user.Username, client.IP)
+		s.recordFailure(ctx, user.Username, client.IP, now)
+		return nil, ErrInvalidCode
+	}
+
+	s.resetThrottle(ctx, user.Username)
+	return s.startSession(ctx, user.ID, client, now)
+}
+
+// CompleteEnrollment logs in a pending user who had to set up an authenticator first. Callers must
+// have confirmed the new authenticator with a code before calling it.
+func (s *SessionService) CompleteEnrollment(ctx context.Context, pending *sessions.Pending, client sessions.Client) (*sessions.Session, error) {
+	if !pending.Enroll {
+		return nil, sessions.ErrInvalidPending
+	}
+
+	user, err := s.userRepo.GetByUserID(ctx, pending.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	if !user.TOTPEnabled {
+		return nil, sessions.ErrInvalidPending
+	}
+
+	s.resetThrottle(ctx, user.Username)
+	return s.startSession(ctx, user.ID, client, s.now())
+}
+
+func (s *SessionService) verifyCode(ctx context.Context, userID int, code string, now time.Time) (bool, error) {
+	secret, err := s.userRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !secret.Enabled {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(secret.Secret, code, now, totpSkew); ok {
+		// a code is only good once, even inside its time window
+		return s.userRepo.UseTOTPStep(ctx, userID, step)
 	}
+	return s.userRepo.UseRecoveryCode(ctx, userID, users.HashRecoveryCode(code))
+}
 
-	return sessionID, nil
+func (s *SessionService) startSession(ctx context.Context, userID int, client sessions.Client, now time.Time) (*sessions.Session, error) {
+	session := &sessions.Session{
+		ID:         generateSessionID(),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  s.policy.ExpiresAt(now, now),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+	}
+	if err := s.repo.Login(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
 func (s *SessionService) Logout(ctx context.Context, sessionID string) error {
 	return s.repo.Logout(ctx, sessionID)
 }
 
+// ListSessions returns the user's sessions that have not yet expired, most recently used first.
+func (s *SessionService) ListSessions(ctx context.Context, userID int) ([]sessions.Session, error) {
+	userSessions, err := s.repo.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	active := make([]sessions.Session, 0, len(userSessions))
+	for i := range userSessions {
+		if !userSessions[i].Expired(now) {
+			active = append(active, userSessions[i])
+		}
+	}
+	return active, nil
+}
+
+// RevokeSession logs out the user's session identified by handle and returns its ID.
+func (s *SessionService) RevokeSession(ctx context.Context, userID int, handle string) (string, error) {
+	userSessions, err := s.repo.ListUserSessions(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range userSessions {
+		if userSessions[i].Handle() == handle {
+			return userSessions[i].ID, s.repo.Logout(ctx, userSessions[i].ID)
+		}
+	}
+	return "", myErrors.ErrNotFound
+}
+
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, userID int, currentID string) (int, error) {
+	return s.repo.DeleteUserSessions(ctx, userID, currentID)
+}
+
+type throttleTarget struct {
+	kind   string
+	key    string
+	policy sessions.ThrottlePolicy
+}
+
+func (s *SessionService) throttleTargets(username string, ip string) []throttleTarget {
+	targets := []throttleTarget{{kind: sessions.ThrottleAccount, key: username, policy: s.limits.Account}}
+	if ip != "" {
+		targets = append(targets, throttleTarget{kind: sessions.ThrottleIP, key: ip, policy: s.limits.IP})
+	}
+	return targets
+}
+
+func (s *SessionService) checkThrottles(ctx context.Context, username string, ip string, now time.Time) error {
+	var retryAfter time.Duration
+	for _, target := range s.throttleTargets(username, ip) {
+		throttle, err := s.throttleRepo.GetThrottle(ctx, target.kind, target.key)
+		if err != nil {
+			if errors.Is(err, myErrors.ErrNotFound) {
+				continue
+			}
+			return err
+		}
+		if throttle.Locked(now) {
+			retryAfter = max(retryAfter, throttle.LockedUntil.Sub(now))
+		}
+	}
+
+	if retryAfter > 0 {
+		return &sessions.ThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordFailure counts a failed login against the account and the client IP, locking them once the
+// count is high enough. The count comes from the database, so parallel guesses all add to it.
+func (s *SessionService) recordFailure(ctx context.Context, username string, ip string, now time.Time) {
+	for _, target := range s.throttleTargets(username, ip) {
+		throttle, err := s.throttleRepo.AddFailure(ctx, target.kind, target.key, now, now.Add(-target.policy.ResetAfter))
+		if err != nil {
+			log.Printf("Failed to save login throttle for %s %q: %v\n", target.kind, target.key, err)
+			continue
+		}
+
+		lock := target.policy.LockFor(throttle.Failures)
+		if lock <= 0 {
+			continue
+		}
+		lockedUntil := now.Add(lock)
+		log.Printf("Locking logins for %s %q until %s after %d failures\n", target.kind, target.key, lockedUntil.Format(time.RFC3339), throttle.Failures)
+		if err := s.throttleRepo.ExtendLock(ctx, target.kind, target.key, lockedUntil); err != nil {
+			log.Printf("Failed to lock logins for %s %q: %v\n", target.kind, target.key, err)
+		}
+	}
+}
+
+func (s *SessionService) resetThrottle(ctx context.Context, username string) {
+	if err := s.throttleRepo.DeleteThrottle(ctx, sessions.ThrottleAccount, username); err != nil {
+		log.Printf("Failed to reset login throttle for %q: %v\n", username, err)
+	}
+}
+
+// ListLockedAccounts returns the accounts currently locked out by failed logins.
+func (s *SessionService) ListLockedAccounts(ctx context.Context) ([]sessions.Throttle, error) {
+	return s.throttleRepo.ListLocked(ctx, sessions.ThrottleAccount, s.now())
+}
+
+// UnlockAccount lifts a lockout and forgets the account's failed attempts.
+func (s *SessionService) UnlockAccount(ctx context.Context, username string) error {
+	return s.throttleRepo.DeleteThrottle(ctx, sessions.ThrottleAccount, username)
+}
+
 func generateSessionID() string {
 	b := make([]byte, 64)
 	rand.Read(b)
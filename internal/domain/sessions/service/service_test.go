@@ -7,11 +7,71 @@ import (
 	"goserv/internal/domain/sessions/repository"
 	"goserv/internal/domain/users"
 	uRepo "goserv/internal/domain/users/repository"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/pkg/totp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var testPolicy = sessions.Policy{Absolute: 7 * 24 * time.Hour, Idle: time.Hour}
+
+var testSecret = []byte("test secret")
+
+var testLimits = sessions.LoginLimits{
+	Account: sessions.ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+	IP:      sessions.ThrottlePolicy{FreeAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+}
+
+// newThrottleMock keeps throttles in memory so lockouts build up across logins. Like the database it
+// counts each failure under a lock, so failures recorded in parallel all count.
+func newThrottleMock() *repository.ThrottleMock {
+	var mu sync.Mutex
+	stored := map[string]sessions.Throttle{}
+	return &repository.ThrottleMock{
+		GetThrottleFunc: func(ctx context.Context, kind string, key string) (*sessions.Throttle, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			throttle, ok := stored[kind+":"+key]
+			if !ok {
+				return nil, myErrors.ErrNotFound
+			}
+			return &throttle, nil
+		},
+		AddFailureFunc: func(ctx context.Context, kind string, key string, now time.Time, resetBefore time.Time) (*sessions.Throttle, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			throttle := stored[kind+":"+key]
+			if throttle.LastFailureAt.Before(resetBefore) {
+				throttle.Failures = 0
+			}
+			throttle.Kind, throttle.Key = kind, key
+			throttle.Failures++
+			throttle.LastFailureAt = now
+			stored[kind+":"+key] = throttle
+			return &throttle, nil
+		},
+		ExtendLockFunc: func(ctx context.Context, kind string, key string, until time.Time) error {
+			mu.Lock()
+			defer mu.Unlock()
+			throttle := stored[kind+":"+key]
+			if throttle.LockedUntil == nil || throttle.LockedUntil.Before(until) {
+				throttle.LockedUntil = &until
+			}
+			stored[kind+":"+key] = throttle
+			return nil
+		},
+		DeleteThrottleFunc: func(ctx context.Context, kind string, key string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(stored, kind+":"+key)
+			return nil
+		},
+	}
+}
+
 func TestSessionService_Login(t *testing.T) {
 	type args struct {
 		username string
@@ -37,7 +97,7 @@ func TestSessionService_Login(t *testing.T) {
 	basicUser := users.User{
 		ID:       1,
 		Username: "username",
-		IsAdmin:  false,
+		Role:     users.RoleUploader,
 	}
 
 	tests := []test{
@@ -61,6 +121,16 @@ func TestSessionService_Login(t *testing.T) {
 				err:      nil,
 			},
 		},
+		{
+			name: "disabled account",
+			args: basicArgs,
+			want: want{
+				user:     &users.User{ID: 2, Username: "username", Disabled: true},
+				isMatch:  true,
+				checkErr: nil,
+				err:      ErrAccountDisabled,
+			},
+		},
 		{
 			name: "login error",
 			args: basicArgs,
@@ -87,21 +157,235 @@ func TestSessionService_Login(t *testing.T) {
 				},
 			}
 
-			service := NewSessionService(sessionRepo, userRepo)
+			service := NewSessionService(sessionRepo, userRepo, newThrottleMock(), testPolicy, testLimits, testSecret)
 
-			sessionID, err := service.Login(context.Background(), test.args.username, test.args.password)
+			session, err := service.Login(context.Background(), test.args.username, test.args.password, sessions.Client{UserAgent: "test", IP: "127.0.0.1"})
 			if test.want.checkErr == nil {
 				assert.Equal(t, test.want.err, err)
 			} else {
 				assert.Equal(t, test.want.checkErr, err)
 			}
 			if err == nil {
-				assert.NotEmpty(t, sessionID)
+				assert.NotEmpty(t, session.ID)
+				assert.Equal(t, test.want.user.ID, session.UserID)
+				assert.Equal(t, session.CreatedAt.Add(testPolicy.Idle), session.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestSessionService_LoginThrottled(t *testing.T) {
+	client := sessions.Client{UserAgent: "test", IP: "127.0.0.1"}
+	checked := 0
+	sessionRepo := &repository.SessionMock{
+		LoginFunc: func(ctx context.Context, session *sessions.Session) error {
+			return nil
+		},
+	}
+	userRepo := &uRepo.UserMock{
+		CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+			checked++
+			return &users.User{ID: 1, Username: username}, password == "password", nil
+		},
+	}
+	throttleRepo := newThrottleMock()
+
+	service := NewSessionService(sessionRepo, userRepo, throttleRepo, testPolicy, testLimits, testSecret)
+
+	for range testLimits.Account.FreeAttempts + 1 {
+		_, err := service.Login(context.Background(), "username", "wrong", client)
+		assert.EqualError(t, err, "invalid credentials")
+	}
+
+	// Locked out: even the right password is not checked.
+	_, err := service.Login(context.Background(), "username", "password", client)
+	var throttled *sessions.ThrottledError
+	assert.ErrorAs(t, err, &throttled)
+	assert.ErrorIs(t, err, sessions.ErrThrottled)
+	assert.InDelta(t, time.Minute, throttled.RetryAfter, float64(time.Second))
+	assert.Equal(t, testLimits.Account.FreeAttempts+1, checked)
+
+	// Other accounts from the same IP are still allowed below the IP limit.
+	_, err = service.Login(context.Background(), "other", "password", client)
+	assert.NoError(t, err)
+
+	assert.NoError(t, service.UnlockAccount(context.Background(), "username"))
+	session, err := service.Login(context.Background(), "username", "password", client)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, session.UserID)
+
+	_, err = throttleRepo.GetThrottle(context.Background(), sessions.ThrottleAccount, "username")
+	assert.ErrorIs(t, err, myErrors.ErrNotFound)
+}
+
+func TestSessionService_RecordFailureParallel(t *testing.T) {
+	type args struct {
+		stored   int
+		failures int
+	}
+	type want struct {
+		failures int
+		lock     time.Duration
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{name: "below the limit", args: args{stored: 0, failures: 2}, want: want{failures: 2, lock: 0}},
+		{name: "racing past the limit", args: args{stored: 1, failures: 5}, want: want{failures: 6, lock: 8 * time.Minute}},
+		{name: "racing past the cap", args: args{stored: 2, failures: 20}, want: want{failures: 22, lock: time.Hour}},
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			throttleRepo := newThrottleMock()
+			for range test.args.stored {
+				_, err := throttleRepo.AddFailure(context.Background(), sessions.ThrottleAccount, "username", now, now.Add(-time.Hour))
+				assert.NoError(t, err)
+			}
+
+			service := NewSessionService(nil, nil, throttleRepo, testPolicy, testLimits, testSecret)
+
+			// every guess got past the lockout check before any of them failed
+			var wg sync.WaitGroup
+			for range test.args.failures {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					service.recordFailure(context.Background(), "username", "", now)
+				}()
+			}
+			wg.Wait()
+
+			throttle, err := throttleRepo.GetThrottle(context.Background(), sessions.ThrottleAccount, "username")
+			assert.NoError(t, err)
+			assert.Equal(t, test.want.failures, throttle.Failures)
+			if test.want.lock == 0 {
+				assert.Nil(t, throttle.LockedUntil)
+			} else {
+				assert.Equal(t, now.Add(test.want.lock), *throttle.LockedUntil)
 			}
 		})
 	}
 }
 
+func TestSessionService_LoginSecondFactor(t *testing.T) {
+	// secret is the RFC 6238 test key, whose code at unix time 1111111111 is 050471.
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+	client := sessions.Client{UserAgent: "test", IP: "127.0.0.1"}
+
+	type args struct {
+		code string
+	}
+	type want struct {
+		err error
+	}
+	type test struct {
+		name     string
+		args     args
+		lastStep int64
+		want     want
+	}
+
+	tests := []test{
+		{name: "valid code", args: args{code: "050471"}, lastStep: 0, want: want{err: nil}},
+		{name: "replayed code", args: args{code: "050471"}, lastStep: totp.Step(now), want: want{err: ErrInvalidCode}},
+		{name: "recovery code", args: args{code: "abcde-fghij"}, lastStep: 0, want: want{err: nil}},
+		{name: "wrong code", args: args{code: "123456"}, lastStep: 0, want: want{err: ErrInvalidCode}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user := &users.User{ID: 1, Username: "username", TOTPEnabled: true}
+			sessionRepo := &repository.SessionMock{
+				LoginFunc: func(ctx context.Context, session *sessions.Session) error {
+					return nil
+				},
+			}
+			userRepo := &uRepo.UserMock{
+				CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+					return user, true, nil
+				},
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					return user, nil
+				},
+				GetTOTPFunc: func(ctx context.Context, userID int) (*users.TOTP, error) {
+					return &users.TOTP{Secret: secret, Enabled: true, LastStep: test.lastStep}, nil
+				},
+				UseTOTPStepFunc: func(ctx context.Context, userID int, step int64) (bool, error) {
+					return step > test.lastStep, nil
+				},
+				UseRecoveryCodeFunc: func(ctx context.Context, userID int, codeHash string) (bool, error) {
+					return codeHash == users.HashRecoveryCode("ABCDEFGHIJ"), nil
+				},
+			}
+
+			service := NewSessionService(sessionRepo, userRepo, newThrottleMock(), testPolicy, testLimits, testSecret)
+			service.now = func() time.Time { return now }
+
+			session, err := service.Login(context.Background(), "username", "password", client)
+			assert.Nil(t, session)
+			var secondFactor *sessions.SecondFactorError
+			assert.ErrorAs(t, err, &secondFactor)
+			assert.False(t, secondFactor.Pending.Enroll)
+
+			pending, err := service.ParsePending(secondFactor.Token)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, pending.UserID)
+
+			session, err = service.LoginSecondFactor(context.Background(), pending, test.args.code, client)
+			assert.Equal(t, test.want.err, err)
+			if err == nil {
+				assert.Equal(t, 1, session.UserID)
+				assert.Equal(t, now, session.CreatedAt)
+			}
+		})
+	}
+}
+
+func TestSessionService_CompleteEnrollment(t *testing.T) {
+	enabled := false
+	user := &users.User{ID: 1, Username: "username", TOTPRequired: true}
+	sessionRepo := &repository.SessionMock{
+		LoginFunc: func(ctx context.Context, session *sessions.Session) error {
+			return nil
+		},
+	}
+	userRepo := &uRepo.UserMock{
+		CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+			return user, true, nil
+		},
+		GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+			return &users.User{ID: user.ID, Username: user.Username, TOTPRequired: true, TOTPEnabled: enabled}, nil
+		},
+	}
+	client := sessions.Client{UserAgent: "test", IP: "127.0.0.1"}
+
+	service := NewSessionService(sessionRepo, userRepo, newThrottleMock(), testPolicy, testLimits, testSecret)
+
+	_, err := service.Login(context.Background(), "username", "password", client)
+	var secondFactor *sessions.SecondFactorError
+	assert.ErrorAs(t, err, &secondFactor)
+	assert.True(t, secondFactor.Pending.Enroll)
+
+	// a code is still needed while the authenticator is unconfirmed
+	_, err = service.LoginSecondFactor(context.Background(), &secondFactor.Pending, "123456", client)
+	assert.Equal(t, sessions.ErrInvalidPending, err)
+	_, err = service.CompleteEnrollment(context.Background(), &secondFactor.Pending, client)
+	assert.Equal(t, sessions.ErrInvalidPending, err)
+
+	enabled = true
+	session, err := service.CompleteEnrollment(context.Background(), &secondFactor.Pending, client)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, session.UserID)
+}
+
 func TestSessionService_Logout(t *testing.T) {
 	type args struct {
 		sessionID string
@@ -144,10 +428,81 @@ func TestSessionService_Logout(t *testing.T) {
 				},
 			}
 
-			service := NewSessionService(sessionRepo, nil)
+			service := NewSessionService(sessionRepo, nil, nil, testPolicy, testLimits, testSecret)
 
 			err := service.Logout(context.Background(), test.args.sessionID)
 			assert.Equal(t, test.want.err, err)
 		})
 	}
 }
+
+func TestSessionService_ListSessions(t *testing.T) {
+	now := time.Now()
+	sessionRepo := &repository.SessionMock{
+		ListUserSessionsFunc: func(ctx context.Context, userID int) ([]sessions.Session, error) {
+			return []sessions.Session{
+				{ID: "active", UserID: userID, ExpiresAt: now.Add(time.Hour)},
+				{ID: "expired", UserID: userID, ExpiresAt: now.Add(-time.Hour)},
+			}, nil
+		},
+	}
+
+	service := NewSessionService(sessionRepo, nil, nil, testPolicy, testLimits, testSecret)
+
+	active, err := service.ListSessions(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, active, 1)
+	assert.Equal(t, "active", active[0].ID)
+}
+
+func TestSessionService_RevokeSession(t *testing.T) {
+	type args struct {
+		handle string
+	}
+	type want struct {
+		sessionID string
+		err       error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	other := sessions.Session{ID: "other", UserID: 1}
+
+	tests := []test{
+		{
+			name: "revoke by handle",
+			args: args{handle: other.Handle()},
+			want: want{sessionID: "other", err: nil},
+		},
+		{
+			name: "unknown handle",
+			args: args{handle: "nothing"},
+			want: want{sessionID: "", err: myErrors.ErrNotFound},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loggedOut := ""
+			sessionRepo := &repository.SessionMock{
+				ListUserSessionsFunc: func(ctx context.Context, userID int) ([]sessions.Session, error) {
+					return []sessions.Session{{ID: "current", UserID: userID}, other}, nil
+				},
+				LogoutFunc: func(ctx context.Context, sessionID string) error {
+					loggedOut = sessionID
+					return nil
+				},
+			}
+
+			service := NewSessionService(sessionRepo, nil, nil, testPolicy, testLimits, testSecret)
+
+			sessionID, err := service.RevokeSession(context.Background(), 1, test.args.handle)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.sessionID, sessionID)
+			assert.Equal(t, test.want.sessionID, loggedOut)
+		})
+	}
+}
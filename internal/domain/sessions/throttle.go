@@ -0,0 +1,71 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrThrottled = errors.New("too many failed logins")
+
+// ThrottledError is returned while an account or client is locked out after failed logins.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%v, try again in %s", ErrThrottled, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// Failed logins are counted separately per account and per client IP.
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+type Throttle struct {
+	Kind          string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+func (t *Throttle) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+// ThrottlePolicy allows FreeAttempts failures, then locks for BaseDelay doubling with every
+// further failure up to MaxDelay. Counting starts over once ResetAfter passes without a failure.
+type ThrottlePolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	ResetAfter   time.Duration
+}
+
+func (p ThrottlePolicy) LockFor(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < over; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// LoginLimits holds the throttle policies for accounts and for client IPs.
+type LoginLimits struct {
+	Account ThrottlePolicy
+	IP      ThrottlePolicy
+}
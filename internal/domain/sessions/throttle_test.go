@@ -0,0 +1,38 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottlePolicy_LockFor(t *testing.T) {
+	type args struct {
+		failures int
+	}
+	type want struct {
+		lock time.Duration
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	policy := ThrottlePolicy{FreeAttempts: 3, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute, ResetAfter: time.Hour}
+
+	tests := []test{
+		{name: "no failures", args: args{failures: 0}, want: want{lock: 0}},
+		{name: "within free attempts", args: args{failures: 3}, want: want{lock: 0}},
+		{name: "first lockout", args: args{failures: 4}, want: want{lock: 30 * time.Second}},
+		{name: "doubles", args: args{failures: 6}, want: want{lock: 2 * time.Minute}},
+		{name: "capped", args: args{failures: 20}, want: want{lock: 5 * time.Minute}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want.lock, policy.LockFor(test.args.failures))
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package tags
 
 import (
+	"errors"
 	"goserv/internal/static/enum"
 )
 
+var ErrTagExists = errors.New("a tag with that name already exists")
+
 type Tag struct {
 	ID   int          `json:"id,omitempty"`
 	Type enum.TagType `json:"type"`
@@ -6,13 +6,18 @@ import (
 	entTag "goserv/ent/gen/tag"
 	"goserv/internal/domain/tags"
 	"goserv/internal/static/enum"
+	"goserv/internal/utils/errors"
 )
 
 type Tag interface {
 	AddTag(ctx context.Context, name string, tagType enum.TagType) (int, error)
+	GetTagByName(ctx context.Context, name string) (*tags.Tag, error)
 	ListTags(ctx context.Context) ([]tags.Tag, error)
 	ListGeneralTags(ctx context.Context) ([]tags.Tag, error)
 	ListPeopleTags(ctx context.Context) ([]tags.Tag, error)
+	RenameTag(ctx context.Context, tagID int, name string) error
+	SetTagType(ctx context.Context, tagID int, tagType enum.TagType) error
+	DeleteTag(ctx context.Context, tagID int) error
 }
 
 type tagRepository struct {
@@ -31,6 +36,17 @@ func (repo *tagRepository) AddTag(ctx context.Context, name string, tagType enum
 	return entTag.ID, nil
 }
 
+func (repo *tagRepository) GetTagByName(ctx context.Context, name string) (*tags.Tag, error) {
+	tag, err := repo.client.Tag.Query().Where(entTag.NameEQ(name)).Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return &tags.Tag{ID: tag.ID, Type: enum.TagType(tag.TagType), Name: tag.Name}, nil
+}
+
 func (repo *tagRepository) ListTags(ctx context.Context) ([]tags.Tag, error) {
 	entTags, err := repo.client.Tag.Query().All(ctx)
 	returnTags := make([]tags.Tag, len(entTags))
@@ -75,3 +91,30 @@ func (repo *tagRepository) ListPeopleTags(ctx context.Context) ([]tags.Tag, erro
 	}
 	return returnTags, err
 }
+
+func (repo *tagRepository) RenameTag(ctx context.Context, tagID int, name string) error {
+	err := repo.client.Tag.UpdateOneID(tagID).SetName(name).Exec(ctx)
+	switch {
+	case gen.IsNotFound(err):
+		return errors.ErrNotFound
+	case gen.IsConstraintError(err):
+		return tags.ErrTagExists
+	}
+	return err
+}
+
+func (repo *tagRepository) SetTagType(ctx context.Context, tagID int, tagType enum.TagType) error {
+	err := repo.client.Tag.UpdateOneID(tagID).SetTagType(entTag.TagType(tagType)).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func (repo *tagRepository) DeleteTag(ctx context.Context, tagID int) error {
+	err := repo.client.Tag.DeleteOneID(tagID).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
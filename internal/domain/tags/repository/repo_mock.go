@@ -8,15 +8,23 @@ import (
 
 type TagMock struct {
 	AddTagFunc          func(ctx context.Context, name string, tagType enum.TagType) (int, error)
+	GetTagByNameFunc    func(ctx context.Context, name string) (*tags.Tag, error)
 	ListTagsFunc        func(ctx context.Context) ([]tags.Tag, error)
 	ListGeneralTagsFunc func(ctx context.Context) ([]tags.Tag, error)
 	ListPeopleTagsFunc  func(ctx context.Context) ([]tags.Tag, error)
+	RenameTagFunc       func(ctx context.Context, tagID int, name string) error
+	SetTagTypeFunc      func(ctx context.Context, tagID int, tagType enum.TagType) error
+	DeleteTagFunc       func(ctx context.Context, tagID int) error
 }
 
 func (m *TagMock) AddTag(ctx context.Context, name string, tagType enum.TagType) (int, error) {
 	return m.AddTagFunc(ctx, name, tagType)
 }
 
+func (m *TagMock) GetTagByName(ctx context.Context, name string) (*tags.Tag, error) {
+	return m.GetTagByNameFunc(ctx, name)
+}
+
 func (m *TagMock) ListTags(ctx context.Context) ([]tags.Tag, error) {
 	return m.ListTagsFunc(ctx)
 }
@@ -28,3 +36,15 @@ func (m *TagMock) ListGeneralTags(ctx context.Context) ([]tags.Tag, error) {
 func (m *TagMock) ListPeopleTags(ctx context.Context) ([]tags.Tag, error) {
 	return m.ListPeopleTagsFunc(ctx)
 }
+
+func (m *TagMock) RenameTag(ctx context.Context, tagID int, name string) error {
+	return m.RenameTagFunc(ctx, tagID, name)
+}
+
+func (m *TagMock) SetTagType(ctx context.Context, tagID int, tagType enum.TagType) error {
+	return m.SetTagTypeFunc(ctx, tagID, tagType)
+}
+
+func (m *TagMock) DeleteTag(ctx context.Context, tagID int) error {
+	return m.DeleteTagFunc(ctx, tagID)
+}
@@ -6,8 +6,13 @@ import (
 	"goserv/internal/domain/tags"
 	"goserv/internal/domain/tags/repository"
 	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"strings"
 )
 
+var ErrInvalidName = errors.New("tag name cannot be empty")
+var ErrInvalidType = errors.New("invalid tag type")
+
 type TagService struct {
 	repo repository.Tag
 }
@@ -20,6 +25,39 @@ func (s *TagService) AddTag(ctx context.Context, name string, tagType enum.TagTy
 	return s.repo.AddTag(ctx, name, tagType)
 }
 
+// ResolveTags fills in the IDs of tags given only by name, creating any that do not exist yet.
+func (s *TagService) ResolveTags(ctx context.Context, newTags []tags.Tag) ([]tags.Tag, error) {
+	resolved := make([]tags.Tag, len(newTags))
+	for i := range newTags {
+		resolved[i] = newTags[i]
+		if resolved[i].ID != 0 {
+			continue
+		}
+
+		existing, err := s.repo.GetTagByName(ctx, resolved[i].Name)
+		if err == nil {
+			resolved[i] = *existing
+			continue
+		}
+		if !errors.Is(err, myErrors.ErrNotFound) {
+			return nil, err
+		}
+
+		switch resolved[i].Type {
+		case enum.TagGeneral, enum.TagPeople:
+		default:
+			return nil, errors.New("invalid tag type detected")
+		}
+
+		id, err := s.repo.AddTag(ctx, resolved[i].Name, resolved[i].Type)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i].ID = id
+	}
+	return resolved, nil
+}
+
 func (s *TagService) ListTags(ctx context.Context) ([]tags.Tag, error) {
 	return s.repo.ListTags(ctx)
 }
@@ -49,3 +87,25 @@ func (s *TagService) SeperateTagTypes(ctx context.Context, allTags []tags.Tag) (
 	}
 	return result, nil
 }
+
+func (s *TagService) RenameTag(ctx context.Context, tagID int, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrInvalidName
+	}
+	return s.repo.RenameTag(ctx, tagID, name)
+}
+
+func (s *TagService) RetypeTag(ctx context.Context, tagID int, tagType enum.TagType) error {
+	switch tagType {
+	case enum.TagGeneral, enum.TagPeople:
+	default:
+		return ErrInvalidType
+	}
+	return s.repo.SetTagType(ctx, tagID, tagType)
+}
+
+// DeleteTag removes the tag from every post it was on.
+func (s *TagService) DeleteTag(ctx context.Context, tagID int) error {
+	return s.repo.DeleteTag(ctx, tagID)
+}
@@ -6,6 +6,7 @@ import (
 	"goserv/internal/domain/tags"
 	"goserv/internal/domain/tags/repository"
 	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -436,3 +437,153 @@ func TestTagService_SeperateTagTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestTagService_ResolveTags(t *testing.T) {
+	type args struct {
+		tags []tags.Tag
+	}
+	type want struct {
+		tags []tags.Tag
+		err  error
+	}
+	type test struct {
+		name     string
+		args     args
+		existing map[string]tags.Tag
+		addErr   error
+		want     want
+	}
+
+	tests := []test{
+		{
+			name: "already resolved",
+			args: args{tags: []tags.Tag{{ID: 3, Name: "beach", Type: enum.TagGeneral}}},
+			want: want{
+				tags: []tags.Tag{{ID: 3, Name: "beach", Type: enum.TagGeneral}},
+				err:  nil,
+			},
+		},
+		{
+			name:     "existing by name",
+			args:     args{tags: []tags.Tag{{Name: "alice", Type: enum.TagPeople}}},
+			existing: map[string]tags.Tag{"alice": {ID: 4, Name: "alice", Type: enum.TagPeople}},
+			want: want{
+				tags: []tags.Tag{{ID: 4, Name: "alice", Type: enum.TagPeople}},
+				err:  nil,
+			},
+		},
+		{
+			name: "new tag",
+			args: args{tags: []tags.Tag{{Name: "sunset", Type: enum.TagGeneral}}},
+			want: want{
+				tags: []tags.Tag{{ID: 10, Name: "sunset", Type: enum.TagGeneral}},
+				err:  nil,
+			},
+		},
+		{
+			name: "invalid type",
+			args: args{tags: []tags.Tag{{Name: "sunset", Type: enum.TagType("bad")}}},
+			want: want{
+				tags: nil,
+				err:  errors.New("invalid tag type detected"),
+			},
+		},
+		{
+			name:   "error adding tag",
+			args:   args{tags: []tags.Tag{{Name: "sunset", Type: enum.TagGeneral}}},
+			addErr: errors.New("test error"),
+			want: want{
+				tags: nil,
+				err:  errors.New("test error"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tagRepo := &repository.TagMock{
+				GetTagByNameFunc: func(ctx context.Context, name string) (*tags.Tag, error) {
+					if tag, ok := test.existing[name]; ok {
+						return &tag, nil
+					}
+					return nil, myErrors.ErrNotFound
+				},
+				AddTagFunc: func(ctx context.Context, name string, tagType enum.TagType) (int, error) {
+					return 10, test.addErr
+				},
+			}
+
+			service := NewTagService(tagRepo)
+
+			resolved, err := service.ResolveTags(context.Background(), test.args.tags)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.tags, resolved)
+		})
+	}
+}
+
+func TestTagService_RenameTag(t *testing.T) {
+	type args struct {
+		name string
+	}
+	type want struct {
+		saved string
+		err   error
+	}
+	type test struct {
+		name    string
+		args    args
+		repoErr error
+		want    want
+	}
+
+	tests := []test{
+		{
+			name: "rename tag",
+			args: args{name: "  sunset "},
+			want: want{saved: "sunset", err: nil},
+		},
+		{
+			name: "empty name",
+			args: args{name: "   "},
+			want: want{saved: "", err: ErrInvalidName},
+		},
+		{
+			name:    "name taken",
+			args:    args{name: "beach"},
+			repoErr: tags.ErrTagExists,
+			want:    want{saved: "beach", err: tags.ErrTagExists},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saved := ""
+			tagRepo := &repository.TagMock{
+				RenameTagFunc: func(ctx context.Context, tagID int, name string) error {
+					saved = name
+					return test.repoErr
+				},
+			}
+
+			service := NewTagService(tagRepo)
+
+			err := service.RenameTag(context.Background(), 1, test.args.name)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.saved, saved)
+		})
+	}
+}
+
+func TestTagService_RetypeTag(t *testing.T) {
+	tagRepo := &repository.TagMock{
+		SetTagTypeFunc: func(ctx context.Context, tagID int, tagType enum.TagType) error {
+			assert.Equal(t, enum.TagPeople, tagType)
+			return nil
+		},
+	}
+	service := NewTagService(tagRepo)
+
+	assert.NoError(t, service.RetypeTag(context.Background(), 1, enum.TagPeople))
+	assert.Equal(t, ErrInvalidType, service.RetypeTag(context.Background(), 1, "Artist"))
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"goserv/internal/domain/tokens"
+	"goserv/internal/domain/tokens/service"
+	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+type TokenHandler struct {
+	svc  *service.TokenService
+	tmpl *template.Template
+}
+
+func NewTokenHandler(svc *service.TokenService, tmpl *template.Template) *TokenHandler {
+	return &TokenHandler{svc: svc, tmpl: tmpl}
+}
+
+func (h *TokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	h.renderTokens(w, r, "")
+}
+
+func (h *TokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	scopes := make([]enum.Scope, len(r.Form["scopes"]))
+	for i, scope := range r.Form["scopes"] {
+		scopes[i] = enum.Scope(scope)
+	}
+
+	plain, err := h.svc.CreateToken(r.Context(), userID, r.FormValue("name"), scopes)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidName) || errors.Is(err, service.ErrInvalidScope) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error creating token", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderTokens(w, r, plain)
+}
+
+func (h *TokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	tokenID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Error getting token id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.svc.RevokeToken(r.Context(), tokenID, userID)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Error revoking token", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/profile/tokens", http.StatusSeeOther)
+}
+
+func (h *TokenHandler) renderTokens(w http.ResponseWriter, r *http.Request, newToken string) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	tokenList, err := h.svc.ListUserTokens(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error listing tokens", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "tokens.html", struct {
+		Tokens   []tokens.Token
+		Scopes   []string
+		NewToken string
+		CSRF     string
+	}{
+		Tokens:   tokenList,
+		Scopes:   enum.Scope("").Values(),
+		NewToken: newToken,
+		CSRF:     middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,25 @@
+package tokens
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"goserv/internal/static/enum"
+	"time"
+)
+
+const Prefix = "gsv_"
+
+type Token struct {
+	ID         int
+	Name       string
+	Scopes     []enum.Scope
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	UserID     int
+}
+
+// Hash is what gets stored for a token; the plain value is only shown once when it is minted.
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"goserv/ent/gen"
+	entToken "goserv/ent/gen/apitoken"
+	entUser "goserv/ent/gen/user"
+	"goserv/internal/domain/tokens"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/errors"
+	"time"
+)
+
+type Token interface {
+	AddToken(ctx context.Context, token *tokens.Token, tokenHash string) (int, error)
+	ListUserTokens(ctx context.Context, userID int) ([]tokens.Token, error)
+	GetByHash(ctx context.Context, tokenHash string) (*tokens.Token, error)
+	DeleteToken(ctx context.Context, tokenID int, userID int) error
+	TouchToken(ctx context.Context, tokenID int, usedAt time.Time) error
+}
+
+type tokenRepository struct {
+	client *gen.Client
+}
+
+func NewTokenRepository(client *gen.Client) *tokenRepository {
+	return &tokenRepository{client: client}
+}
+
+func (repo *tokenRepository) AddToken(ctx context.Context, token *tokens.Token, tokenHash string) (int, error) {
+	scopes := make([]string, len(token.Scopes))
+	for i := range token.Scopes {
+		scopes[i] = string(token.Scopes[i])
+	}
+
+	saved, err := repo.client.APIToken.
+		Create().
+		SetName(token.Name).
+		SetTokenHash(tokenHash).
+		SetScopes(scopes).
+		SetUserID(token.UserID).
+		Save(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return saved.ID, nil
+}
+
+func (repo *tokenRepository) ListUserTokens(ctx context.Context, userID int) ([]tokens.Token, error) {
+	entTokens, err := repo.client.APIToken.
+		Query().
+		Where(entToken.UserIDEQ(userID)).
+		Order(entToken.ByCreatedAt()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	returnTokens := make([]tokens.Token, len(entTokens))
+	for i := range entTokens {
+		returnTokens[i] = *toDomainToken(entTokens[i])
+	}
+	return returnTokens, nil
+}
+
+func (repo *tokenRepository) GetByHash(ctx context.Context, tokenHash string) (*tokens.Token, error) {
+	// tokens of disabled accounts are treated as unknown
+	token, err := repo.client.APIToken.
+		Query().
+		Where(entToken.TokenHashEQ(tokenHash), entToken.HasOwnerWith(entUser.DisabledEQ(false))).
+		Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return toDomainToken(token), nil
+}
+
+func (repo *tokenRepository) DeleteToken(ctx context.Context, tokenID int, userID int) error {
+	deleted, err := repo.client.APIToken.
+		Delete().
+		Where(entToken.IDEQ(tokenID), entToken.UserIDEQ(userID)).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+func (repo *tokenRepository) TouchToken(ctx context.Context, tokenID int, usedAt time.Time) error {
+	return repo.client.APIToken.UpdateOneID(tokenID).SetLastUsedAt(usedAt).Exec(ctx)
+}
+
+func toDomainToken(token *gen.APIToken) *tokens.Token {
+	scopes := make([]enum.Scope, len(token.Scopes))
+	for i := range token.Scopes {
+		scopes[i] = enum.Scope(token.Scopes[i])
+	}
+
+	return &tokens.Token{
+		ID:         token.ID,
+		Name:       token.Name,
+		Scopes:     scopes,
+		CreatedAt:  token.CreatedAt,
+		LastUsedAt: token.LastUsedAt,
+		UserID:     token.UserID,
+	}
+}
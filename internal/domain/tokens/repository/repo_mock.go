@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"goserv/internal/domain/tokens"
+	"time"
+)
+
+type TokenMock struct {
+	AddTokenFunc       func(ctx context.Context, token *tokens.Token, tokenHash string) (int, error)
+	ListUserTokensFunc func(ctx context.Context, userID int) ([]tokens.Token, error)
+	GetByHashFunc      func(ctx context.Context, tokenHash string) (*tokens.Token, error)
+	DeleteTokenFunc    func(ctx context.Context, tokenID int, userID int) error
+	TouchTokenFunc     func(ctx context.Context, tokenID int, usedAt time.Time) error
+}
+
+func (m *TokenMock) AddToken(ctx context.Context, token *tokens.Token, tokenHash string) (int, error) {
+	return m.AddTokenFunc(ctx, token, tokenHash)
+}
+
+func (m *TokenMock) ListUserTokens(ctx context.Context, userID int) ([]tokens.Token, error) {
+	return m.ListUserTokensFunc(ctx, userID)
+}
+
+func (m *TokenMock) GetByHash(ctx context.Context, tokenHash string) (*tokens.Token, error) {
+	return m.GetByHashFunc(ctx, tokenHash)
+}
+
+func (m *TokenMock) DeleteToken(ctx context.Context, tokenID int, userID int) error {
+	return m.DeleteTokenFunc(ctx, tokenID, userID)
+}
+
+func (m *TokenMock) TouchToken(ctx context.Context, tokenID int, usedAt time.Time) error {
+	return m.TouchTokenFunc(ctx, tokenID, usedAt)
+}
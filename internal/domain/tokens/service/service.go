@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"goserv/internal/domain/tokens"
+	"goserv/internal/domain/tokens/repository"
+	"goserv/internal/static/enum"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidName = errors.New("token name is required")
+var ErrInvalidScope = errors.New("invalid token scope")
+
+type TokenService struct {
+	repo repository.Token
+}
+
+func NewTokenService(repo repository.Token) *TokenService {
+	return &TokenService{repo: repo}
+}
+
+// CreateToken mints a new token and returns its plain value, which is never stored.
+func (s *TokenService) CreateToken(ctx context.Context, userID int, name string, scopes []enum.Scope) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrInvalidName
+	}
+
+	if len(scopes) == 0 {
+		return "", ErrInvalidScope
+	}
+	validScopes := enum.Scope("").Values()
+	for i := range scopes {
+		if !slices.Contains(validScopes, string(scopes[i])) {
+			return "", ErrInvalidScope
+		}
+	}
+
+	plain := generateToken()
+	token := &tokens.Token{Name: name, Scopes: scopes, UserID: userID}
+	if _, err := s.repo.AddToken(ctx, token, tokens.Hash(plain)); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+func (s *TokenService) ListUserTokens(ctx context.Context, userID int) ([]tokens.Token, error) {
+	return s.repo.ListUserTokens(ctx, userID)
+}
+
+func (s *TokenService) RevokeToken(ctx context.Context, tokenID int, userID int) error {
+	return s.repo.DeleteToken(ctx, tokenID, userID)
+}
+
+func generateToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return tokens.Prefix + hex.EncodeToString(b)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"goserv/internal/domain/tokens"
+	"goserv/internal/domain/tokens/repository"
+	"goserv/internal/static/enum"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenService_CreateToken(t *testing.T) {
+	type args struct {
+		userID int
+		name   string
+		scopes []enum.Scope
+	}
+	type want struct {
+		err error
+	}
+	type test struct {
+		name   string
+		args   args
+		addErr error
+		want   want
+	}
+
+	basicArgs := args{
+		userID: 1,
+		name:   "uploader",
+		scopes: []enum.Scope{enum.ScopePostsRead, enum.ScopePostsWrite},
+	}
+
+	tests := []test{
+		{
+			name: "simple create token",
+			args: basicArgs,
+			want: want{err: nil},
+		},
+		{
+			name: "missing name",
+			args: args{userID: 1, name: "  ", scopes: basicArgs.scopes},
+			want: want{err: ErrInvalidName},
+		},
+		{
+			name: "missing scopes",
+			args: args{userID: 1, name: "uploader", scopes: nil},
+			want: want{err: ErrInvalidScope},
+		},
+		{
+			name: "unknown scope",
+			args: args{userID: 1, name: "uploader", scopes: []enum.Scope{"admin:all"}},
+			want: want{err: ErrInvalidScope},
+		},
+		{
+			name:   "error saving token",
+			args:   basicArgs,
+			addErr: errors.New("test error"),
+			want:   want{err: errors.New("test error")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var savedHash string
+			tokenRepo := &repository.TokenMock{
+				AddTokenFunc: func(ctx context.Context, token *tokens.Token, tokenHash string) (int, error) {
+					assert.Equal(t, test.args.userID, token.UserID)
+					assert.Equal(t, test.args.scopes, token.Scopes)
+					savedHash = tokenHash
+					return 1, test.addErr
+				},
+			}
+
+			service := NewTokenService(tokenRepo)
+
+			plain, err := service.CreateToken(context.Background(), test.args.userID, test.args.name, test.args.scopes)
+			assert.Equal(t, test.want.err, err)
+			if test.want.err == nil {
+				assert.True(t, strings.HasPrefix(plain, tokens.Prefix))
+				assert.Equal(t, tokens.Hash(plain), savedHash)
+				assert.NotEqual(t, plain, savedHash)
+			} else {
+				assert.Empty(t, plain)
+			}
+		})
+	}
+}
+
+func TestTokenService_ListUserTokens(t *testing.T) {
+	type want struct {
+		tokens []tokens.Token
+		err    error
+	}
+	type test struct {
+		name string
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "simple list tokens",
+			want: want{
+				tokens: []tokens.Token{{ID: 1, Name: "uploader", Scopes: []enum.Scope{enum.ScopePostsWrite}, UserID: 1}},
+				err:    nil,
+			},
+		},
+		{
+			name: "error list tokens",
+			want: want{tokens: nil, err: errors.New("test error")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokenRepo := &repository.TokenMock{
+				ListUserTokensFunc: func(ctx context.Context, userID int) ([]tokens.Token, error) {
+					return test.want.tokens, test.want.err
+				},
+			}
+
+			service := NewTokenService(tokenRepo)
+
+			list, err := service.ListUserTokens(context.Background(), 1)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.tokens, list)
+		})
+	}
+}
+
+func TestTokenService_RevokeToken(t *testing.T) {
+	type want struct {
+		err error
+	}
+	type test struct {
+		name string
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "simple revoke",
+			want: want{err: nil},
+		},
+		{
+			name: "error revoking",
+			want: want{err: errors.New("test error")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokenRepo := &repository.TokenMock{
+				DeleteTokenFunc: func(ctx context.Context, tokenID int, userID int) error {
+					assert.Equal(t, 3, tokenID)
+					assert.Equal(t, 1, userID)
+					return test.want.err
+				},
+			}
+
+			service := NewTokenService(tokenRepo)
+
+			err := service.RevokeToken(context.Background(), 3, 1)
+			assert.Equal(t, test.want.err, err)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"goserv/internal/domain/sessions"
+	"goserv/internal/domain/users/service"
+	"goserv/internal/middleware"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// ExportData sends the user's uploads, favourites and tags as a zip archive.
+func (h *UserHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+
+	export, err := h.postSvc.UserExport(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error collecting export", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "goserv-" + user.Username + "-" + export.CreatedAt.Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	// the archive is streamed, so a failure part way through can only cut it short
+	if err := h.postSvc.WriteExport(r.Context(), w, export); err != nil {
+		log.Printf("Failed to write export for user %d: %v\n", userID, err)
+		return
+	}
+	log.Printf("User %d exported %d uploads\n", userID, len(export.Uploads))
+}
+
+func (h *UserHandler) DisplayDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	h.renderDeleteAccount(w, r, "")
+}
+
+// DeleteAccount removes the user after checking their password. Their uploads are either deleted
+// too or kept without an owner, as chosen on the form.
+func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	deletePosts := false
+	switch r.FormValue("posts") {
+	case "delete":
+		deletePosts = true
+	case "keep":
+	default:
+		h.renderDeleteAccount(w, r, "Choose what happens to your uploads")
+		return
+	}
+
+	// read before the account goes, as afterwards the uploads no longer point back at it
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+	uploads, err := h.postSvc.AllUserPosts(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error listing uploads", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.svc.DeleteAccount(r.Context(), userID, r.FormValue("password"))
+	if err != nil {
+		if errors.Is(err, service.ErrWrongPassword) || errors.Is(err, service.ErrLastAdmin) {
+			h.renderDeleteAccount(w, r, err.Error())
+			return
+		}
+		http.Error(w, "Error deleting account", http.StatusInternalServerError)
+		return
+	}
+	h.profileSvc.DiscardAvatar(r.Context(), user.Avatar)
+
+	if deletePosts {
+		// whatever fails to delete stays behind without an owner, as if it had been kept
+		if err := h.postSvc.DeletePosts(r.Context(), uploads); err != nil {
+			log.Printf("Failed to delete uploads of deleted user %d: %v\n", userID, err)
+		}
+	}
+	log.Printf("User %d deleted their account, %d uploads deleted: %t\n", userID, len(uploads), deletePosts)
+
+	http.SetCookie(w, &http.Cookie{Name: sessions.CookieName, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *UserHandler) renderDeleteAccount(w http.ResponseWriter, r *http.Request, message string) {
+	err := h.tmpl.ExecuteTemplate(w, "delete_account.html", struct {
+		Error string
+		CSRF  string
+	}{
+		Error: message,
+		CSRF:  middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
@@ -1,52 +1,483 @@
 package handler
 
 import (
+	"errors"
+	postService "goserv/internal/domain/posts/service"
+	"goserv/internal/domain/sessions"
 	"goserv/internal/domain/users"
 	"goserv/internal/domain/users/service"
 	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/totp"
 	"html/template"
+	"log"
 	"net/http"
 )
 
 type UserHandler struct {
-	svc  *service.UserService
-	tmpl *template.Template
+	svc        *service.UserService
+	resetSvc   *service.PasswordResetService
+	regSvc     *service.RegistrationService
+	profileSvc *service.ProfileService
+	postSvc    *postService.PostService
+	tmpl       *template.Template
 }
 
-func NewUserHandler(svc *service.UserService, tmpl *template.Template) *UserHandler {
-	return &UserHandler{svc: svc, tmpl: tmpl}
+func NewUserHandler(
+	svc *service.UserService,
+	resetSvc *service.PasswordResetService,
+	regSvc *service.RegistrationService,
+	profileSvc *service.ProfileService,
+	postSvc *postService.PostService,
+	tmpl *template.Template,
+) *UserHandler {
+	return &UserHandler{svc: svc, resetSvc: resetSvc, regSvc: regSvc, profileSvc: profileSvc, postSvc: postSvc, tmpl: tmpl}
+}
+
+// registerForm is what the registration page shows again after a failed attempt.
+type registerForm struct {
+	Invite   string
+	Username string
+	Error    string
+	// Fields holds a message for each field that was filled in wrong
+	Fields map[string]string
 }
 
 func (h *UserHandler) DisplayRegister(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserID(r)
 	if ok && userID != 0 {
 		http.Error(w, "Already logged in", http.StatusUnauthorized)
+		return
+	}
+
+	code := r.URL.Query().Get("invite")
+	if code == "" {
+		h.renderRegister(w, r, registerForm{})
+		return
 	}
 
-	err := h.tmpl.ExecuteTemplate(w, "register.html", struct{ User *users.User }{
-		User: &users.User{},
+	if _, err := h.regSvc.CheckInvite(r.Context(), code); err != nil {
+		if errors.Is(err, users.ErrInvalidInvite) {
+			h.renderRegister(w, r, registerForm{Error: err.Error()})
+			return
+		}
+		http.Error(w, "Error checking invite", http.StatusInternalServerError)
+		return
+	}
+	h.renderRegister(w, r, registerForm{Invite: code})
+}
+
+func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+	form := registerForm{
+		Invite:   r.FormValue("invite"),
+		Username: r.FormValue("username"),
+	}
+	password := r.FormValue("password")
+	if password != r.FormValue("password2") {
+		form.Fields = map[string]string{"password2": "Passwords do not match"}
+		h.renderRegister(w, r, form)
+		return
+	}
+
+	err := h.regSvc.Register(r.Context(), form.Username, password, form.Invite)
+	if err != nil {
+		var invalid *users.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			form.Fields = invalid.Fields
+			h.renderRegister(w, r, form)
+		case errors.Is(err, service.ErrRegistrationClosed):
+			h.renderRegister(w, r, registerForm{})
+		case errors.Is(err, service.ErrInviteRequired), errors.Is(err, users.ErrInvalidInvite):
+			h.renderRegister(w, r, registerForm{Error: err.Error()})
+		default:
+			log.Printf("Failed to register %q: %v\n", form.Username, err)
+			http.Error(w, "Failed to register user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// renderRegister shows the registration form, or asks for an invite code first when one is needed.
+func (h *UserHandler) renderRegister(w http.ResponseWriter, r *http.Request, form registerForm) {
+	mode := h.regSvc.Mode()
+	err := h.tmpl.ExecuteTemplate(w, "register.html", struct {
+		registerForm
+		Closed     bool
+		NeedInvite bool
+		Rules      registerRules
+		CSRF       string
+	}{
+		registerForm: form,
+		Closed:       mode == users.RegistrationClosed,
+		NeedInvite:   mode == users.RegistrationInvite && form.Invite == "",
+		Rules:        registerRules{MinUsername: validate.MinUsernameLen, MaxUsername: validate.MaxUsernameLen, MinPassword: validate.MinPasswordLen},
+		CSRF:         middleware.CSRFToken(r),
 	})
 	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
 	}
 }
 
-func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-	username := r.FormValue("username")
+type registerRules struct {
+	MinUsername int
+	MaxUsername int
+	MinPassword int
+}
+
+func (h *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "profile.html", struct {
+		Username    string
+		Role        string
+		TOTPEnabled bool
+		CanUpload   bool
+		CanInvite   bool
+		CanManage   bool
+	}{
+		Username:    user.Username,
+		Role:        user.Role,
+		TOTPEnabled: user.TOTPEnabled,
+		CanUpload:   user.Can(enum.PermPostCreate),
+		CanInvite:   user.Can(enum.PermInviteCreate),
+		CanManage:   user.Can(enum.PermUserManage) || user.Can(enum.PermTagEdit) || user.Can(enum.PermPostDeleteAny),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *UserHandler) DisplayChangePassword(w http.ResponseWriter, r *http.Request) {
+	h.renderChangePassword(w, r, "")
+}
+
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
 	password := r.FormValue("password")
+	if password != r.FormValue("confirm") {
+		h.renderChangePassword(w, r, "New passwords do not match")
+		return
+	}
 
-	err := h.svc.Register(r.Context(), username, password)
+	err := h.svc.ChangePassword(r.Context(), userID, r.FormValue("current"), password)
 	if err != nil {
-		http.Error(w, "Failed to register user", http.StatusInternalServerError)
+		var invalid *users.ValidationError
+		if errors.Is(err, service.ErrWrongPassword) || errors.As(err, &invalid) {
+			h.renderChangePassword(w, r, err.Error())
+			return
+		}
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
 		return
 	}
 
+	// every session was revoked, so drop the now dead cookie and log in again
+	http.SetCookie(w, &http.Cookie{Name: sessions.CookieName, Path: "/", MaxAge: -1})
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
-func (h *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
-	if err := h.tmpl.ExecuteTemplate(w, "profile.html", nil); err != nil {
+func (h *UserHandler) renderChangePassword(w http.ResponseWriter, r *http.Request, message string) {
+	err := h.tmpl.ExecuteTemplate(w, "password.html", struct {
+		Error string
+		CSRF  string
+	}{
+		Error: message,
+		CSRF:  middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) DisplayEmail(w http.ResponseWriter, r *http.Request) {
+	h.renderEmail(w, r, "", false)
+}
+
+func (h *UserHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	err := h.svc.SetEmail(r.Context(), userID, r.FormValue("email"), r.FormValue("password"))
+	if err != nil {
+		if errors.Is(err, service.ErrWrongPassword) || errors.Is(err, service.ErrInvalidEmail) || errors.Is(err, users.ErrEmailTaken) {
+			h.renderEmail(w, r, err.Error(), false)
+			return
+		}
+		http.Error(w, "Failed to change email", http.StatusInternalServerError)
+		return
+	}
+	h.renderEmail(w, r, "", true)
+}
+
+func (h *UserHandler) renderEmail(w http.ResponseWriter, r *http.Request, message string, saved bool) {
+	userID, _ := middleware.GetUserID(r)
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "email.html", struct {
+		Email string
+		Saved bool
+		Error string
+		CSRF  string
+	}{
+		Email: user.Email,
+		Saved: saved,
+		Error: message,
+		CSRF:  middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) DisplayForgotPassword(w http.ResponseWriter, r *http.Request) {
+	h.renderForgotPassword(w, r, "", false)
+}
+
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	err := h.resetSvc.RequestReset(r.Context(), r.FormValue("email"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidEmail) {
+			h.renderForgotPassword(w, r, err.Error(), false)
+			return
+		}
+		log.Printf("Failed to send password reset: %v\n", err)
+		http.Error(w, "Failed to send reset email", http.StatusInternalServerError)
+		return
+	}
+	h.renderForgotPassword(w, r, "", true)
+}
+
+func (h *UserHandler) renderForgotPassword(w http.ResponseWriter, r *http.Request, message string, sent bool) {
+	err := h.tmpl.ExecuteTemplate(w, "forgot_password.html", struct {
+		Sent  bool
+		Error string
+		CSRF  string
+	}{
+		Sent:  sent,
+		Error: message,
+		CSRF:  middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) DisplayResetPassword(w http.ResponseWriter, r *http.Request) {
+	h.renderResetPassword(w, r, r.URL.Query().Get("token"), "")
+}
+
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	password := r.FormValue("password")
+	if password != r.FormValue("confirm") {
+		h.renderResetPassword(w, r, token, "New passwords do not match")
+		return
+	}
+
+	err := h.resetSvc.ResetPassword(r.Context(), token, password)
+	if err != nil {
+		var invalid *users.ValidationError
+		if errors.Is(err, service.ErrInvalidResetToken) || errors.As(err, &invalid) {
+			h.renderResetPassword(w, r, token, err.Error())
+			return
+		}
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	// every session was revoked, including any this browser had
+	http.SetCookie(w, &http.Cookie{Name: sessions.CookieName, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (h *UserHandler) renderResetPassword(w http.ResponseWriter, r *http.Request, token string, message string) {
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	err := h.tmpl.ExecuteTemplate(w, "reset_password.html", struct {
+		Token string
+		Error string
+		CSRF  string
+	}{
+		Token: token,
+		Error: message,
+		CSRF:  middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) DisplayTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.renderTwoFactor(w, r, "")
+}
+
+func (h *UserHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.renderSetup(w, r, "")
+}
+
+func (h *UserHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.svc.EnableTOTP(r.Context(), userID, r.FormValue("code"))
+	if err != nil {
+		if errors.Is(err, service.ErrWrongCode) {
+			h.renderSetup(w, r, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrTOTPEnabled) {
+			http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Error enabling two-factor login", http.StatusInternalServerError)
+		return
+	}
+	h.renderRecoveryCodes(w, codes)
+}
+
+func (h *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	err := h.svc.DisableTOTP(r.Context(), userID, r.FormValue("password"))
+	if err != nil {
+		if errors.Is(err, service.ErrWrongPassword) || errors.Is(err, service.ErrTOTPRequired) {
+			h.renderTwoFactor(w, r, err.Error())
+			return
+		}
+		http.Error(w, "Error disabling two-factor login", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+}
+
+func (h *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.svc.RegenerateRecoveryCodes(r.Context(), userID, r.FormValue("password"))
+	if err != nil {
+		if errors.Is(err, service.ErrWrongPassword) || errors.Is(err, service.ErrTOTPNotEnabled) {
+			h.renderTwoFactor(w, r, err.Error())
+			return
+		}
+		http.Error(w, "Error creating recovery codes", http.StatusInternalServerError)
+		return
+	}
+	h.renderRecoveryCodes(w, codes)
+}
+
+func (h *UserHandler) renderTwoFactor(w http.ResponseWriter, r *http.Request, message string) {
+	userID, _ := middleware.GetUserID(r)
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+
+	remaining := 0
+	if user.TOTPEnabled {
+		remaining, err = h.svc.CountRecoveryCodes(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Error reading recovery codes", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "totp.html", struct {
+		Enabled       bool
+		Required      bool
+		RecoveryCodes int
+		Error         string
+		CSRF          string
+	}{
+		Enabled:       user.TOTPEnabled,
+		Required:      user.TOTPRequired,
+		RecoveryCodes: remaining,
+		Error:         message,
+		CSRF:          middleware.CSRFToken(r),
+	})
+	if err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) renderSetup(w http.ResponseWriter, r *http.Request, message string) {
+	userID, _ := middleware.GetUserID(r)
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
 		return
 	}
+
+	secret, err := h.svc.BeginTOTP(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrTOTPEnabled) {
+			http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Error setting up two-factor login", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "totp_setup.html", struct {
+		Action   string
+		Secret   string
+		URI      string
+		Required bool
+		Error    string
+		CSRF     string
+	}{
+		Action:   "/profile/2fa/enable",
+		Secret:   secret,
+		URI:      totp.ProvisioningURI(r.Host, user.Username, secret),
+		Required: false,
+		Error:    message,
+		CSRF:     middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) renderRecoveryCodes(w http.ResponseWriter, codes []string) {
+	err := h.tmpl.ExecuteTemplate(w, "totp_recovery.html", struct {
+		Codes    []string
+		Continue string
+	}{
+		Codes:    codes,
+		Continue: "/profile/2fa",
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	"goserv/internal/domain/users"
+	"goserv/internal/domain/users/service"
+	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (h *UserHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	h.renderInvites(w, r, "", "")
+}
+
+func (h *UserHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	uses, err := strconv.Atoi(r.FormValue("uses"))
+	if err != nil {
+		h.renderInvites(w, r, "", service.ErrInvalidUses.Error())
+		return
+	}
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil {
+		h.renderInvites(w, r, "", service.ErrInvalidExpiry.Error())
+		return
+	}
+
+	code, err := h.regSvc.CreateInvite(r.Context(), userID, r.FormValue("role"), uses, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUses) || errors.Is(err, service.ErrInvalidExpiry) || errors.Is(err, service.ErrInvalidRole) {
+			h.renderInvites(w, r, "", err.Error())
+			return
+		}
+		http.Error(w, "Error creating invite", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("User %d created an invite for %d users\n", userID, uses)
+	h.renderInvites(w, r, code, "")
+}
+
+func (h *UserHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	inviteID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Error getting invite id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.regSvc.RevokeInvite(r.Context(), userID, inviteID); err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Error revoking invite", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/profile/invites", http.StatusSeeOther)
+}
+
+func (h *UserHandler) renderInvites(w http.ResponseWriter, r *http.Request, newCode string, message string) {
+	userID, _ := middleware.GetUserID(r)
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+
+	invites, err := h.regSvc.ListUserInvites(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error listing invites", http.StatusInternalServerError)
+		return
+	}
+
+	// only admins pick the role an invite gives
+	var roles []users.Role
+	if user.Can(enum.PermUserManage) {
+		roles, err = h.svc.ListRoles(r.Context())
+		if err != nil {
+			http.Error(w, "Error listing roles", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "invites.html", struct {
+		Invites   []users.Invite
+		Roles     []users.Role
+		NewInvite string
+		Now       time.Time
+		Error     string
+		CSRF      string
+	}{
+		Invites:   invites,
+		Roles:     roles,
+		NewInvite: newCode,
+		Now:       time.Now(),
+		Error:     message,
+		CSRF:      middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"errors"
+	"goserv/internal/domain/posts"
+	"goserv/internal/domain/users"
+	"goserv/internal/domain/users/service"
+	"goserv/internal/middleware"
+	"goserv/internal/static/constant"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/pagination"
+	"goserv/internal/utils/validate"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxAvatarSize = 5 << 20
+
+const (
+	tabUploads    = "uploads"
+	tabFavourites = "favourites"
+)
+
+type profilePost struct {
+	ID        int
+	Thumbnail string
+	Ready     bool
+}
+
+// PublicProfile shows a user's profile with a page of their uploads, or of their favourites when
+// they have made those public.
+func (h *UserHandler) PublicProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := h.profileSvc.GetProfile(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+	if user.Username != chi.URLParam(r, "username") {
+		target := url.URL{Path: "/users/" + user.Username, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+		return
+	}
+
+	viewerID, _ := middleware.GetUserID(r)
+	isOwner := viewerID != 0 && viewerID == user.ID
+	showFavourites := user.FavouritesPublic || isOwner
+
+	tab := r.URL.Query().Get("tab")
+	switch tab {
+	case "":
+		tab = tabUploads
+	case tabUploads:
+	case tabFavourites:
+		if !showFavourites {
+			http.NotFound(w, r)
+			return
+		}
+	default:
+		http.Error(w, "Unknown tab", http.StatusBadRequest)
+		return
+	}
+
+	pageReq, err := pagination.ParsePageRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid page request", http.StatusBadRequest)
+		return
+	}
+
+	uploads, err := h.postSvc.CountUserPosts(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to count posts", http.StatusInternalServerError)
+		return
+	}
+
+	var page pagination.Page[posts.Post]
+	if tab == tabFavourites {
+		page, err = h.postSvc.ListUserFavs(r.Context(), user.ID, pageReq)
+	} else {
+		page, err = h.postSvc.ListUserPosts(r.Context(), user.ID, pageReq)
+	}
+	if err != nil {
+		http.Error(w, "Failed to list posts", http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]profilePost, len(page.Items))
+	for i := range page.Items {
+		entries[i] = profilePost{
+			ID:        page.Items[i].ID,
+			Thumbnail: page.Items[i].Filename + constant.ThumbnailExt,
+			Ready:     page.Items[i].Ready(),
+		}
+	}
+
+	avatar := ""
+	if user.Avatar != "" {
+		avatar = user.Avatar + constant.ThumbnailExt
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "user.html", struct {
+		Name           string
+		Username       string
+		Bio            string
+		Avatar         string
+		JoinedAt       time.Time
+		Uploads        int
+		Tab            string
+		ShowFavourites bool
+		IsOwner        bool
+		IsUser         bool
+		Posts          []profilePost
+		Nav            pagination.Nav
+	}{
+		Name:           user.Name(),
+		Username:       user.Username,
+		Bio:            user.Bio,
+		Avatar:         avatar,
+		JoinedAt:       user.CreatedAt,
+		Uploads:        uploads,
+		Tab:            tab,
+		ShowFavourites: showFavourites,
+		IsOwner:        isOwner,
+		IsUser:         viewerID != 0,
+		Posts:          entries,
+		Nav:            pagination.NewNav(r.URL, page.Prev, page.Next),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) DisplayEditProfile(w http.ResponseWriter, r *http.Request) {
+	h.renderEditProfile(w, r, nil, "")
+}
+
+func (h *UserHandler) EditProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	err := h.profileSvc.UpdateProfile(r.Context(), userID, r.FormValue("display_name"), r.FormValue("bio"), r.FormValue("favourites_public") != "")
+	if err != nil {
+		var invalid *users.ValidationError
+		if errors.As(err, &invalid) {
+			h.renderEditProfile(w, r, invalid.Fields, "")
+			return
+		}
+		http.Error(w, "Failed to save profile", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+}
+
+func (h *UserHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		h.renderEditProfile(w, r, nil, "Choose an image to upload")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxAvatarSize {
+		h.renderEditProfile(w, r, nil, "Avatar must be at most 5 MB")
+		return
+	}
+
+	if err := h.profileSvc.SetAvatar(r.Context(), userID, file); err != nil {
+		if errors.Is(err, service.ErrInvalidAvatar) {
+			h.renderEditProfile(w, r, nil, err.Error())
+			return
+		}
+		http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+}
+
+func (h *UserHandler) RemoveAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Error reading user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.profileSvc.RemoveAvatar(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to remove avatar", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+}
+
+// renderEditProfile shows the form with the saved profile. Fields holds a message for each field that
+// was filled in wrong, AvatarError what went wrong with an avatar upload.
+func (h *UserHandler) renderEditProfile(w http.ResponseWriter, r *http.Request, fields map[string]string, avatarError string) {
+	userID, _ := middleware.GetUserID(r)
+	user, err := h.svc.GetByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Error reading user", http.StatusInternalServerError)
+		return
+	}
+
+	displayName, bio := user.DisplayName, user.Bio
+	if fields != nil {
+		// keep what was typed so it can be fixed rather than typed again
+		displayName, bio = r.FormValue("display_name"), r.FormValue("bio")
+	}
+
+	avatar := ""
+	if user.Avatar != "" {
+		avatar = user.Avatar + constant.ThumbnailExt
+	}
+
+	err = h.tmpl.ExecuteTemplate(w, "profile_edit.html", struct {
+		Username         string
+		DisplayName      string
+		Bio              string
+		Avatar           string
+		FavouritesPublic bool
+		Fields           map[string]string
+		AvatarError      string
+		MaxDisplayName   int
+		MaxBio           int
+		CSRF             string
+	}{
+		Username:         user.Username,
+		DisplayName:      displayName,
+		Bio:              bio,
+		Avatar:           avatar,
+		FavouritesPublic: user.FavouritesPublic,
+		Fields:           fields,
+		AvatarError:      avatarError,
+		MaxDisplayName:   validate.MaxDisplayNameLen,
+		MaxBio:           validate.MaxBioLen,
+		CSRF:             middleware.CSRFToken(r),
+	})
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
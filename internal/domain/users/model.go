@@ -1,7 +1,190 @@
 package users
 
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"goserv/internal/static/constant"
+	"goserv/internal/static/enum"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrEmailTaken = errors.New("email address is already in use")
+var ErrUsernameTaken = errors.New("username is already taken")
+var ErrIdentityTaken = errors.New("external account is already linked to another user")
+var ErrInvalidInvite = errors.New("invite code is invalid, used up or expired")
+
+// ValidationError lists what is wrong with a submitted form, keyed by field name.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	messages := make([]string, len(fields))
+	for i := range fields {
+		messages[i] = e.Fields[fields[i]]
+	}
+	return strings.Join(messages, "; ")
+}
+
+// NoPassword is stored as the password hash of users created through an external login. It never
+// matches a password, so they log in through their provider until they set one with a reset link.
+const NoPassword = "!"
+
+// Role names seeded by the migrations.
+const (
+	RoleViewer    = "viewer"
+	RoleUploader  = "uploader"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Registration modes. Invite-only registration needs a code from an existing user.
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite"
+	RegistrationClosed = "closed"
+)
+
 type User struct {
-	ID       int
-	Username string
-	IsAdmin  bool
+	ID          int
+	Username    string
+	Email       string
+	Role        string
+	Permissions []enum.Permission
+	Disabled    bool
+	// TOTPEnabled is set once the user has confirmed an authenticator, TOTPRequired when an admin demands one.
+	TOTPEnabled  bool
+	TOTPRequired bool
+	// InviteID is the invite the user registered with, 0 when they did not need one.
+	InviteID int
+
+	DisplayName string
+	Bio         string
+	// Avatar is the stored name of the user's avatar image, empty when they have none.
+	Avatar           string
+	FavouritesPublic bool
+	CreatedAt        time.Time
+}
+
+func (u *User) Can(perm enum.Permission) bool {
+	return slices.Contains(u.Permissions, perm)
+}
+
+// Name is what profile pages call the user, their display name when they have set one.
+func (u *User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
+
+// NewAvatarName makes a random name for a stored avatar, so replacing one never reuses a cached URL.
+func NewAvatarName() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// AvatarKey is where an avatar is kept in blob storage, spread over directories like post files are.
+func AvatarKey(name string) string {
+	return path.Join("avatars", name[0:2], name[2:4], name+constant.ThumbnailExt)
+}
+
+// Identity links a user to the subject an OpenID Connect provider knows them by.
+type Identity struct {
+	ID          int
+	UserID      int
+	Provider    string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt *time.Time
+}
+
+// Invite lets up to MaxUses people register with Role. CreatedBy is 0 once the creator's account is gone.
+type Invite struct {
+	ID            int
+	Role          string
+	MaxUses       int
+	Uses          int
+	CreatedBy     int
+	CreatedByName string
+	CreatedAt     time.Time
+	ExpiresAt     *time.Time
+	RevokedAt     *time.Time
+}
+
+// Usable reports whether someone could still register with the invite at now.
+func (i *Invite) Usable(now time.Time) bool {
+	if i.RevokedAt != nil || i.Uses >= i.MaxUses {
+		return false
+	}
+	return i.ExpiresAt == nil || now.Before(*i.ExpiresAt)
+}
+
+type Role struct {
+	ID          int
+	Name        string
+	Permissions []enum.Permission
+}
+
+// TOTP is a user's authenticator secret. LastStep is the newest time step a code was accepted for,
+// so the same code cannot be replayed.
+type TOTP struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+// RecoveryCodeCount is how many recovery codes are handed out when two-factor login is enabled.
+const RecoveryCodeCount = 10
+
+// GenerateRecoveryCodes returns n random codes formatted as XXXXX-XXXXX.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// GenerateInviteCode returns a random code formatted as XXXX-XXXX-XXXX-XXXX.
+func GenerateInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.EncodeToString(b)
+	return code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:], nil
+}
+
+// HashInviteCode hashes an invite code for storage, ignoring case, spaces and dashes.
+func HashInviteCode(code string) string {
+	return HashRecoveryCode(code)
+}
+
+// HashRecoveryCode hashes a recovery code for storage, ignoring case, spaces and dashes.
+func HashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"goserv/ent/gen"
+	entIdentity "goserv/ent/gen/identity"
+	entRole "goserv/ent/gen/role"
+	"goserv/internal/domain/users"
+	"goserv/internal/utils/errors"
+	"time"
+)
+
+type Identity interface {
+	GetIdentity(ctx context.Context, provider string, subject string) (*users.Identity, error)
+	ListUserIdentities(ctx context.Context, userID int) ([]users.Identity, error)
+	AddIdentity(ctx context.Context, identity *users.Identity) error
+	// CreateUser registers user without a usable password and links identity to it, returning the new user's ID.
+	CreateUser(ctx context.Context, user *users.User, identity *users.Identity) (int, error)
+	TouchIdentity(ctx context.Context, identityID int, now time.Time) error
+}
+
+type identityRepository struct {
+	client *gen.Client
+}
+
+func NewIdentityRepository(client *gen.Client) *identityRepository {
+	return &identityRepository{client: client}
+}
+
+func (repo *identityRepository) GetIdentity(ctx context.Context, provider string, subject string) (*users.Identity, error) {
+	identity, err := repo.client.Identity.Query().
+		Where(entIdentity.ProviderEQ(provider), entIdentity.SubjectEQ(subject)).
+		Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return toDomainIdentity(identity), nil
+}
+
+func (repo *identityRepository) ListUserIdentities(ctx context.Context, userID int) ([]users.Identity, error) {
+	entIdentities, err := repo.client.Identity.Query().
+		Where(entIdentity.UserIDEQ(userID)).
+		Order(entIdentity.ByProvider()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]users.Identity, len(entIdentities))
+	for i := range entIdentities {
+		identities[i] = *toDomainIdentity(entIdentities[i])
+	}
+	return identities, nil
+}
+
+func (repo *identityRepository) AddIdentity(ctx context.Context, identity *users.Identity) error {
+	err := addIdentity(ctx, repo.client, identity)
+	if gen.IsConstraintError(err) {
+		return users.ErrIdentityTaken
+	}
+	return err
+}
+
+func (repo *identityRepository) CreateUser(ctx context.Context, user *users.User, identity *users.Identity) (int, error) {
+	var userID int
+	err := withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		if err := checkUsername(ctx, tx.Client(), user.Username); err != nil {
+			return err
+		}
+
+		roleID, err := tx.Role.Query().Where(entRole.NameEQ(user.Role)).OnlyID(ctx)
+		if err != nil {
+			return err
+		}
+
+		create := tx.User.Create().SetUsername(user.Username).SetPassHash(users.NoPassword).SetRoleID(roleID)
+		if user.Email != "" {
+			create.SetEmail(user.Email)
+		}
+		created, err := create.Save(ctx)
+		if err != nil {
+			return err
+		}
+
+		userID = created.ID
+		identity.UserID = created.ID
+		return addIdentity(ctx, tx.Client(), identity)
+	})
+	if gen.IsConstraintError(err) {
+		return 0, users.ErrIdentityTaken
+	}
+	return userID, err
+}
+
+func (repo *identityRepository) TouchIdentity(ctx context.Context, identityID int, now time.Time) error {
+	return repo.client.Identity.UpdateOneID(identityID).SetLastLoginAt(now).Exec(ctx)
+}
+
+func addIdentity(ctx context.Context, client *gen.Client, identity *users.Identity) error {
+	return client.Identity.Create().
+		SetUserID(identity.UserID).
+		SetProvider(identity.Provider).
+		SetSubject(identity.Subject).
+		SetEmail(identity.Email).
+		Exec(ctx)
+}
+
+func toDomainIdentity(identity *gen.Identity) *users.Identity {
+	return &users.Identity{
+		ID:          identity.ID,
+		UserID:      identity.UserID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		CreatedAt:   identity.CreatedAt,
+		LastLoginAt: identity.LastLoginAt,
+	}
+}
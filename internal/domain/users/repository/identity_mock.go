@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"goserv/internal/domain/users"
+	"time"
+)
+
+type IdentityMock struct {
+	GetIdentityFunc        func(ctx context.Context, provider string, subject string) (*users.Identity, error)
+	ListUserIdentitiesFunc func(ctx context.Context, userID int) ([]users.Identity, error)
+	AddIdentityFunc        func(ctx context.Context, identity *users.Identity) error
+	CreateUserFunc         func(ctx context.Context, user *users.User, identity *users.Identity) (int, error)
+	TouchIdentityFunc      func(ctx context.Context, identityID int, now time.Time) error
+}
+
+func (m *IdentityMock) GetIdentity(ctx context.Context, provider string, subject string) (*users.Identity, error) {
+	return m.GetIdentityFunc(ctx, provider, subject)
+}
+
+func (m *IdentityMock) ListUserIdentities(ctx context.Context, userID int) ([]users.Identity, error) {
+	return m.ListUserIdentitiesFunc(ctx, userID)
+}
+
+func (m *IdentityMock) AddIdentity(ctx context.Context, identity *users.Identity) error {
+	return m.AddIdentityFunc(ctx, identity)
+}
+
+func (m *IdentityMock) CreateUser(ctx context.Context, user *users.User, identity *users.Identity) (int, error) {
+	return m.CreateUserFunc(ctx, user, identity)
+}
+
+func (m *IdentityMock) TouchIdentity(ctx context.Context, identityID int, now time.Time) error {
+	return m.TouchIdentityFunc(ctx, identityID, now)
+}
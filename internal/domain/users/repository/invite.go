@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"goserv/ent/gen"
+	entInvite "goserv/ent/gen/invite"
+	"goserv/ent/gen/predicate"
+	entRole "goserv/ent/gen/role"
+	"goserv/internal/domain/users"
+	"goserv/internal/utils/errors"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+)
+
+type Invite interface {
+	AddInvite(ctx context.Context, invite *users.Invite, codeHash string) (int, error)
+	GetInvite(ctx context.Context, inviteID int) (*users.Invite, error)
+	GetInviteByCode(ctx context.Context, codeHash string) (*users.Invite, error)
+	ListInvites(ctx context.Context) ([]users.Invite, error)
+	ListUserInvites(ctx context.Context, userID int) ([]users.Invite, error)
+	RevokeInvite(ctx context.Context, inviteID int, now time.Time) error
+	// RegisterWithInvite uses up one use of the invite and creates the user with the invite's role.
+	RegisterWithInvite(ctx context.Context, user *users.User, passHash string, codeHash string, now time.Time) error
+}
+
+type inviteRepository struct {
+	client *gen.Client
+}
+
+func NewInviteRepository(client *gen.Client) *inviteRepository {
+	return &inviteRepository{client: client}
+}
+
+func (repo *inviteRepository) AddInvite(ctx context.Context, invite *users.Invite, codeHash string) (int, error) {
+	roleID, err := repo.client.Role.Query().Where(entRole.NameEQ(invite.Role)).OnlyID(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return 0, errors.ErrNotFound
+		}
+		return 0, err
+	}
+
+	create := repo.client.Invite.Create().
+		SetCodeHash(codeHash).
+		SetRoleID(roleID).
+		SetMaxUses(invite.MaxUses).
+		SetNillableExpiresAt(invite.ExpiresAt)
+	if invite.CreatedBy != 0 {
+		create.SetCreatedBy(invite.CreatedBy)
+	}
+
+	created, err := create.Save(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+func (repo *inviteRepository) GetInvite(ctx context.Context, inviteID int) (*users.Invite, error) {
+	return repo.getInvite(ctx, entInvite.IDEQ(inviteID))
+}
+
+func (repo *inviteRepository) GetInviteByCode(ctx context.Context, codeHash string) (*users.Invite, error) {
+	return repo.getInvite(ctx, entInvite.CodeHashEQ(codeHash))
+}
+
+func (repo *inviteRepository) getInvite(ctx context.Context, where predicate.Invite) (*users.Invite, error) {
+	invite, err := repo.client.Invite.Query().Where(where).WithRole().WithCreator().Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return toDomainInvite(invite), nil
+}
+
+func (repo *inviteRepository) ListInvites(ctx context.Context) ([]users.Invite, error) {
+	return repo.listInvites(ctx)
+}
+
+func (repo *inviteRepository) ListUserInvites(ctx context.Context, userID int) ([]users.Invite, error) {
+	return repo.listInvites(ctx, entInvite.CreatedByEQ(userID))
+}
+
+func (repo *inviteRepository) listInvites(ctx context.Context, where ...predicate.Invite) ([]users.Invite, error) {
+	entInvites, err := repo.client.Invite.Query().
+		Where(where...).
+		WithRole().
+		WithCreator().
+		Order(gen.Desc(entInvite.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invites := make([]users.Invite, len(entInvites))
+	for i := range entInvites {
+		invites[i] = *toDomainInvite(entInvites[i])
+	}
+	return invites, nil
+}
+
+func (repo *inviteRepository) RevokeInvite(ctx context.Context, inviteID int, now time.Time) error {
+	n, err := repo.client.Invite.Update().
+		Where(entInvite.IDEQ(inviteID), entInvite.RevokedAtIsNil()).
+		SetRevokedAt(now).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+func (repo *inviteRepository) RegisterWithInvite(ctx context.Context, user *users.User, passHash string, codeHash string, now time.Time) error {
+	err := withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		if err := checkUsername(ctx, tx.Client(), user.Username); err != nil {
+			return err
+		}
+
+		// counting the use in the same statement that checks it keeps two signups from sharing the last one
+		n, err := tx.Invite.Update().
+			Where(
+				entInvite.CodeHashEQ(codeHash),
+				entInvite.RevokedAtIsNil(),
+				entInvite.Or(entInvite.ExpiresAtIsNil(), entInvite.ExpiresAtGT(now)),
+				predicate.Invite(sql.FieldsLT(entInvite.FieldUses, entInvite.FieldMaxUses)),
+			).
+			AddUses(1).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return users.ErrInvalidInvite
+		}
+
+		invite, err := tx.Invite.Query().Where(entInvite.CodeHashEQ(codeHash)).Only(ctx)
+		if err != nil {
+			return err
+		}
+
+		return tx.User.Create().
+			SetUsername(user.Username).
+			SetPassHash(passHash).
+			SetRoleID(invite.RoleID).
+			SetInviteID(invite.ID).
+			Exec(ctx)
+	})
+	if gen.IsConstraintError(err) {
+		return users.ErrUsernameTaken
+	}
+	return err
+}
+
+func toDomainInvite(invite *gen.Invite) *users.Invite {
+	result := &users.Invite{
+		ID:        invite.ID,
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		CreatedAt: invite.CreatedAt,
+		ExpiresAt: invite.ExpiresAt,
+		RevokedAt: invite.RevokedAt,
+	}
+	if invite.CreatedBy != nil {
+		result.CreatedBy = *invite.CreatedBy
+	}
+	if invite.Edges.Creator != nil {
+		result.CreatedByName = invite.Edges.Creator.Username
+	}
+	if invite.Edges.Role != nil {
+		result.Role = invite.Edges.Role.Name
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"goserv/internal/domain/users"
+	"time"
+)
+
+type InviteMock struct {
+	AddInviteFunc          func(ctx context.Context, invite *users.Invite, codeHash string) (int, error)
+	GetInviteFunc          func(ctx context.Context, inviteID int) (*users.Invite, error)
+	GetInviteByCodeFunc    func(ctx context.Context, codeHash string) (*users.Invite, error)
+	ListInvitesFunc        func(ctx context.Context) ([]users.Invite, error)
+	ListUserInvitesFunc    func(ctx context.Context, userID int) ([]users.Invite, error)
+	RevokeInviteFunc       func(ctx context.Context, inviteID int, now time.Time) error
+	RegisterWithInviteFunc func(ctx context.Context, user *users.User, passHash string, codeHash string, now time.Time) error
+}
+
+func (m *InviteMock) AddInvite(ctx context.Context, invite *users.Invite, codeHash string) (int, error) {
+	return m.AddInviteFunc(ctx, invite, codeHash)
+}
+
+func (m *InviteMock) GetInvite(ctx context.Context, inviteID int) (*users.Invite, error) {
+	return m.GetInviteFunc(ctx, inviteID)
+}
+
+func (m *InviteMock) GetInviteByCode(ctx context.Context, codeHash string) (*users.Invite, error) {
+	return m.GetInviteByCodeFunc(ctx, codeHash)
+}
+
+func (m *InviteMock) ListInvites(ctx context.Context) ([]users.Invite, error) {
+	return m.ListInvitesFunc(ctx)
+}
+
+func (m *InviteMock) ListUserInvites(ctx context.Context, userID int) ([]users.Invite, error) {
+	return m.ListUserInvitesFunc(ctx, userID)
+}
+
+func (m *InviteMock) RevokeInvite(ctx context.Context, inviteID int, now time.Time) error {
+	return m.RevokeInviteFunc(ctx, inviteID, now)
+}
+
+func (m *InviteMock) RegisterWithInvite(ctx context.Context, user *users.User, passHash string, codeHash string, now time.Time) error {
+	return m.RegisterWithInviteFunc(ctx, user, passHash, codeHash, now)
+}
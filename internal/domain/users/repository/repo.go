@@ -3,10 +3,14 @@ package repository
 import (
 	"context"
 	"goserv/ent/gen"
+	entRecovery "goserv/ent/gen/recoverycode"
+	entRole "goserv/ent/gen/role"
 	entUser "goserv/ent/gen/user"
 	"goserv/internal/domain/users"
-
-	"golang.org/x/crypto/bcrypt"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/errors"
+	"goserv/pkg/password"
+	"log"
 )
 
 type User interface {
@@ -14,55 +18,392 @@ type User interface {
 	GetByUsername(ctx context.Context, username string) (*users.User, error)
 	CheckPassword(ctx context.Context, username string, password string) (*users.User, bool, error)
 	GetByUserID(ctx context.Context, userID int) (*users.User, error)
-	IsAdmin(ctx context.Context, userID int) (bool, error)
+	GetByEmail(ctx context.Context, email string) (*users.User, error)
+	SetEmail(ctx context.Context, userID int, email string) error
+	GetProfile(ctx context.Context, username string) (*users.User, error)
+	UpdateProfile(ctx context.Context, userID int, displayName string, bio string, favouritesPublic bool) error
+	SetAvatar(ctx context.Context, userID int, avatar string) error
+	GetPermissions(ctx context.Context, userID int) ([]enum.Permission, error)
+	UpdatePassword(ctx context.Context, userID int, passHash string) error
+	ListUsers(ctx context.Context) ([]users.User, error)
+	SetRole(ctx context.Context, userID int, role string) error
+	ListRoles(ctx context.Context) ([]users.Role, error)
+	SetDisabled(ctx context.Context, userID int, disabled bool) error
+	DeleteUser(ctx context.Context, userID int) error
+	GetTOTP(ctx context.Context, userID int) (*users.TOTP, error)
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+	SetTOTPRequired(ctx context.Context, userID int, required bool) error
 }
 
 type userRepository struct {
 	client *gen.Client
+	hasher *password.Hasher
 }
 
-func NewUserRepository(client *gen.Client) *userRepository {
-	return &userRepository{client: client}
+func NewUserRepository(client *gen.Client, hasher *password.Hasher) *userRepository {
+	return &userRepository{client: client, hasher: hasher}
 }
 
 func (repo *userRepository) Register(ctx context.Context, user *users.User, passHash string) error {
-	_, err := repo.client.User.Create().SetUsername(user.Username).SetPassHash(passHash).SetIsAdmin(user.IsAdmin).Save(ctx)
+	err := withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		if err := checkUsername(ctx, tx.Client(), user.Username); err != nil {
+			return err
+		}
+
+		roleID, err := tx.Role.Query().Where(entRole.NameEQ(user.Role)).OnlyID(ctx)
+		if err != nil {
+			return err
+		}
+		return tx.User.Create().SetUsername(user.Username).SetPassHash(passHash).SetRoleID(roleID).Exec(ctx)
+	})
+	if gen.IsConstraintError(err) {
+		return users.ErrUsernameTaken
+	}
 	return err
 }
 
 func (repo *userRepository) GetByUsername(ctx context.Context, username string) (*users.User, error) {
-	user, err := repo.client.User.Query().Where(entUser.UsernameEQ(username)).Only(ctx)
+	user, err := repo.client.User.Query().Where(entUser.UsernameEQ(username)).WithRole().Only(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &users.User{ID: user.ID, Username: user.Username, IsAdmin: user.IsAdmin}, nil
+	return toDomainUser(user), nil
 }
 
+// CheckPassword verifies the password against a hash from any known scheme, upgrading outdated hashes on a match.
 func (repo *userRepository) CheckPassword(ctx context.Context, username string, password string) (*users.User, bool, error) {
-	user, err := repo.client.User.Query().Where(entUser.UsernameEQ(username)).Only(ctx)
+	user, err := repo.client.User.Query().Where(entUser.UsernameEQ(username)).WithRole().Only(ctx)
 	if err != nil {
 		return nil, false, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.PassHash), []byte(password))
-	if err != nil {
+	if user.PassHash == users.NoPassword {
 		return nil, false, nil
 	}
-	return &users.User{ID: user.ID, Username: user.Username, IsAdmin: user.IsAdmin}, true, nil
+
+	isMatch, rehash, err := repo.hasher.Verify(password, user.PassHash)
+	if err != nil || !isMatch {
+		return nil, false, err
+	}
+	if rehash {
+		repo.upgradeHash(ctx, user, password)
+	}
+	return toDomainUser(user), true, nil
+}
+
+// upgradeHash replaces a hash made by an older scheme or with weaker settings now that the password
+// is known. Failing only leaves the old hash in place, so the login goes ahead regardless.
+func (repo *userRepository) upgradeHash(ctx context.Context, user *gen.User, password string) {
+	passHash, err := repo.hasher.Hash(password)
+	if err != nil {
+		log.Printf("Failed to rehash password of user %d: %v\n", user.ID, err)
+		return
+	}
+
+	// a password changed in the meantime is left alone
+	err = repo.client.User.UpdateOneID(user.ID).Where(entUser.PassHashEQ(user.PassHash)).SetPassHash(passHash).Exec(ctx)
+	if err != nil && !gen.IsNotFound(err) {
+		log.Printf("Failed to store upgraded password hash of user %d: %v\n", user.ID, err)
+	}
 }
 
 func (repo *userRepository) GetByUserID(ctx context.Context, userID int) (*users.User, error) {
-	user, err := repo.client.User.Query().Where(entUser.IDEQ(userID)).Only(ctx)
+	user, err := repo.client.User.Query().Where(entUser.IDEQ(userID)).WithRole().Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toDomainUser(user), nil
+}
+
+func (repo *userRepository) GetByEmail(ctx context.Context, email string) (*users.User, error) {
+	user, err := repo.client.User.Query().Where(entUser.EmailEQ(email)).WithRole().Only(ctx)
 	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
 		return nil, err
 	}
-	return &users.User{ID: user.ID, Username: user.Username, IsAdmin: user.IsAdmin}, nil
+	return toDomainUser(user), nil
+}
+
+// SetEmail changes the user's email address, clearing it when email is empty.
+func (repo *userRepository) SetEmail(ctx context.Context, userID int, email string) error {
+	update := repo.client.User.UpdateOneID(userID)
+	if email == "" {
+		update.ClearEmail()
+	} else {
+		update.SetEmail(email)
+	}
+
+	err := update.Exec(ctx)
+	if gen.IsConstraintError(err) {
+		return users.ErrEmailTaken
+	}
+	return err
 }
 
-func (repo *userRepository) IsAdmin(ctx context.Context, userID int) (bool, error) {
-	user, err := repo.client.User.Query().Select(entUser.FieldIsAdmin).Where(entUser.IDEQ(userID)).Only(ctx)
+// GetProfile finds the user a profile page is for, ignoring the case of the username.
+func (repo *userRepository) GetProfile(ctx context.Context, username string) (*users.User, error) {
+	user, err := repo.client.User.Query().Where(entUser.UsernameEqualFold(username)).WithRole().Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return toDomainUser(user), nil
+}
+
+func (repo *userRepository) UpdateProfile(ctx context.Context, userID int, displayName string, bio string, favouritesPublic bool) error {
+	err := repo.client.User.UpdateOneID(userID).
+		SetDisplayName(displayName).
+		SetBio(bio).
+		SetFavouritesPublic(favouritesPublic).
+		Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+// SetAvatar records the user's avatar, clearing it when avatar is empty.
+func (repo *userRepository) SetAvatar(ctx context.Context, userID int, avatar string) error {
+	update := repo.client.User.UpdateOneID(userID)
+	if avatar == "" {
+		update.ClearAvatar()
+	} else {
+		update.SetAvatar(avatar)
+	}
+
+	err := update.Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func (repo *userRepository) GetPermissions(ctx context.Context, userID int) ([]enum.Permission, error) {
+	role, err := repo.client.User.Query().Where(entUser.IDEQ(userID)).QueryRole().Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toPermissions(role.Permissions), nil
+}
+
+func (repo *userRepository) UpdatePassword(ctx context.Context, userID int, passHash string) error {
+	return repo.client.User.UpdateOneID(userID).SetPassHash(passHash).Exec(ctx)
+}
+
+func (repo *userRepository) ListUsers(ctx context.Context) ([]users.User, error) {
+	entUsers, err := repo.client.User.Query().WithRole().Order(entUser.ByUsername()).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	returnUsers := make([]users.User, len(entUsers))
+	for i := range entUsers {
+		returnUsers[i] = *toDomainUser(entUsers[i])
+	}
+	return returnUsers, nil
+}
+
+func (repo *userRepository) SetRole(ctx context.Context, userID int, role string) error {
+	roleID, err := repo.client.Role.Query().Where(entRole.NameEQ(role)).OnlyID(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return errors.ErrNotFound
+		}
+		return err
+	}
+
+	err = repo.client.User.UpdateOneID(userID).SetRoleID(roleID).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func (repo *userRepository) ListRoles(ctx context.Context) ([]users.Role, error) {
+	entRoles, err := repo.client.Role.Query().Order(entRole.ByID()).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]users.Role, len(entRoles))
+	for i := range entRoles {
+		roles[i] = users.Role{ID: entRoles[i].ID, Name: entRoles[i].Name, Permissions: toPermissions(entRoles[i].Permissions)}
+	}
+	return roles, nil
+}
+
+func (repo *userRepository) SetDisabled(ctx context.Context, userID int, disabled bool) error {
+	err := repo.client.User.UpdateOneID(userID).SetDisabled(disabled).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+// DeleteUser removes the account along with its sessions, tokens and favourites. Its posts are kept without an owner.
+func (repo *userRepository) DeleteUser(ctx context.Context, userID int) error {
+	err := repo.client.User.DeleteOneID(userID).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func (repo *userRepository) GetTOTP(ctx context.Context, userID int) (*users.TOTP, error) {
+	user, err := repo.client.User.Get(ctx, userID)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return &users.TOTP{Secret: user.TotpSecret, Enabled: user.TotpEnabled, LastStep: user.TotpLastStep}, nil
+}
+
+// SetTOTPSecret stores a secret that is waiting to be confirmed with a first code.
+func (repo *userRepository) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return repo.client.User.UpdateOneID(userID).SetTotpSecret(secret).SetTotpEnabled(false).Exec(ctx)
+}
+
+// EnableTOTP turns on two-factor login, marking step as used and replacing any recovery codes.
+func (repo *userRepository) EnableTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	return withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		if err := tx.User.UpdateOneID(userID).SetTotpEnabled(true).SetTotpLastStep(step).Exec(ctx); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(ctx, tx.Client(), userID, codeHashes)
+	})
+}
+
+func (repo *userRepository) DisableTOTP(ctx context.Context, userID int) error {
+	return withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		err := tx.User.UpdateOneID(userID).ClearTotpSecret().SetTotpEnabled(false).SetTotpLastStep(0).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.RecoveryCode.Delete().Where(entRecovery.UserIDEQ(userID)).Exec(ctx)
+		return err
+	})
+}
+
+// UseTOTPStep records step as used. It reports false when a code for this or a later step was already accepted.
+func (repo *userRepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	n, err := repo.client.User.Update().
+		Where(entUser.IDEQ(userID), entUser.TotpLastStepLT(step)).
+		SetTotpLastStep(step).
+		Save(ctx)
 	if err != nil {
 		return false, err
 	}
-	return user.IsAdmin, nil
+	return n == 1, nil
+}
+
+// UseRecoveryCode deletes the matching code, reporting whether there was one to use.
+func (repo *userRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	n, err := repo.client.RecoveryCode.Delete().
+		Where(entRecovery.UserIDEQ(userID), entRecovery.CodeHashEQ(codeHash)).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (repo *userRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return withTx(ctx, repo.client, func(tx *gen.Tx) error {
+		return replaceRecoveryCodes(ctx, tx.Client(), userID, codeHashes)
+	})
+}
+
+func (repo *userRepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	return repo.client.RecoveryCode.Query().Where(entRecovery.UserIDEQ(userID)).Count(ctx)
+}
+
+func (repo *userRepository) SetTOTPRequired(ctx context.Context, userID int, required bool) error {
+	err := repo.client.User.UpdateOneID(userID).SetTotpRequired(required).Exec(ctx)
+	if gen.IsNotFound(err) {
+		return errors.ErrNotFound
+	}
+	return err
+}
+
+func replaceRecoveryCodes(ctx context.Context, client *gen.Client, userID int, codeHashes []string) error {
+	if _, err := client.RecoveryCode.Delete().Where(entRecovery.UserIDEQ(userID)).Exec(ctx); err != nil {
+		return err
+	}
+
+	builders := make([]*gen.RecoveryCodeCreate, len(codeHashes))
+	for i := range codeHashes {
+		builders[i] = client.RecoveryCode.Create().SetUserID(userID).SetCodeHash(codeHashes[i])
+	}
+	return client.RecoveryCode.CreateBulk(builders...).Exec(ctx)
+}
+
+// checkUsername returns users.ErrUsernameTaken when someone already has username in any letter case.
+func checkUsername(ctx context.Context, client *gen.Client, username string) error {
+	taken, err := client.User.Query().Where(entUser.UsernameEqualFold(username)).Exist(ctx)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return users.ErrUsernameTaken
+	}
+	return nil
+}
+
+func withTx(ctx context.Context, client *gen.Client, fn func(tx *gen.Tx) error) error {
+	tx, err := client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func toDomainUser(user *gen.User) *users.User {
+	result := &users.User{
+		ID:           user.ID,
+		Username:     user.Username,
+		Disabled:     user.Disabled,
+		TOTPEnabled:  user.TotpEnabled,
+		TOTPRequired: user.TotpRequired,
+
+		DisplayName:      user.DisplayName,
+		Bio:              user.Bio,
+		Avatar:           user.Avatar,
+		FavouritesPublic: user.FavouritesPublic,
+		CreatedAt:        user.CreatedAt,
+	}
+	if user.Email != nil {
+		result.Email = *user.Email
+	}
+	if user.InviteID != nil {
+		result.InviteID = *user.InviteID
+	}
+	if user.Edges.Role != nil {
+		result.Role = user.Edges.Role.Name
+		result.Permissions = toPermissions(user.Edges.Role.Permissions)
+	}
+	return result
+}
+
+func toPermissions(names []string) []enum.Permission {
+	perms := make([]enum.Permission, len(names))
+	for i := range names {
+		perms[i] = enum.Permission(names[i])
+	}
+	return perms
 }
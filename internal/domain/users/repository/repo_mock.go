@@ -3,14 +3,36 @@ package repository
 import (
 	"context"
 	"goserv/internal/domain/users"
+	"goserv/internal/static/enum"
 )
 
 type UserMock struct {
-	RegisterFunc      func(ctx context.Context, user *users.User, passHash string) error
-	GetByUsernameFunc func(ctx context.Context, username string) (*users.User, error)
-	CheckPasswordFunc func(ctx context.Context, username string, password string) (*users.User, bool, error)
-	GetByUserIDFunc   func(ctx context.Context, userID int) (*users.User, error)
-	IsAdminFunc       func(ctx context.Context, userID int) (bool, error)
+	RegisterFunc       func(ctx context.Context, user *users.User, passHash string) error
+	GetByUsernameFunc  func(ctx context.Context, username string) (*users.User, error)
+	CheckPasswordFunc  func(ctx context.Context, username string, password string) (*users.User, bool, error)
+	GetByUserIDFunc    func(ctx context.Context, userID int) (*users.User, error)
+	GetByEmailFunc     func(ctx context.Context, email string) (*users.User, error)
+	SetEmailFunc       func(ctx context.Context, userID int, email string) error
+	GetProfileFunc     func(ctx context.Context, username string) (*users.User, error)
+	UpdateProfileFunc  func(ctx context.Context, userID int, displayName string, bio string, favouritesPublic bool) error
+	SetAvatarFunc      func(ctx context.Context, userID int, avatar string) error
+	GetPermissionsFunc func(ctx context.Context, userID int) ([]enum.Permission, error)
+	UpdatePasswordFunc func(ctx context.Context, userID int, passHash string) error
+	ListUsersFunc      func(ctx context.Context) ([]users.User, error)
+	SetRoleFunc        func(ctx context.Context, userID int, role string) error
+	ListRolesFunc      func(ctx context.Context) ([]users.Role, error)
+	SetDisabledFunc    func(ctx context.Context, userID int, disabled bool) error
+	DeleteUserFunc     func(ctx context.Context, userID int) error
+
+	GetTOTPFunc              func(ctx context.Context, userID int) (*users.TOTP, error)
+	SetTOTPSecretFunc        func(ctx context.Context, userID int, secret string) error
+	EnableTOTPFunc           func(ctx context.Context, userID int, step int64, codeHashes []string) error
+	DisableTOTPFunc          func(ctx context.Context, userID int) error
+	UseTOTPStepFunc          func(ctx context.Context, userID int, step int64) (bool, error)
+	UseRecoveryCodeFunc      func(ctx context.Context, userID int, codeHash string) (bool, error)
+	ReplaceRecoveryCodesFunc func(ctx context.Context, userID int, codeHashes []string) error
+	CountRecoveryCodesFunc   func(ctx context.Context, userID int) (int, error)
+	SetTOTPRequiredFunc      func(ctx context.Context, userID int, required bool) error
 }
 
 func (m *UserMock) Register(ctx context.Context, user *users.User, passHash string) error {
@@ -29,6 +51,86 @@ func (m *UserMock) GetByUserID(ctx context.Context, userID int) (*users.User, er
 	return m.GetByUserIDFunc(ctx, userID)
 }
 
-func (m *UserMock) IsAdmin(ctx context.Context, userID int) (bool, error) {
-	return m.IsAdminFunc(ctx, userID)
+func (m *UserMock) GetByEmail(ctx context.Context, email string) (*users.User, error) {
+	return m.GetByEmailFunc(ctx, email)
+}
+
+func (m *UserMock) SetEmail(ctx context.Context, userID int, email string) error {
+	return m.SetEmailFunc(ctx, userID, email)
+}
+
+func (m *UserMock) GetProfile(ctx context.Context, username string) (*users.User, error) {
+	return m.GetProfileFunc(ctx, username)
+}
+
+func (m *UserMock) UpdateProfile(ctx context.Context, userID int, displayName string, bio string, favouritesPublic bool) error {
+	return m.UpdateProfileFunc(ctx, userID, displayName, bio, favouritesPublic)
+}
+
+func (m *UserMock) SetAvatar(ctx context.Context, userID int, avatar string) error {
+	return m.SetAvatarFunc(ctx, userID, avatar)
+}
+
+func (m *UserMock) GetPermissions(ctx context.Context, userID int) ([]enum.Permission, error) {
+	return m.GetPermissionsFunc(ctx, userID)
+}
+
+func (m *UserMock) UpdatePassword(ctx context.Context, userID int, passHash string) error {
+	return m.UpdatePasswordFunc(ctx, userID, passHash)
+}
+
+func (m *UserMock) ListUsers(ctx context.Context) ([]users.User, error) {
+	return m.ListUsersFunc(ctx)
+}
+
+func (m *UserMock) SetRole(ctx context.Context, userID int, role string) error {
+	return m.SetRoleFunc(ctx, userID, role)
+}
+
+func (m *UserMock) ListRoles(ctx context.Context) ([]users.Role, error) {
+	return m.ListRolesFunc(ctx)
+}
+
+func (m *UserMock) SetDisabled(ctx context.Context, userID int, disabled bool) error {
+	return m.SetDisabledFunc(ctx, userID, disabled)
+}
+
+func (m *UserMock) DeleteUser(ctx context.Context, userID int) error {
+	return m.DeleteUserFunc(ctx, userID)
+}
+
+func (m *UserMock) GetTOTP(ctx context.Context, userID int) (*users.TOTP, error) {
+	return m.GetTOTPFunc(ctx, userID)
+}
+
+func (m *UserMock) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return m.SetTOTPSecretFunc(ctx, userID, secret)
+}
+
+func (m *UserMock) EnableTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	return m.EnableTOTPFunc(ctx, userID, step, codeHashes)
+}
+
+func (m *UserMock) DisableTOTP(ctx context.Context, userID int) error {
+	return m.DisableTOTPFunc(ctx, userID)
+}
+
+func (m *UserMock) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	return m.UseTOTPStepFunc(ctx, userID, step)
+}
+
+func (m *UserMock) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	return m.UseRecoveryCodeFunc(ctx, userID, codeHash)
+}
+
+func (m *UserMock) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return m.ReplaceRecoveryCodesFunc(ctx, userID, codeHashes)
+}
+
+func (m *UserMock) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	return m.CountRecoveryCodesFunc(ctx, userID)
+}
+
+func (m *UserMock) SetTOTPRequired(ctx context.Context, userID int, required bool) error {
+	return m.SetTOTPRequiredFunc(ctx, userID, required)
 }
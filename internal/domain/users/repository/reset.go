@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"goserv/ent/gen"
+	entReset "goserv/ent/gen/passwordreset"
+	"goserv/internal/utils/errors"
+	"time"
+)
+
+type PasswordReset interface {
+	AddReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	// LatestReset returns when the user's newest reset token was created.
+	LatestReset(ctx context.Context, userID int) (time.Time, error)
+	// ResetUser returns the user an unexpired token belongs to without using it up.
+	ResetUser(ctx context.Context, tokenHash string, now time.Time) (int, error)
+	// UseReset consumes an unexpired token and returns the user it belongs to.
+	UseReset(ctx context.Context, tokenHash string, now time.Time) (int, error)
+	DeleteUserResets(ctx context.Context, userID int) error
+	DeleteExpiredResets(ctx context.Context, now time.Time) (int, error)
+}
+
+type resetRepository struct {
+	client *gen.Client
+}
+
+func NewResetRepository(client *gen.Client) *resetRepository {
+	return &resetRepository{client: client}
+}
+
+func (repo *resetRepository) AddReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	return repo.client.PasswordReset.Create().
+		SetUserID(userID).
+		SetTokenHash(tokenHash).
+		SetExpiresAt(expiresAt).
+		Exec(ctx)
+}
+
+func (repo *resetRepository) LatestReset(ctx context.Context, userID int) (time.Time, error) {
+	reset, err := repo.client.PasswordReset.Query().
+		Where(entReset.UserIDEQ(userID)).
+		Order(gen.Desc(entReset.FieldCreatedAt)).
+		First(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return time.Time{}, errors.ErrNotFound
+		}
+		return time.Time{}, err
+	}
+	return reset.CreatedAt, nil
+}
+
+func (repo *resetRepository) ResetUser(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	reset, err := repo.client.PasswordReset.Query().
+		Where(entReset.TokenHashEQ(tokenHash), entReset.ExpiresAtGT(now)).
+		Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return 0, errors.ErrNotFound
+		}
+		return 0, err
+	}
+	return reset.UserID, nil
+}
+
+func (repo *resetRepository) UseReset(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	reset, err := repo.client.PasswordReset.Query().
+		Where(entReset.TokenHashEQ(tokenHash), entReset.ExpiresAtGT(now)).
+		Only(ctx)
+	if err != nil {
+		if gen.IsNotFound(err) {
+			return 0, errors.ErrNotFound
+		}
+		return 0, err
+	}
+
+	// only the request that actually deletes the token gets to use it
+	n, err := repo.client.PasswordReset.Delete().Where(entReset.IDEQ(reset.ID)).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errors.ErrNotFound
+	}
+	return reset.UserID, nil
+}
+
+func (repo *resetRepository) DeleteUserResets(ctx context.Context, userID int) error {
+	_, err := repo.client.PasswordReset.Delete().Where(entReset.UserIDEQ(userID)).Exec(ctx)
+	return err
+}
+
+func (repo *resetRepository) DeleteExpiredResets(ctx context.Context, now time.Time) (int, error) {
+	return repo.client.PasswordReset.Delete().Where(entReset.ExpiresAtLTE(now)).Exec(ctx)
+}
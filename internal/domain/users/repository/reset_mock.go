@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+type PasswordResetMock struct {
+	AddResetFunc            func(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	LatestResetFunc         func(ctx context.Context, userID int) (time.Time, error)
+	ResetUserFunc           func(ctx context.Context, tokenHash string, now time.Time) (int, error)
+	UseResetFunc            func(ctx context.Context, tokenHash string, now time.Time) (int, error)
+	DeleteUserResetsFunc    func(ctx context.Context, userID int) error
+	DeleteExpiredResetsFunc func(ctx context.Context, now time.Time) (int, error)
+}
+
+func (m *PasswordResetMock) AddReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	return m.AddResetFunc(ctx, userID, tokenHash, expiresAt)
+}
+
+func (m *PasswordResetMock) LatestReset(ctx context.Context, userID int) (time.Time, error) {
+	return m.LatestResetFunc(ctx, userID)
+}
+
+func (m *PasswordResetMock) ResetUser(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	return m.ResetUserFunc(ctx, tokenHash, now)
+}
+
+func (m *PasswordResetMock) UseReset(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	return m.UseResetFunc(ctx, tokenHash, now)
+}
+
+func (m *PasswordResetMock) DeleteUserResets(ctx context.Context, userID int) error {
+	return m.DeleteUserResetsFunc(ctx, userID)
+}
+
+func (m *PasswordResetMock) DeleteExpiredResets(ctx context.Context, now time.Time) (int, error) {
+	return m.DeleteExpiredResetsFunc(ctx, now)
+}
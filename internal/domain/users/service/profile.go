@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"goserv/internal/domain/users"
+	"goserv/internal/domain/users/repository"
+	"goserv/internal/static/constant"
+	"goserv/internal/utils"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/storage"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var ErrInvalidAvatar = errors.New("avatar must be a JPEG, PNG, GIF, BMP or TIFF image")
+
+type ProfileService struct {
+	repo repository.User
+	blob storage.Blob
+}
+
+func NewProfileService(repo repository.User, blob storage.Blob) *ProfileService {
+	return &ProfileService{repo: repo, blob: blob}
+}
+
+// GetProfile returns the user whose public profile is at username. Disabled accounts have no profile.
+func (s *ProfileService) GetProfile(ctx context.Context, username string) (*users.User, error) {
+	user, err := s.repo.GetProfile(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, myErrors.ErrNotFound
+	}
+	return user, nil
+}
+
+// UpdateProfile changes what the user's profile page shows. Fields breaking the rules come back as a *users.ValidationError.
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID int, displayName string, bio string, favouritesPublic bool) error {
+	displayName = strings.TrimSpace(displayName)
+	bio = strings.TrimSpace(strings.ReplaceAll(bio, "\r\n", "\n"))
+
+	invalid := &users.ValidationError{Fields: map[string]string{}}
+	if err := validate.DisplayName(displayName); err != nil {
+		invalid.Fields["display_name"] = err.Error()
+	}
+	if err := validate.Bio(bio); err != nil {
+		invalid.Fields["bio"] = err.Error()
+	}
+	if len(invalid.Fields) > 0 {
+		return invalid
+	}
+	return s.repo.UpdateProfile(ctx, userID, displayName, bio, favouritesPublic)
+}
+
+// SetAvatar scales the uploaded image down the same way post thumbnails are made and makes it the
+// user's avatar, removing the one it replaces.
+func (s *ProfileService) SetAvatar(ctx context.Context, userID int, content io.Reader) error {
+	user, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp("tmp", "avatar-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	_, err = io.Copy(tempFile, content)
+	tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	scaledPath := tempFile.Name() + constant.ThumbnailExt
+	defer os.Remove(scaledPath)
+	if err := utils.CreateImageThumbnail(tempFile.Name(), scaledPath); err != nil {
+		return ErrInvalidAvatar
+	}
+
+	name, err := users.NewAvatarName()
+	if err != nil {
+		return err
+	}
+	scaled, err := os.Open(scaledPath)
+	if err != nil {
+		return err
+	}
+	defer scaled.Close()
+
+	if err := s.blob.Put(ctx, users.AvatarKey(name), scaled, "image/jpeg"); err != nil {
+		return err
+	}
+	if err := s.repo.SetAvatar(ctx, userID, name); err != nil {
+		s.DiscardAvatar(ctx, name)
+		return err
+	}
+	s.DiscardAvatar(ctx, user.Avatar)
+	return nil
+}
+
+func (s *ProfileService) RemoveAvatar(ctx context.Context, userID int) error {
+	user, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Avatar == "" {
+		return nil
+	}
+
+	if err := s.repo.SetAvatar(ctx, userID, ""); err != nil {
+		return err
+	}
+	s.DiscardAvatar(ctx, user.Avatar)
+	return nil
+}
+
+// DiscardAvatar deletes a stored avatar no user points at anymore. An empty name does nothing.
+func (s *ProfileService) DiscardAvatar(ctx context.Context, name string) {
+	if name == "" {
+		return
+	}
+	key := users.AvatarKey(name)
+	if err := s.blob.Delete(ctx, key); err != nil {
+		log.Printf("Failed to remove avatar: %s, %v\n", key, err)
+	}
+}
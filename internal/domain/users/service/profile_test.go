@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"goserv/internal/domain/users"
+	"goserv/internal/domain/users/repository"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/storage"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileService_GetProfile(t *testing.T) {
+	userRepo := &repository.UserMock{
+		GetProfileFunc: func(ctx context.Context, username string) (*users.User, error) {
+			switch username {
+			case "alice":
+				return &users.User{ID: 1, Username: "Alice"}, nil
+			case "banned":
+				return &users.User{ID: 2, Username: "banned", Disabled: true}, nil
+			}
+			return nil, myErrors.ErrNotFound
+		},
+	}
+	service := NewProfileService(userRepo, nil)
+
+	user, err := service.GetProfile(context.Background(), "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.ID)
+
+	_, err = service.GetProfile(context.Background(), "banned")
+	assert.Equal(t, myErrors.ErrNotFound, err)
+
+	_, err = service.GetProfile(context.Background(), "nobody")
+	assert.Equal(t, myErrors.ErrNotFound, err)
+}
+
+func TestProfileService_UpdateProfile(t *testing.T) {
+	type args struct {
+		displayName string
+		bio         string
+	}
+	type want struct {
+		displayName string
+		bio         string
+		err         error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "trimmed",
+			args: args{displayName: "  Alice A. ", bio: " Takes photos.\r\nMostly birds. "},
+			want: want{displayName: "Alice A.", bio: "Takes photos.\nMostly birds.", err: nil},
+		},
+		{
+			name: "cleared",
+			args: args{displayName: "", bio: ""},
+			want: want{displayName: "", bio: "", err: nil},
+		},
+		{
+			name: "too long and with a line break",
+			args: args{displayName: "Alice\nA.", bio: strings.Repeat("a", validate.MaxBioLen+1)},
+			want: want{err: &users.ValidationError{Fields: map[string]string{
+				"display_name": validate.ErrDisplayNameChars.Error(),
+				"bio":          validate.ErrBioLength.Error(),
+			}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saved := false
+			userRepo := &repository.UserMock{
+				UpdateProfileFunc: func(ctx context.Context, userID int, displayName string, bio string, favouritesPublic bool) error {
+					assert.Equal(t, test.want.displayName, displayName)
+					assert.Equal(t, test.want.bio, bio)
+					assert.True(t, favouritesPublic)
+					saved = true
+					return nil
+				},
+			}
+			service := NewProfileService(userRepo, nil)
+
+			err := service.UpdateProfile(context.Background(), 1, test.args.displayName, test.args.bio, true)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.err == nil, saved)
+		})
+	}
+}
+
+func TestProfileService_SetAvatar(t *testing.T) {
+	old := strings.Repeat("ab", 32)
+
+	type test struct {
+		name    string
+		content func(t *testing.T) []byte
+		err     error
+	}
+
+	tests := []test{
+		{
+			name: "png replaces the old avatar",
+			content: func(t *testing.T) []byte {
+				var buf bytes.Buffer
+				require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 600))))
+				return buf.Bytes()
+			},
+			err: nil,
+		},
+		{
+			name: "not an image",
+			content: func(t *testing.T) []byte {
+				return []byte("just text")
+			},
+			err: ErrInvalidAvatar,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			require.NoError(t, os.Mkdir("tmp", 0755))
+			oldPath := filepath.FromSlash(users.AvatarKey(old))
+			require.NoError(t, os.MkdirAll(filepath.Dir(oldPath), 0755))
+			require.NoError(t, os.WriteFile(oldPath, nil, 0644))
+
+			avatar := ""
+			userRepo := &repository.UserMock{
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					return &users.User{ID: userID, Avatar: old}, nil
+				},
+				SetAvatarFunc: func(ctx context.Context, userID int, name string) error {
+					avatar = name
+					return nil
+				},
+			}
+			service := NewProfileService(userRepo, storage.NewLocal("."))
+
+			err := service.SetAvatar(context.Background(), 1, bytes.NewReader(test.content(t)))
+			assert.Equal(t, test.err, err)
+			if test.err != nil {
+				assert.Empty(t, avatar)
+				assert.FileExists(t, oldPath)
+				return
+			}
+
+			assert.Len(t, avatar, 64)
+			assert.FileExists(t, filepath.FromSlash(users.AvatarKey(avatar)))
+			assert.NoFileExists(t, oldPath)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"goserv/internal/domain/users"
+	"goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/password"
+	"strings"
+	"time"
+)
+
+var ErrRegistrationClosed = errors.New("registration is closed")
+var ErrInviteRequired = errors.New("an invite code is required to register")
+var ErrInvalidUses = errors.New("invite cannot be used that many times")
+var ErrInvalidExpiry = errors.New("invite expiry is out of range")
+var ErrInvalidRole = errors.New("invite role does not exist")
+
+type RegistrationOptions struct {
+	// Mode is users.RegistrationOpen, users.RegistrationInvite or users.RegistrationClosed
+	Mode string
+	// DefaultRole is given to users registering without an invite and to invites made by non-admins
+	DefaultRole string
+	// MaxUses and MaxTTL limit the invites users who cannot manage users make
+	MaxUses int
+	MaxTTL  time.Duration
+}
+
+type RegistrationService struct {
+	repo       repository.User
+	inviteRepo repository.Invite
+	hasher     *password.Hasher
+	opts       RegistrationOptions
+	now        func() time.Time
+}
+
+func NewRegistrationService(repo repository.User, inviteRepo repository.Invite, hasher *password.Hasher, opts RegistrationOptions) *RegistrationService {
+	return &RegistrationService{repo: repo, inviteRepo: inviteRepo, hasher: hasher, opts: opts, now: time.Now}
+}
+
+func (s *RegistrationService) Mode() string {
+	return s.opts.Mode
+}
+
+// Register creates an account. An invite code is needed in invite-only mode; given in open mode it
+// still sets the new user's role and is recorded against them. Usernames and passwords breaking the
+// rules come back as a *users.ValidationError.
+func (s *RegistrationService) Register(ctx context.Context, username string, password string, code string) error {
+	if s.opts.Mode == users.RegistrationClosed {
+		return ErrRegistrationClosed
+	}
+	code = strings.TrimSpace(code)
+	if code == "" && s.opts.Mode == users.RegistrationInvite {
+		return ErrInviteRequired
+	}
+
+	username = strings.TrimSpace(username)
+	invalid := &users.ValidationError{Fields: map[string]string{}}
+	if err := validate.Username(username); err != nil {
+		invalid.Fields["username"] = err.Error()
+	}
+	if err := validate.Password(password, username); err != nil {
+		invalid.Fields["password"] = err.Error()
+	}
+	if len(invalid.Fields) > 0 {
+		return invalid
+	}
+
+	hashedPass, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	user := &users.User{
+		Username: username,
+		Role:     s.opts.DefaultRole,
+	}
+	if code == "" {
+		err = s.repo.Register(ctx, user, hashedPass)
+	} else {
+		err = s.inviteRepo.RegisterWithInvite(ctx, user, hashedPass, users.HashInviteCode(code), s.now())
+	}
+	if errors.Is(err, users.ErrUsernameTaken) {
+		return &users.ValidationError{Fields: map[string]string{"username": err.Error()}}
+	}
+	return err
+}
+
+// CheckInvite returns the invite for code if someone could register with it right now.
+func (s *RegistrationService) CheckInvite(ctx context.Context, code string) (*users.Invite, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, ErrInviteRequired
+	}
+
+	invite, err := s.inviteRepo.GetInviteByCode(ctx, users.HashInviteCode(code))
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			return nil, users.ErrInvalidInvite
+		}
+		return nil, err
+	}
+	if !invite.Usable(s.now()) {
+		return nil, users.ErrInvalidInvite
+	}
+	return invite, nil
+}
+
+// CreateInvite makes an invite and returns its code, which is only stored hashed. A validFor of 0 never
+// expires. Users who cannot manage users always invite with the default role and within the configured limits.
+func (s *RegistrationService) CreateInvite(ctx context.Context, userID int, role string, maxUses int, validFor time.Duration) (string, error) {
+	user, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if role == "" {
+		role = s.opts.DefaultRole
+	}
+	if maxUses < 1 {
+		return "", ErrInvalidUses
+	}
+	if validFor < 0 {
+		return "", ErrInvalidExpiry
+	}
+	if !user.Can(enum.PermUserManage) {
+		if role != s.opts.DefaultRole {
+			return "", ErrInvalidRole
+		}
+		if maxUses > s.opts.MaxUses {
+			return "", ErrInvalidUses
+		}
+		if validFor == 0 || validFor > s.opts.MaxTTL {
+			return "", ErrInvalidExpiry
+		}
+	}
+
+	code, err := users.GenerateInviteCode()
+	if err != nil {
+		return "", err
+	}
+
+	invite := &users.Invite{Role: role, MaxUses: maxUses, CreatedBy: userID}
+	if validFor > 0 {
+		expiresAt := s.now().Add(validFor)
+		invite.ExpiresAt = &expiresAt
+	}
+	if _, err := s.inviteRepo.AddInvite(ctx, invite, users.HashInviteCode(code)); err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			return "", ErrInvalidRole
+		}
+		return "", err
+	}
+	return code, nil
+}
+
+func (s *RegistrationService) ListInvites(ctx context.Context) ([]users.Invite, error) {
+	return s.inviteRepo.ListInvites(ctx)
+}
+
+func (s *RegistrationService) ListUserInvites(ctx context.Context, userID int) ([]users.Invite, error) {
+	return s.inviteRepo.ListUserInvites(ctx, userID)
+}
+
+// RevokeInvite stops an invite from being used again. Users can revoke their own invites, admins anyone's.
+func (s *RegistrationService) RevokeInvite(ctx context.Context, actorID int, inviteID int) error {
+	actor, err := s.repo.GetByUserID(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	invite, err := s.inviteRepo.GetInvite(ctx, inviteID)
+	if err != nil {
+		return err
+	}
+	if invite.CreatedBy != actorID && !actor.Can(enum.PermUserManage) {
+		return myErrors.ErrNotFound
+	}
+	return s.inviteRepo.RevokeInvite(ctx, inviteID, s.now())
+}
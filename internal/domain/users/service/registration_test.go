@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"goserv/internal/domain/users"
+	"goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/validate"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRegistrationOptions = RegistrationOptions{
+	Mode:        users.RegistrationOpen,
+	DefaultRole: users.RoleUploader,
+	MaxUses:     5,
+	MaxTTL:      30 * 24 * time.Hour,
+}
+
+func TestRegistrationService_Register(t *testing.T) {
+	type args struct {
+		mode     string
+		code     string
+		username string
+		password string
+	}
+	type want struct {
+		registered string
+		err        error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "open registration",
+			args: args{mode: users.RegistrationOpen},
+			want: want{registered: "open", err: nil},
+		},
+		{
+			name: "open registration with an invite",
+			args: args{mode: users.RegistrationOpen, code: "good"},
+			want: want{registered: "invite", err: nil},
+		},
+		{
+			name: "invite-only without a code",
+			args: args{mode: users.RegistrationInvite},
+			want: want{err: ErrInviteRequired},
+		},
+		{
+			name: "invite-only with a code",
+			args: args{mode: users.RegistrationInvite, code: " good "},
+			want: want{registered: "invite", err: nil},
+		},
+		{
+			name: "invite-only with a used up code",
+			args: args{mode: users.RegistrationInvite, code: "used"},
+			want: want{err: users.ErrInvalidInvite},
+		},
+		{
+			name: "closed",
+			args: args{mode: users.RegistrationClosed, code: "good"},
+			want: want{err: ErrRegistrationClosed},
+		},
+		{
+			name: "bad username and breached password",
+			args: args{mode: users.RegistrationOpen, username: "al ice", password: "Password123"},
+			want: want{err: &users.ValidationError{Fields: map[string]string{
+				"username": validate.ErrUsernameChars.Error(),
+				"password": validate.ErrPasswordBreached.Error(),
+			}}},
+		},
+		{
+			name: "reserved username",
+			args: args{mode: users.RegistrationOpen, username: "Admin"},
+			want: want{err: &users.ValidationError{Fields: map[string]string{"username": validate.ErrUsernameReserved.Error()}}},
+		},
+		{
+			name: "short password",
+			args: args{mode: users.RegistrationOpen, password: "short"},
+			want: want{err: &users.ValidationError{Fields: map[string]string{"password": validate.ErrPasswordLength.Error()}}},
+		},
+		{
+			name: "username taken in another case",
+			args: args{mode: users.RegistrationOpen, username: "Taken"},
+			want: want{err: &users.ValidationError{Fields: map[string]string{"username": users.ErrUsernameTaken.Error()}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registered := ""
+			userRepo := &repository.UserMock{
+				RegisterFunc: func(ctx context.Context, user *users.User, passHash string) error {
+					assert.Equal(t, users.RoleUploader, user.Role)
+					if strings.EqualFold(user.Username, "taken") {
+						return users.ErrUsernameTaken
+					}
+					registered = "open"
+					return nil
+				},
+			}
+			inviteRepo := &repository.InviteMock{
+				RegisterWithInviteFunc: func(ctx context.Context, user *users.User, passHash string, codeHash string, now time.Time) error {
+					if codeHash != users.HashInviteCode("good") {
+						return users.ErrInvalidInvite
+					}
+					registered = "invite"
+					return nil
+				},
+			}
+
+			opts := testRegistrationOptions
+			opts.Mode = test.args.mode
+			service := NewRegistrationService(userRepo, inviteRepo, testHasher, opts)
+
+			username, password := "username", "correct horse"
+			if test.args.username != "" {
+				username = test.args.username
+			}
+			if test.args.password != "" {
+				password = test.args.password
+			}
+
+			err := service.Register(context.Background(), username, password, test.args.code)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.registered, registered)
+		})
+	}
+}
+
+func TestRegistrationService_CheckInvite(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Minute)
+
+	invites := map[string]*users.Invite{
+		users.HashInviteCode("ABCD-EFGH"): {ID: 1, Role: users.RoleViewer, MaxUses: 2, Uses: 1},
+		users.HashInviteCode("USED-UP"):   {ID: 2, MaxUses: 1, Uses: 1},
+		users.HashInviteCode("EXPIRED"):   {ID: 3, MaxUses: 1, ExpiresAt: &expired},
+		users.HashInviteCode("REVOKED"):   {ID: 4, MaxUses: 1, RevokedAt: &expired},
+	}
+
+	type want struct {
+		inviteID int
+		err      error
+	}
+	type test struct {
+		name string
+		code string
+		want want
+	}
+
+	tests := []test{
+		{name: "usable, typed in lower case", code: "abcdefgh", want: want{inviteID: 1, err: nil}},
+		{name: "missing", code: "", want: want{err: ErrInviteRequired}},
+		{name: "unknown", code: "NOPE", want: want{err: users.ErrInvalidInvite}},
+		{name: "used up", code: "USED-UP", want: want{err: users.ErrInvalidInvite}},
+		{name: "expired", code: "EXPIRED", want: want{err: users.ErrInvalidInvite}},
+		{name: "revoked", code: "REVOKED", want: want{err: users.ErrInvalidInvite}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inviteRepo := &repository.InviteMock{
+				GetInviteByCodeFunc: func(ctx context.Context, codeHash string) (*users.Invite, error) {
+					if invite, ok := invites[codeHash]; ok {
+						return invite, nil
+					}
+					return nil, myErrors.ErrNotFound
+				},
+			}
+
+			service := NewRegistrationService(&repository.UserMock{}, inviteRepo, testHasher, testRegistrationOptions)
+			service.now = func() time.Time { return now }
+
+			invite, err := service.CheckInvite(context.Background(), test.code)
+			assert.Equal(t, test.want.err, err)
+			if test.want.err == nil {
+				assert.Equal(t, test.want.inviteID, invite.ID)
+			}
+		})
+	}
+}
+
+func TestRegistrationService_CreateInvite(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type args struct {
+		userID   int
+		role     string
+		maxUses  int
+		validFor time.Duration
+	}
+	type want struct {
+		role      string
+		expiresAt *time.Time
+		err       error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	week := now.Add(7 * 24 * time.Hour)
+	tests := []test{
+		{
+			name: "user invite",
+			args: args{userID: 1, maxUses: 2, validFor: 7 * 24 * time.Hour},
+			want: want{role: users.RoleUploader, expiresAt: &week, err: nil},
+		},
+		{
+			name: "user picking a role",
+			args: args{userID: 1, role: users.RoleAdmin, maxUses: 1, validFor: time.Hour},
+			want: want{err: ErrInvalidRole},
+		},
+		{
+			name: "user invite used too often",
+			args: args{userID: 1, maxUses: 6, validFor: time.Hour},
+			want: want{err: ErrInvalidUses},
+		},
+		{
+			name: "user invite that never expires",
+			args: args{userID: 1, maxUses: 1, validFor: 0},
+			want: want{err: ErrInvalidExpiry},
+		},
+		{
+			name: "no uses",
+			args: args{userID: 2, maxUses: 0, validFor: time.Hour},
+			want: want{err: ErrInvalidUses},
+		},
+		{
+			name: "admin invite",
+			args: args{userID: 2, role: users.RoleModerator, maxUses: 50, validFor: 0},
+			want: want{role: users.RoleModerator, expiresAt: nil, err: nil},
+		},
+		{
+			name: "admin invite with unknown role",
+			args: args{userID: 2, role: "owner", maxUses: 1, validFor: 0},
+			want: want{err: ErrInvalidRole},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepo := &repository.UserMock{
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					if userID == 2 {
+						return &users.User{ID: 2, Permissions: []enum.Permission{enum.PermInviteCreate, enum.PermUserManage}}, nil
+					}
+					return &users.User{ID: userID, Permissions: []enum.Permission{enum.PermInviteCreate}}, nil
+				},
+			}
+			var added *users.Invite
+			inviteRepo := &repository.InviteMock{
+				AddInviteFunc: func(ctx context.Context, invite *users.Invite, codeHash string) (int, error) {
+					if invite.Role == "owner" {
+						return 0, myErrors.ErrNotFound
+					}
+					added = invite
+					return 1, nil
+				},
+			}
+
+			service := NewRegistrationService(userRepo, inviteRepo, testHasher, testRegistrationOptions)
+			service.now = func() time.Time { return now }
+
+			code, err := service.CreateInvite(context.Background(), test.args.userID, test.args.role, test.args.maxUses, test.args.validFor)
+			if test.want.err != nil {
+				assert.ErrorIs(t, err, test.want.err)
+				assert.Empty(t, code)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, code, len("XXXX-XXXX-XXXX-XXXX"))
+			assert.Equal(t, test.want.role, added.Role)
+			assert.Equal(t, test.args.userID, added.CreatedBy)
+			assert.Equal(t, test.want.expiresAt, added.ExpiresAt)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	sRepo "goserv/internal/domain/sessions/repository"
+	"goserv/internal/domain/users/repository"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/pkg/mailer"
+	"goserv/pkg/password"
+	"log"
+	"net/url"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("this reset link is invalid or has expired")
+
+// resendAfter stops the forgot password form from being used to flood someone's inbox.
+const resendAfter = time.Minute
+
+type ResetOptions struct {
+	// TTL is how long a reset link stays valid
+	TTL time.Duration
+	// BaseURL is where the site is reached from, used to build the emailed link
+	BaseURL string
+}
+
+type PasswordResetService struct {
+	repo        repository.User
+	resetRepo   repository.PasswordReset
+	sessionRepo sRepo.Session
+	mailer      mailer.Mailer
+	hasher      *password.Hasher
+	opts        ResetOptions
+	now         func() time.Time
+}
+
+func NewPasswordResetService(
+	repo repository.User,
+	resetRepo repository.PasswordReset,
+	sessionRepo sRepo.Session,
+	mailer mailer.Mailer,
+	hasher *password.Hasher,
+	opts ResetOptions,
+) *PasswordResetService {
+	return &PasswordResetService{
+		repo:        repo,
+		resetRepo:   resetRepo,
+		sessionRepo: sessionRepo,
+		mailer:      mailer,
+		hasher:      hasher,
+		opts:        opts,
+		now:         time.Now,
+	}
+}
+
+// RequestReset emails a reset link when email belongs to an active account. Unknown addresses are not
+// an error, so the form does not tell anyone which addresses have accounts.
+func (s *PasswordResetService) RequestReset(ctx context.Context, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil || email == "" {
+		return ErrInvalidEmail
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Disabled {
+		return nil
+	}
+
+	now := s.now()
+	latest, err := s.resetRepo.LatestReset(ctx, user.ID)
+	if err != nil && !errors.Is(err, myErrors.ErrNotFound) {
+		return err
+	}
+	if err == nil && now.Sub(latest) < resendAfter {
+		log.Printf("Skipped password reset for user %d, one was sent %s ago\n", user.ID, now.Sub(latest).Round(time.Second))
+		return nil
+	}
+
+	token := generateResetToken()
+	if err := s.resetRepo.AddReset(ctx, user.ID, hashResetToken(token), now.Add(s.opts.TTL)); err != nil {
+		return err
+	}
+
+	link := s.opts.BaseURL + "/password/reset?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for %s.\n\n"+
+			"Follow this link within %s to choose a new one:\n%s\n\n"+
+			"If it wasn't you, ignore this email and your password stays the same.\n",
+			user.Username, s.opts.TTL, link),
+	})
+}
+
+// ResetPassword sets a new password with a reset token. The token and any others for the user stop
+// working, and every session the user has is logged out.
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token string, password string) error {
+	tokenHash := hashResetToken(token)
+	userID, err := s.resetRepo.ResetUser(ctx, tokenHash, s.now())
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// the password is checked before the token is used up, so a rejected one can be retried with the same link
+	user, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkNewPassword(password, user.Username); err != nil {
+		return err
+	}
+
+	userID, err = s.resetRepo.UseReset(ctx, tokenHash, s.now())
+	if err != nil {
+		if errors.Is(err, myErrors.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	hashedPass, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, hashedPass); err != nil {
+		return err
+	}
+	log.Printf("Password reset for user %d\n", userID)
+
+	if err := s.resetRepo.DeleteUserResets(ctx, userID); err != nil {
+		return err
+	}
+	_, err = s.sessionRepo.DeleteUserSessions(ctx, userID, "")
+	return err
+}
+
+func generateResetToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
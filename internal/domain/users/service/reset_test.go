@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	sRepo "goserv/internal/domain/sessions/repository"
+	"goserv/internal/domain/users"
+	"goserv/internal/domain/users/repository"
+	myErrors "goserv/internal/utils/errors"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/mailer"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMailer struct {
+	sent []mailer.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// passwordError is what a new password breaking the policy with err comes back as.
+func passwordError(err error) error {
+	return &users.ValidationError{Fields: map[string]string{"password": err.Error()}}
+}
+
+var testResetOptions = ResetOptions{TTL: time.Hour, BaseURL: "https://example.com"}
+
+func TestPasswordResetService_RequestReset(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type args struct {
+		email string
+	}
+	type want struct {
+		sent bool
+		err  error
+	}
+	type test struct {
+		name   string
+		args   args
+		latest time.Time
+		want   want
+	}
+
+	tests := []test{
+		{name: "known email", args: args{email: " Alice@Example.com "}, want: want{sent: true, err: nil}},
+		{name: "unknown email", args: args{email: "bob@example.com"}, want: want{sent: false, err: nil}},
+		{name: "sent a moment ago", args: args{email: "alice@example.com"}, latest: now.Add(-10 * time.Second), want: want{sent: false, err: nil}},
+		{name: "sent a while ago", args: args{email: "alice@example.com"}, latest: now.Add(-time.Hour), want: want{sent: true, err: nil}},
+		{name: "not an email", args: args{email: "alice"}, want: want{sent: false, err: ErrInvalidEmail}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var storedHash string
+			userRepo := &repository.UserMock{
+				GetByEmailFunc: func(ctx context.Context, email string) (*users.User, error) {
+					if email == "alice@example.com" {
+						return &users.User{ID: 1, Username: "alice", Email: email}, nil
+					}
+					return nil, myErrors.ErrNotFound
+				},
+			}
+			resetRepo := &repository.PasswordResetMock{
+				LatestResetFunc: func(ctx context.Context, userID int) (time.Time, error) {
+					if test.latest.IsZero() {
+						return time.Time{}, myErrors.ErrNotFound
+					}
+					return test.latest, nil
+				},
+				AddResetFunc: func(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+					assert.Equal(t, now.Add(time.Hour), expiresAt)
+					storedHash = tokenHash
+					return nil
+				},
+			}
+			mail := &fakeMailer{}
+
+			service := NewPasswordResetService(userRepo, resetRepo, nil, mail, testHasher, testResetOptions)
+			service.now = func() time.Time { return now }
+
+			err := service.RequestReset(context.Background(), test.args.email)
+			assert.Equal(t, test.want.err, err)
+			if !test.want.sent {
+				assert.Empty(t, mail.sent)
+				return
+			}
+
+			assert.Len(t, mail.sent, 1)
+			assert.Equal(t, "alice@example.com", mail.sent[0].To)
+			link := regexp.MustCompile(`https://example\.com/password/reset\?token=\S+`).FindString(mail.sent[0].Body)
+			assert.NotEmpty(t, link)
+			parsed, err := url.Parse(link)
+			assert.NoError(t, err)
+			token := parsed.Query().Get("token")
+			assert.Equal(t, hashResetToken(token), storedHash)
+			assert.NotEqual(t, token, storedHash)
+		})
+	}
+}
+
+func TestPasswordResetService_ResetPassword(t *testing.T) {
+	type args struct {
+		token    string
+		password string
+	}
+	type want struct {
+		updated bool
+		revoked bool
+		err     error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{name: "valid token", args: args{token: "good", password: "new horse staple"}, want: want{updated: true, revoked: true, err: nil}},
+		{name: "used or expired token", args: args{token: "bad", password: "new horse staple"}, want: want{updated: false, revoked: false, err: ErrInvalidResetToken}},
+		{name: "empty password", args: args{token: "good", password: ""}, want: want{updated: false, revoked: false, err: passwordError(validate.ErrPasswordLength)}},
+		{name: "breached password", args: args{token: "good", password: "password1"}, want: want{updated: false, revoked: false, err: passwordError(validate.ErrPasswordBreached)}},
+		{name: "username as password", args: args{token: "good", password: "AliceInChains"}, want: want{updated: false, revoked: false, err: passwordError(validate.ErrPasswordUsername)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			updated, revoked, cleared, used := false, false, false, false
+			userRepo := &repository.UserMock{
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					return &users.User{ID: userID, Username: "aliceinchains"}, nil
+				},
+				UpdatePasswordFunc: func(ctx context.Context, userID int, passHash string) error {
+					assert.Equal(t, 1, userID)
+					assert.NotEqual(t, test.args.password, passHash)
+					updated = true
+					return nil
+				},
+			}
+			resetRepo := &repository.PasswordResetMock{
+				ResetUserFunc: func(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+					if tokenHash == hashResetToken("good") {
+						return 1, nil
+					}
+					return 0, myErrors.ErrNotFound
+				},
+				UseResetFunc: func(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+					used = true
+					return 1, nil
+				},
+				DeleteUserResetsFunc: func(ctx context.Context, userID int) error {
+					cleared = true
+					return nil
+				},
+			}
+			sessionRepo := &sRepo.SessionMock{
+				DeleteUserSessionsFunc: func(ctx context.Context, userID int, exceptID string) (int, error) {
+					assert.Equal(t, 1, userID)
+					assert.Equal(t, "", exceptID)
+					revoked = true
+					return 3, nil
+				},
+			}
+
+			service := NewPasswordResetService(userRepo, resetRepo, sessionRepo, &fakeMailer{}, testHasher, testResetOptions)
+
+			err := service.ResetPassword(context.Background(), test.args.token, test.args.password)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.updated, updated)
+			assert.Equal(t, test.want.updated, cleared)
+			assert.Equal(t, test.want.updated, used)
+			assert.Equal(t, test.want.revoked, revoked)
+		})
+	}
+}
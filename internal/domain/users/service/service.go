@@ -2,39 +2,325 @@ package service
 
 import (
 	"context"
+	"errors"
+	sRepo "goserv/internal/domain/sessions/repository"
 	"goserv/internal/domain/users"
 	"goserv/internal/domain/users/repository"
-
-	"golang.org/x/crypto/bcrypt"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/password"
+	"goserv/pkg/totp"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
 )
 
+var ErrWrongPassword = errors.New("current password is incorrect")
+var ErrOwnAccount = errors.New("admins cannot change their own account from the admin console")
+var ErrTOTPEnabled = errors.New("two-factor login is already enabled")
+var ErrTOTPNotEnabled = errors.New("two-factor login is not enabled")
+var ErrTOTPRequired = errors.New("an admin requires two-factor login for this account")
+var ErrWrongCode = errors.New("code does not match the authenticator")
+var ErrInvalidEmail = errors.New("invalid email address")
+var ErrLastAdmin = errors.New("the only admin left cannot delete their account")
+
+// totpSkew accepts codes from one time step either side of the server clock.
+const totpSkew = 1
+
 type UserService struct {
-	repo repository.User
+	repo        repository.User
+	sessionRepo sRepo.Session
+	hasher      *password.Hasher
+	now         func() time.Time
 }
 
-func NewUserService(repo repository.User) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo repository.User, sessionRepo sRepo.Session, hasher *password.Hasher) *UserService {
+	return &UserService{repo: repo, sessionRepo: sessionRepo, hasher: hasher, now: time.Now}
 }
 
 func (s *UserService) GetByUsername(ctx context.Context, username string) (*users.User, error) {
 	return s.repo.GetByUsername(ctx, username)
 }
 
+func (s *UserService) GetByUserID(ctx context.Context, userID int) (*users.User, error) {
+	return s.repo.GetByUserID(ctx, userID)
+}
+
 func (s *UserService) CheckPassword(ctx context.Context, username string, password string) (*users.User, bool, error) {
 	return s.repo.CheckPassword(ctx, username, password)
 }
 
-func (s *UserService) Register(ctx context.Context, username string, password string) error {
-	// TODO: test if user exists before trying to make new one
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (s *UserService) HasPermission(ctx context.Context, userID int, perm enum.Permission) (bool, error) {
+	perms, err := s.repo.GetPermissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(perms, perm), nil
+}
+
+// ChangePassword replaces the user's password and logs out every session, including the current one.
+func (s *UserService) ChangePassword(ctx context.Context, userID int, current string, password string) error {
+	user, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkNewPassword(password, user.Username); err != nil {
+		return err
+	}
+
+	_, isMatch, err := s.repo.CheckPassword(ctx, user.Username, current)
+	if err != nil {
+		return err
+	}
+	if !isMatch {
+		return ErrWrongPassword
+	}
+
+	hashedPass, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, userID, hashedPass); err != nil {
+		return err
+	}
+
+	_, err = s.sessionRepo.DeleteUserSessions(ctx, userID, "")
+	return err
+}
+
+// SetEmail changes the address password reset links go to after checking the password.
+// An empty email removes the address.
+func (s *UserService) SetEmail(ctx context.Context, userID int, email string, password string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if _, err := s.checkOwnPassword(ctx, userID, password); err != nil {
+		return err
+	}
+	return s.repo.SetEmail(ctx, userID, email)
+}
+
+func (s *UserService) ListUsers(ctx context.Context) ([]users.User, error) {
+	return s.repo.ListUsers(ctx)
+}
+
+// SetRole moves a user to another role. Admins cannot change their own role so the instance always keeps one.
+func (s *UserService) SetRole(ctx context.Context, actorID int, userID int, role string) error {
+	if actorID == userID {
+		return ErrOwnAccount
+	}
+	return s.repo.SetRole(ctx, userID, role)
+}
+
+func (s *UserService) ListRoles(ctx context.Context) ([]users.Role, error) {
+	return s.repo.ListRoles(ctx)
+}
+
+// SetDisabled blocks or unblocks a user's logins. Disabling also ends every session the user has open.
+func (s *UserService) SetDisabled(ctx context.Context, actorID int, userID int, disabled bool) error {
+	if actorID == userID {
+		return ErrOwnAccount
+	}
+	if err := s.repo.SetDisabled(ctx, userID, disabled); err != nil {
+		return err
+	}
+	if !disabled {
+		return nil
+	}
+
+	_, err := s.sessionRepo.DeleteUserSessions(ctx, userID, "")
+	return err
+}
+
+func (s *UserService) DeleteUser(ctx context.Context, actorID int, userID int) error {
+	if actorID == userID {
+		return ErrOwnAccount
+	}
+	return s.repo.DeleteUser(ctx, userID)
+}
+
+// DeleteAccount lets a user remove their own account after confirming their password. The last
+// active user manager is kept so the instance is never left without one. Their posts stay behind without an owner.
+func (s *UserService) DeleteAccount(ctx context.Context, userID int, password string) error {
+	user, err := s.checkOwnPassword(ctx, userID, password)
 	if err != nil {
 		return err
 	}
 
-	user := &users.User{
-		Username: username,
-		IsAdmin:  false,
+	if user.Can(enum.PermUserManage) {
+		all, err := s.repo.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		others := slices.ContainsFunc(all, func(other users.User) bool {
+			return other.ID != userID && !other.Disabled && other.Can(enum.PermUserManage)
+		})
+		if !others {
+			return ErrLastAdmin
+		}
 	}
+	return s.repo.DeleteUser(ctx, userID)
+}
 
-	return s.repo.Register(ctx, user, string(hashedPass))
+// BeginTOTP returns the secret for a new authenticator, reusing one that is still waiting for confirmation.
+func (s *UserService) BeginTOTP(ctx context.Context, userID int) (string, error) {
+	current, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if current.Enabled {
+		return "", ErrTOTPEnabled
+	}
+	if current.Secret != "" {
+		return current.Secret, nil
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// EnableTOTP confirms the authenticator from BeginTOTP with its current code and returns a fresh set of
+// recovery codes. Only their hashes are stored, so this is the one time they can be shown.
+func (s *UserService) EnableTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	current, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	step, ok := totp.Validate(current.Secret, code, s.now(), totpSkew)
+	if !ok {
+		return nil, ErrWrongCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor login off after checking the password, unless an admin requires it.
+func (s *UserService) DisableTOTP(ctx context.Context, userID int, password string) error {
+	user, err := s.checkOwnPassword(ctx, userID, password)
+	if err != nil {
+		return err
+	}
+	if user.TOTPRequired {
+		return ErrTOTPRequired
+	}
+	return s.repo.DisableTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not, after checking the password.
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, userID int, password string) ([]string, error) {
+	user, err := s.checkOwnPassword(ctx, userID, password)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *UserService) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	return s.repo.CountRecoveryCodes(ctx, userID)
+}
+
+// SetTOTPRequired makes a user set up two-factor login. Users without an authenticator yet are logged out
+// so they have to enroll on their next login.
+func (s *UserService) SetTOTPRequired(ctx context.Context, actorID int, userID int, required bool) error {
+	if actorID == userID {
+		return ErrOwnAccount
+	}
+	if err := s.repo.SetTOTPRequired(ctx, userID, required); err != nil {
+		return err
+	}
+	if !required {
+		return nil
+	}
+
+	user, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return nil
+	}
+	_, err = s.sessionRepo.DeleteUserSessions(ctx, userID, "")
+	return err
+}
+
+func (s *UserService) checkOwnPassword(ctx context.Context, userID int, password string) (*users.User, error) {
+	user, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, isMatch, err := s.repo.CheckPassword(ctx, user.Username, password)
+	if err != nil {
+		return nil, err
+	}
+	if !isMatch {
+		return nil, ErrWrongPassword
+	}
+	return user, nil
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := users.GenerateRecoveryCodes(users.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i := range codes {
+		hashes[i] = users.HashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// checkNewPassword applies the password policy, reporting a failure against the form's password field.
+func checkNewPassword(password string, username string) error {
+	if err := validate.Password(password, username); err != nil {
+		return &users.ValidationError{Fields: map[string]string{"password": err.Error()}}
+	}
+	return nil
+}
+
+// normalizeEmail accepts a bare address such as "alice@example.com" and lower cases it.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(email), nil
 }
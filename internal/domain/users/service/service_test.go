@@ -3,13 +3,28 @@ package service
 import (
 	"context"
 	"errors"
+	sRepo "goserv/internal/domain/sessions/repository"
 	"goserv/internal/domain/users"
 	"goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
+	"goserv/internal/utils/validate"
+	"goserv/pkg/password"
+	"goserv/pkg/totp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// testHasher keeps the tests quick, real settings come from the config
+var testHasher = password.NewHasher(password.Argon2id{Params: password.Argon2idParams{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}})
+
 func TestUserService_GetByUsername(t *testing.T) {
 	type args struct {
 		name string
@@ -34,7 +49,7 @@ func TestUserService_GetByUsername(t *testing.T) {
 				user: &users.User{
 					ID:       1,
 					Username: "username",
-					IsAdmin:  false,
+					Role:     users.RoleUploader,
 				},
 				err: nil,
 			},
@@ -59,7 +74,7 @@ func TestUserService_GetByUsername(t *testing.T) {
 				},
 			}
 
-			service := NewUserService(userRepo)
+			service := NewUserService(userRepo, nil, testHasher)
 
 			user, err := service.GetByUsername(context.Background(), test.args.name)
 			assert.Equal(t, test.want.err, err)
@@ -97,7 +112,7 @@ func TestUserService_CheckPassword(t *testing.T) {
 				user: &users.User{
 					ID:       1,
 					Username: "username",
-					IsAdmin:  false,
+					Role:     users.RoleUploader,
 				},
 				isMatch: true,
 				err:     nil,
@@ -131,7 +146,7 @@ func TestUserService_CheckPassword(t *testing.T) {
 				},
 			}
 
-			service := NewUserService(userRepo)
+			service := NewUserService(userRepo, nil, testHasher)
 
 			user, isMatch, err := service.CheckPassword(context.Background(), test.args.name, test.args.password)
 			assert.Equal(t, test.want.err, err)
@@ -141,13 +156,70 @@ func TestUserService_CheckPassword(t *testing.T) {
 	}
 }
 
-func TestUserService_Register(t *testing.T) {
+func TestUserService_HasPermission(t *testing.T) {
+	type args struct {
+		userID int
+		perm   enum.Permission
+	}
+	type want struct {
+		allowed bool
+		err     error
+	}
+	type test struct {
+		name  string
+		args  args
+		perms []enum.Permission
+		want  want
+	}
+
+	tests := []test{
+		{
+			name:  "role grants permission",
+			args:  args{userID: 1, perm: enum.PermPostCreate},
+			perms: []enum.Permission{enum.PermPostCreate},
+			want:  want{allowed: true, err: nil},
+		},
+		{
+			name:  "viewer cannot upload",
+			args:  args{userID: 2, perm: enum.PermPostCreate},
+			perms: []enum.Permission{},
+			want:  want{allowed: false, err: nil},
+		},
+		{
+			name:  "error reading permissions",
+			args:  args{userID: 3, perm: enum.PermUserManage},
+			perms: nil,
+			want:  want{allowed: false, err: errors.New("test error")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepo := &repository.UserMock{
+				GetPermissionsFunc: func(ctx context.Context, userID int) ([]enum.Permission, error) {
+					assert.Equal(t, test.args.userID, userID)
+					return test.perms, test.want.err
+				},
+			}
+
+			service := NewUserService(userRepo, nil, testHasher)
+
+			allowed, err := service.HasPermission(context.Background(), test.args.userID, test.args.perm)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.allowed, allowed)
+		})
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
 	type args struct {
-		username string
+		current  string
 		password string
 	}
 	type want struct {
-		err error
+		isMatch bool
+		revoked bool
+		err     error
 	}
 	type test struct {
 		name string
@@ -155,40 +227,355 @@ func TestUserService_Register(t *testing.T) {
 		want want
 	}
 
-	basicArgs := args{
-		username: "username",
-		password: "password",
+	tests := []test{
+		{
+			name: "password changed",
+			args: args{current: "old", password: "new horse staple"},
+			want: want{isMatch: true, revoked: true, err: nil},
+		},
+		{
+			name: "wrong current password",
+			args: args{current: "wrong", password: "new horse staple"},
+			want: want{isMatch: false, revoked: false, err: ErrWrongPassword},
+		},
+		{
+			name: "empty new password",
+			args: args{current: "old", password: ""},
+			want: want{isMatch: true, revoked: false, err: passwordError(validate.ErrPasswordLength)},
+		},
+		{
+			name: "username as new password",
+			args: args{current: "old", password: "Username"},
+			want: want{isMatch: true, revoked: false, err: passwordError(validate.ErrPasswordUsername)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revoked := false
+			userRepo := &repository.UserMock{
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					return &users.User{ID: userID, Username: "username"}, nil
+				},
+				CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+					return &users.User{ID: 1, Username: username}, test.want.isMatch, nil
+				},
+				UpdatePasswordFunc: func(ctx context.Context, userID int, passHash string) error {
+					assert.NotEqual(t, test.args.password, passHash)
+					return nil
+				},
+			}
+			sessionRepo := &sRepo.SessionMock{
+				DeleteUserSessionsFunc: func(ctx context.Context, userID int, exceptID string) (int, error) {
+					assert.Equal(t, "", exceptID)
+					revoked = true
+					return 2, nil
+				},
+			}
+
+			service := NewUserService(userRepo, sessionRepo, testHasher)
+
+			err := service.ChangePassword(context.Background(), 1, test.args.current, test.args.password)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.revoked, revoked)
+		})
+	}
+}
+
+func TestUserService_SetDisabled(t *testing.T) {
+	type args struct {
+		actorID  int
+		userID   int
+		disabled bool
+	}
+	type want struct {
+		updated bool
+		revoked bool
+		err     error
+	}
+	type test struct {
+		name string
+		args args
+		want want
 	}
 
 	tests := []test{
 		{
-			name: "simple register",
-			args: basicArgs,
-			want: want{
-				err: nil,
-			},
+			name: "disable user",
+			args: args{actorID: 1, userID: 2, disabled: true},
+			want: want{updated: true, revoked: true, err: nil},
 		},
 		{
-			name: "error registering",
-			args: basicArgs,
-			want: want{
-				err: errors.New("test error"),
-			},
+			name: "enable user",
+			args: args{actorID: 1, userID: 2, disabled: false},
+			want: want{updated: true, revoked: false, err: nil},
+		},
+		{
+			name: "disable yourself",
+			args: args{actorID: 1, userID: 1, disabled: true},
+			want: want{updated: false, revoked: false, err: ErrOwnAccount},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
+			updated, revoked := false, false
 			userRepo := &repository.UserMock{
-				RegisterFunc: func(ctx context.Context, user *users.User, passHash string) error {
-					return test.want.err
+				SetDisabledFunc: func(ctx context.Context, userID int, disabled bool) error {
+					assert.Equal(t, test.args.userID, userID)
+					assert.Equal(t, test.args.disabled, disabled)
+					updated = true
+					return nil
+				},
+			}
+			sessionRepo := &sRepo.SessionMock{
+				DeleteUserSessionsFunc: func(ctx context.Context, userID int, exceptID string) (int, error) {
+					assert.Equal(t, test.args.userID, userID)
+					revoked = true
+					return 1, nil
+				},
+			}
+
+			service := NewUserService(userRepo, sessionRepo, testHasher)
+
+			err := service.SetDisabled(context.Background(), test.args.actorID, test.args.userID, test.args.disabled)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.updated, updated)
+			assert.Equal(t, test.want.revoked, revoked)
+		})
+	}
+}
+
+func TestUserService_SetRole(t *testing.T) {
+	userRepo := &repository.UserMock{
+		SetRoleFunc: func(ctx context.Context, userID int, role string) error {
+			assert.Equal(t, 2, userID)
+			assert.Equal(t, users.RoleModerator, role)
+			return nil
+		},
+	}
+	service := NewUserService(userRepo, nil, testHasher)
+
+	assert.NoError(t, service.SetRole(context.Background(), 1, 2, users.RoleModerator))
+	assert.Equal(t, ErrOwnAccount, service.SetRole(context.Background(), 1, 1, users.RoleViewer))
+}
+
+func TestUserService_EnableTOTP(t *testing.T) {
+	// secret is the RFC 6238 test key, whose code at unix time 1111111111 is 050471.
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+
+	type args struct {
+		code string
+	}
+	type want struct {
+		codes int
+		err   error
+	}
+	type test struct {
+		name    string
+		args    args
+		current users.TOTP
+		want    want
+	}
+
+	tests := []test{
+		{
+			name:    "confirm authenticator",
+			args:    args{code: "050471"},
+			current: users.TOTP{Secret: secret},
+			want:    want{codes: users.RecoveryCodeCount, err: nil},
+		},
+		{
+			name:    "wrong code",
+			args:    args{code: "123456"},
+			current: users.TOTP{Secret: secret},
+			want:    want{codes: 0, err: ErrWrongCode},
+		},
+		{
+			name:    "already enabled",
+			args:    args{code: "050471"},
+			current: users.TOTP{Secret: secret, Enabled: true},
+			want:    want{codes: 0, err: ErrTOTPEnabled},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stored []string
+			userRepo := &repository.UserMock{
+				GetTOTPFunc: func(ctx context.Context, userID int) (*users.TOTP, error) {
+					return &test.current, nil
+				},
+				EnableTOTPFunc: func(ctx context.Context, userID int, step int64, codeHashes []string) error {
+					assert.Equal(t, totp.Step(now), step)
+					stored = codeHashes
+					return nil
+				},
+			}
+
+			service := NewUserService(userRepo, nil, testHasher)
+			service.now = func() time.Time { return now }
+
+			codes, err := service.EnableTOTP(context.Background(), 1, test.args.code)
+			assert.Equal(t, test.want.err, err)
+			assert.Len(t, codes, test.want.codes)
+			for i := range codes {
+				assert.Equal(t, users.HashRecoveryCode(codes[i]), stored[i])
+			}
+		})
+	}
+}
+
+func TestUserService_DisableTOTP(t *testing.T) {
+	type args struct {
+		password string
+	}
+	type want struct {
+		disabled bool
+		err      error
+	}
+	type test struct {
+		name     string
+		args     args
+		required bool
+		want     want
+	}
+
+	tests := []test{
+		{name: "turn off", args: args{password: "password"}, required: false, want: want{disabled: true, err: nil}},
+		{name: "wrong password", args: args{password: "wrong"}, required: false, want: want{disabled: false, err: ErrWrongPassword}},
+		{name: "required by admin", args: args{password: "password"}, required: true, want: want{disabled: false, err: ErrTOTPRequired}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			disabled := false
+			userRepo := &repository.UserMock{
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					return &users.User{ID: userID, Username: "username", TOTPEnabled: true, TOTPRequired: test.required}, nil
+				},
+				CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+					return &users.User{ID: 1, Username: username}, password == "password", nil
+				},
+				DisableTOTPFunc: func(ctx context.Context, userID int) error {
+					disabled = true
+					return nil
+				},
+			}
+
+			service := NewUserService(userRepo, nil, testHasher)
+
+			err := service.DisableTOTP(context.Background(), 1, test.args.password)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.disabled, disabled)
+		})
+	}
+}
+
+func TestUserService_SetEmail(t *testing.T) {
+	type args struct {
+		email    string
+		password string
+	}
+	type want struct {
+		stored string
+		err    error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{name: "set email", args: args{email: "Alice@Example.com", password: "password"}, want: want{stored: "alice@example.com", err: nil}},
+		{name: "clear email", args: args{email: "", password: "password"}, want: want{stored: "", err: nil}},
+		{name: "display name not allowed", args: args{email: "Alice <alice@example.com>", password: "password"}, want: want{stored: "unset", err: ErrInvalidEmail}},
+		{name: "wrong password", args: args{email: "alice@example.com", password: "wrong"}, want: want{stored: "unset", err: ErrWrongPassword}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stored := "unset"
+			userRepo := &repository.UserMock{
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					return &users.User{ID: userID, Username: "alice"}, nil
+				},
+				CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+					return &users.User{ID: 1, Username: username}, password == "password", nil
+				},
+				SetEmailFunc: func(ctx context.Context, userID int, email string) error {
+					stored = email
+					return nil
+				},
+			}
+
+			service := NewUserService(userRepo, nil, testHasher)
+
+			err := service.SetEmail(context.Background(), 1, test.args.email, test.args.password)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.stored, stored)
+		})
+	}
+}
+
+func TestUserService_DeleteAccount(t *testing.T) {
+	type args struct {
+		userID   int
+		password string
+	}
+	type want struct {
+		deleted bool
+		err     error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{name: "delete", args: args{userID: 3, password: "password"}, want: want{deleted: true, err: nil}},
+		{name: "wrong password", args: args{userID: 3, password: "wrong"}, want: want{deleted: false, err: ErrWrongPassword}},
+		{name: "admin with another admin", args: args{userID: 1, password: "password"}, want: want{deleted: true, err: nil}},
+		{name: "last active admin", args: args{userID: 2, password: "password"}, want: want{deleted: false, err: ErrLastAdmin}},
+	}
+
+	admin := []enum.Permission{enum.PermUserManage}
+	accounts := map[int][]users.User{
+		1: {{ID: 1, Permissions: admin}, {ID: 2, Permissions: admin}, {ID: 3}},
+		2: {{ID: 1, Permissions: admin, Disabled: true}, {ID: 2, Permissions: admin}, {ID: 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deleted := false
+			userRepo := &repository.UserMock{
+				GetByUserIDFunc: func(ctx context.Context, userID int) (*users.User, error) {
+					if userID == 3 {
+						return &users.User{ID: userID, Username: "username"}, nil
+					}
+					return &users.User{ID: userID, Username: "admin", Permissions: admin}, nil
+				},
+				CheckPasswordFunc: func(ctx context.Context, username string, password string) (*users.User, bool, error) {
+					return &users.User{Username: username}, password == "password", nil
+				},
+				ListUsersFunc: func(ctx context.Context) ([]users.User, error) {
+					return accounts[test.args.userID], nil
+				},
+				DeleteUserFunc: func(ctx context.Context, userID int) error {
+					assert.Equal(t, test.args.userID, userID)
+					deleted = true
+					return nil
 				},
 			}
 
-			service := NewUserService(userRepo)
+			service := NewUserService(userRepo, nil, testHasher)
 
-			err := service.Register(context.Background(), test.args.username, test.args.password)
+			err := service.DeleteAccount(context.Background(), test.args.userID, test.args.password)
 			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.deleted, deleted)
 		})
 	}
 }
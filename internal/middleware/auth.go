@@ -2,45 +2,127 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"goserv/internal/domain/sessions"
 	"goserv/internal/domain/sessions/repository"
+	"goserv/internal/domain/tokens"
+	tokenRepo "goserv/internal/domain/tokens/repository"
+	"goserv/internal/static/enum"
+	"log"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 )
 
-func AuthRestrictMiddleware(sessionRepo repository.Session) func(http.Handler) http.Handler {
+var errNoSession = errors.New("no session")
+var errInvalidSession = errors.New("invalid session")
+var errExpiredSession = errors.New("session expired")
+var errInvalidToken = errors.New("invalid token")
+
+func AuthRestrictMiddleware(sessionRepo repository.Session, tokenRepo tokenRepo.Token, policy sessions.Policy) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			cookie, err := r.Cookie("id")
+			ctx, err := authenticate(w, r, sessionRepo, tokenRepo, policy)
 			if err != nil {
-				http.Error(w, "Unauthorized: no session", http.StatusUnauthorized)
+				http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
 				return
 			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			userID, err := sessionRepo.GetUserIDBySessionID(r.Context(), cookie.Value)
+func AuthCheckMiddleware(sessionRepo repository.Session, tokenRepo tokenRepo.Token, policy sessions.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := authenticate(w, r, sessionRepo, tokenRepo, policy)
 			if err != nil {
-				http.Error(w, "Unauthorized: invalid session", http.StatusUnauthorized)
-				return
+				ctx = context.WithValue(r.Context(), userKey, 0)
 			}
-
-			ctx := context.WithValue(r.Context(), userKey, userID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func AuthCheckMiddleware(sessionRepo repository.Session) func(http.Handler) http.Handler {
+// authenticate accepts either an "Authorization: Bearer" API token or the session cookie.
+// Token requests also carry the token's scopes in the returned context, while cookie
+// sessions are checked against the policy timeouts and renewed as they are used.
+func authenticate(w http.ResponseWriter, r *http.Request, sessionRepo repository.Session, tokenRepo tokenRepo.Token, policy sessions.Policy) (context.Context, error) {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token, err := tokenRepo.GetByHash(r.Context(), tokens.Hash(strings.TrimSpace(bearer)))
+		if err != nil {
+			return nil, errInvalidToken
+		}
+
+		if err := tokenRepo.TouchToken(r.Context(), token.ID, time.Now()); err != nil {
+			log.Printf("Failed to update token last use for token %d: %v\n", token.ID, err)
+		}
+
+		ctx := context.WithValue(r.Context(), userKey, token.UserID)
+		ctx = context.WithValue(ctx, scopeKey, token.Scopes)
+		return ctx, nil
+	}
+
+	cookie, err := r.Cookie(sessions.CookieName)
+	if err != nil {
+		return nil, errNoSession
+	}
+
+	session, err := sessionRepo.GetSession(r.Context(), cookie.Value)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+
+	now := time.Now()
+	if session.Expired(now) {
+		return nil, errExpiredSession
+	}
+
+	if policy.NeedsRenewal(session, now) {
+		expiresAt := policy.ExpiresAt(session.CreatedAt, now)
+		if err := sessionRepo.RenewSession(r.Context(), session.ID, now, expiresAt); err != nil {
+			log.Printf("Failed to renew session for user %d: %v\n", session.UserID, err)
+		} else {
+			http.SetCookie(w, sessions.NewCookie(session.ID, expiresAt))
+		}
+	}
+	return context.WithValue(r.Context(), userKey, session.UserID), nil
+}
+
+// HasScope reports whether the request may act within scope. Cookie sessions carry every scope.
+func HasScope(r *http.Request, scope enum.Scope) bool {
+	scopes, ok := r.Context().Value(scopeKey).([]enum.Scope)
+	if !ok {
+		return true
+	}
+	return slices.Contains(scopes, scope)
+}
+
+func IsTokenRequest(r *http.Request) bool {
+	_, ok := r.Context().Value(scopeKey).([]enum.Scope)
+	return ok
+}
+
+func RequireScope(scope enum.Scope) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			userID := 0
-			cookie, err := r.Cookie("id")
-			if err == nil {
-				userID, err = sessionRepo.GetUserIDBySessionID(r.Context(), cookie.Value)
-				if err != nil {
-					userID = 0
-				}
+			if !HasScope(r, scope) {
+				http.Error(w, "Forbidden: token is missing scope "+string(scope), http.StatusForbidden)
+				return
 			}
-
-			ctx := context.WithValue(r.Context(), userKey, userID)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// SessionOnly keeps API tokens away from pages such as token management.
+func SessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsTokenRequest(r) {
+			http.Error(w, "Forbidden: API tokens cannot access this page", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
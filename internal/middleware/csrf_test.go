@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRF(t *testing.T) {
+	secret := []byte("secret")
+	nonce := newCSRFNonce()
+	token := signCSRF(secret, nonce)
+
+	type args struct {
+		method string
+		cookie string
+		field  string
+		header string
+		bearer string
+	}
+	type want struct {
+		status    int
+		newCookie bool
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "get issues a cookie",
+			args: args{method: http.MethodGet},
+			want: want{status: http.StatusOK, newCookie: true},
+		},
+		{
+			name: "get keeps a valid cookie",
+			args: args{method: http.MethodGet, cookie: nonce},
+			want: want{status: http.StatusOK, newCookie: false},
+		},
+		{
+			name: "post with form token",
+			args: args{method: http.MethodPost, cookie: nonce, field: token},
+			want: want{status: http.StatusOK, newCookie: false},
+		},
+		{
+			name: "post with header token",
+			args: args{method: http.MethodPost, cookie: nonce, header: token},
+			want: want{status: http.StatusOK, newCookie: false},
+		},
+		{
+			name: "post without token",
+			args: args{method: http.MethodPost, cookie: nonce},
+			want: want{status: http.StatusForbidden, newCookie: false},
+		},
+		{
+			name: "post with wrong token",
+			args: args{method: http.MethodPost, cookie: nonce, field: signCSRF([]byte("other"), nonce)},
+			want: want{status: http.StatusForbidden, newCookie: false},
+		},
+		{
+			name: "token for another cookie",
+			args: args{method: http.MethodPost, cookie: newCSRFNonce(), field: token},
+			want: want{status: http.StatusForbidden, newCookie: false},
+		},
+		{
+			name: "post without cookie",
+			args: args{method: http.MethodPost, field: token},
+			want: want{status: http.StatusForbidden, newCookie: true},
+		},
+		{
+			name: "malformed cookie",
+			args: args{method: http.MethodDelete, cookie: "short", header: signCSRF(secret, "short")},
+			want: want{status: http.StatusForbidden, newCookie: true},
+		},
+		{
+			name: "api token is exempt",
+			args: args{method: http.MethodDelete, bearer: "gsv_token"},
+			want: want{status: http.StatusOK, newCookie: true},
+		},
+	}
+
+	handler := CSRF(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, w.Header().Get(CSRFHeaderName), CSRFToken(r))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			if test.args.field != "" {
+				form.Set(CSRFFieldName, test.args.field)
+			}
+			r := httptest.NewRequest(test.args.method, "/delete", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.args.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: test.args.cookie})
+			}
+			if test.args.header != "" {
+				r.Header.Set(CSRFHeaderName, test.args.header)
+			}
+			if test.args.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+test.args.bearer)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, test.want.status, w.Code)
+			assert.Equal(t, test.want.newCookie, len(w.Result().Cookies()) == 1)
+		})
+	}
+}
@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"context"
+	"goserv/internal/domain/posts"
 	pRepo "goserv/internal/domain/posts/repository"
 	uRepo "goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
 	"net/http"
 	"strconv"
 )
@@ -29,21 +31,14 @@ func DeleteMiddleware(userRepo uRepo.User, postRepo pRepo.Post) func(http.Handle
 				http.Error(w, "Error deleting", http.StatusInternalServerError)
 				return
 			}
-			ctx = context.WithValue(ctx, filenameKey, post.Filename)
-			ctx = context.WithValue(ctx, fileExtKey, post.FileExt)
 
-			isAdmin, err := userRepo.IsAdmin(r.Context(), userID)
+			allowed, err := CanModifyPost(r.Context(), userRepo, post, userID, enum.PermPostDeleteAny)
 			if err != nil {
 				http.Error(w, "Error deleting", http.StatusInternalServerError)
 				return
 			}
 
-			if isAdmin {
-				next.ServeHTTP(w, r.WithContext(ctx))
-				return
-			}
-
-			if post.OwnerID == userID {
+			if allowed {
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
@@ -52,3 +47,11 @@ func DeleteMiddleware(userRepo uRepo.User, postRepo pRepo.Post) func(http.Handle
 		})
 	}
 }
+
+// CanModifyPost allows the post's owner, and anyone whose role grants perm, to change or remove it.
+func CanModifyPost(ctx context.Context, userRepo uRepo.User, post *posts.Post, userID int, perm enum.Permission) (bool, error) {
+	if post.OwnerID == userID {
+		return true, nil
+	}
+	return HasPermission(ctx, userRepo, userID, perm)
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	pRepo "goserv/internal/domain/posts/repository"
+	uRepo "goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
+	myErrors "goserv/internal/utils/errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// EditMiddleware loads the post named by the {id} route parameter and only lets
+// its owner or users allowed to edit any post through. The post ID is stored like DeleteMiddleware does.
+func EditMiddleware(userRepo uRepo.User, postRepo pRepo.Post) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+			if !ok {
+				http.Error(w, "Error getting user id", http.StatusInternalServerError)
+				return
+			}
+
+			postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			post, err := postRepo.GetPost(r.Context(), postID)
+			if err != nil {
+				if errors.Is(err, myErrors.ErrNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				http.Error(w, "Error getting post", http.StatusInternalServerError)
+				return
+			}
+
+			allowed, err := CanModifyPost(r.Context(), userRepo, post, userID, enum.PermPostEditAny)
+			if err != nil {
+				http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				http.Error(w, "Forbidden: only the owner or a moderator can edit this post", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), postKey, postID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
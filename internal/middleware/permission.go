@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	uRepo "goserv/internal/domain/users/repository"
+	"goserv/internal/static/enum"
+	"net/http"
+	"slices"
+)
+
+// HasPermission reports whether the user's role grants perm.
+func HasPermission(ctx context.Context, userRepo uRepo.User, userID int, perm enum.Permission) (bool, error) {
+	perms, err := userRepo.GetPermissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(perms, perm), nil
+}
+
+// RequirePermission answers 403 unless the logged in user's role grants perm.
+func RequirePermission(userRepo uRepo.User, perm enum.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+			if !ok || userID == 0 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			allowed, err := HasPermission(r.Context(), userRepo, userID, perm)
+			if err != nil {
+				http.Error(w, "Error checking permissions", http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				http.Error(w, "Forbidden: missing permission "+string(perm), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
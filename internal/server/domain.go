@@ -1,6 +1,15 @@
 package server
 
 import (
+	"context"
+	v1 "goserv/internal/api/v1"
+	adminHandler "goserv/internal/domain/admin/handler"
+	"goserv/internal/domain/jobs"
+	jobRepo "goserv/internal/domain/jobs/repository"
+	jobService "goserv/internal/domain/jobs/service"
+	poolHandler "goserv/internal/domain/pools/handler"
+	poolRepo "goserv/internal/domain/pools/repository"
+	poolService "goserv/internal/domain/pools/service"
 	postHandler "goserv/internal/domain/posts/handler"
 	postRepo "goserv/internal/domain/posts/repository"
 	postService "goserv/internal/domain/posts/service"
@@ -10,42 +19,155 @@ import (
 	tagHandler "goserv/internal/domain/tags/handler"
 	tagRepo "goserv/internal/domain/tags/repository"
 	tagService "goserv/internal/domain/tags/service"
+	tokenHandler "goserv/internal/domain/tokens/handler"
+	tokenRepo "goserv/internal/domain/tokens/repository"
+	tokenService "goserv/internal/domain/tokens/service"
+	"goserv/internal/domain/users"
 	userHandler "goserv/internal/domain/users/handler"
 	userRepo "goserv/internal/domain/users/repository"
 	userService "goserv/internal/domain/users/service"
+	"goserv/pkg/oidc"
+	"log"
+	"net/http"
+	"strings"
+	"time"
 )
 
 func (s *Server) initDomain() {
-	postHandler, tagHandler := s.initContent()
-	userHandler, sessionHandler := s.initAuth()
+	postHandler, tagHandler, poolHandler, pService, tService := s.initContent()
+	userHandler, sessionHandler, uService, rService, sService := s.initAuth(pService)
+	tokenHandler := s.initTokens()
+	adminHandler := adminHandler.NewAdminHandler(uService, rService, sService, tService, pService, s.tmplCache)
+	s.api = v1.NewAPI(pService, tService, uService, sService)
 
-	s.initRoutes(tagHandler, postHandler, userHandler, sessionHandler)
+	s.initRoutes(tagHandler, postHandler, poolHandler, userHandler, sessionHandler, tokenHandler, adminHandler)
 }
 
-func (s *Server) initContent() (*postHandler.PostHandler, *tagHandler.TagHandler) {
+func (s *Server) initContent() (
+	*postHandler.PostHandler,
+	*tagHandler.TagHandler,
+	*poolHandler.PoolHandler,
+	*postService.PostService,
+	*tagService.TagService,
+) {
 	tRepo := tagRepo.NewTagRepository(s.ent)
 	tService := tagService.NewTagService(tRepo)
 	tHandler := tagHandler.NewTagHandler(tService, s.tmplCache)
 	s.tag = tRepo
 
+	jRepo := jobRepo.NewJobRepository(s.ent)
 	pRepo := postRepo.NewPostRepository(s.ent)
-	pService := postService.NewPostService(pRepo)
-	pHandler := postHandler.NewPostHandler(pService, tService, s.tmplCache)
+	pService := postService.NewPostService(pRepo, s.blob, jRepo)
+	// pools and posts check edit permissions before initAuth has built the shared user repository
+	uRepo := userRepo.NewUserRepository(s.ent, s.hasher)
+	plService := poolService.NewPoolService(poolRepo.NewPoolRepository(s.ent), uRepo)
+	plHandler := poolHandler.NewPoolHandler(plService, s.tmplCache)
+	pHandler := postHandler.NewPostHandler(pService, tService, plService, uRepo, s.tmplCache)
 	s.post = pRepo
+	s.initJobs(jRepo, pService)
 
-	return pHandler, tHandler
+	return pHandler, tHandler, plHandler, pService, tService
 }
 
-func (s *Server) initAuth() (*userHandler.UserHandler, *sessionHandler.SessionHandler) {
-	userRepo := userRepo.NewUserRepository(s.ent)
-	userService := userService.NewUserService(userRepo)
-	userHandler := userHandler.NewUserHandler(userService, s.tmplCache)
-	s.user = userRepo
+func (s *Server) initJobs(jRepo jobRepo.Job, pService *postService.PostService) {
+	s.jobs = jobService.NewJobService(jRepo, jobService.Options{
+		Workers:      s.cfg.JobWorkers,
+		PollInterval: s.cfg.JobPollInterval,
+		MaxAttempts:  s.cfg.JobMaxAttempts,
+	})
+	s.jobs.Register(jobs.KindProcessPost, jobService.Handler{
+		Run: func(ctx context.Context, job *jobs.Job) error {
+			return pService.ProcessPost(ctx, job.PostID)
+		},
+		OnFailure: func(ctx context.Context, job *jobs.Job, err error) {
+			pService.MarkProcessingFailed(ctx, job.PostID)
+		},
+	})
+}
 
+func (s *Server) initAuth(pService *postService.PostService) (
+	*userHandler.UserHandler,
+	*sessionHandler.SessionHandler,
+	*userService.UserService,
+	*userService.RegistrationService,
+	*sessionService.SessionService,
+) {
+	throttleRepo := sessionRepo.NewThrottleRepository(s.ent)
 	sessionRepo := sessionRepo.NewSessionRepository(s.ent)
-	sessionService := sessionService.NewSessionService(sessionRepo, userRepo)
-	sessionHandler := sessionHandler.NewSessionHandler(sessionService, s.tmplCache)
+
+	resetRepo := userRepo.NewResetRepository(s.ent)
+	inviteRepo := userRepo.NewInviteRepository(s.ent)
+	userRepo := userRepo.NewUserRepository(s.ent, s.hasher)
+	resetService := userService.NewPasswordResetService(userRepo, resetRepo, sessionRepo, s.mailer, s.hasher, userService.ResetOptions{
+		TTL:     s.cfg.PasswordResetTTL,
+		BaseURL: s.cfg.BaseURL,
+	})
+	registrationService := s.initRegistration(userRepo, inviteRepo)
+	profileService := userService.NewProfileService(userRepo, s.blob)
+	userService := userService.NewUserService(userRepo, sessionRepo, s.hasher)
+	userHandler := userHandler.NewUserHandler(userService, resetService, registrationService, profileService, pService, s.tmplCache)
+	s.user = userRepo
+	s.reset = resetRepo
+
+	sessionService := sessionService.NewSessionService(sessionRepo, userRepo, throttleRepo, s.sessionPolicy(), s.loginLimits(), s.secret)
+	oidcService := s.initOIDC(sessionService, userRepo)
+	sessionHandler := sessionHandler.NewSessionHandler(sessionService, oidcService, userService, s.tmplCache)
 	s.session = sessionRepo
+	s.throttle = throttleRepo
+
+	return userHandler, sessionHandler, userService, registrationService, sessionService
+}
+
+func (s *Server) initRegistration(uRepo userRepo.User, inviteRepo userRepo.Invite) *userService.RegistrationService {
+	switch s.cfg.RegistrationMode {
+	case users.RegistrationOpen, users.RegistrationInvite, users.RegistrationClosed:
+	default:
+		log.Fatalf("Unknown registration mode %q\n", s.cfg.RegistrationMode)
+	}
+
+	return userService.NewRegistrationService(uRepo, inviteRepo, s.hasher, userService.RegistrationOptions{
+		Mode:        s.cfg.RegistrationMode,
+		DefaultRole: s.cfg.DefaultRole,
+		MaxUses:     s.cfg.InviteMaxUses,
+		MaxTTL:      s.cfg.InviteMaxTTL,
+	})
+}
+
+// initOIDC sets up the external login providers from the config. Their redirect URLs sit under BaseURL.
+func (s *Server) initOIDC(sService *sessionService.SessionService, uRepo userRepo.User) *sessionService.OIDCService {
+	providers := make([]sessionService.OIDCProvider, len(s.cfg.OIDCProviders))
+	for i, p := range s.cfg.OIDCProviders {
+		client, err := oidc.NewClient(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  strings.TrimSuffix(s.cfg.BaseURL, "/") + "/login/oidc/" + p.Name + "/callback",
+			Scopes:       p.Scopes,
+		}, &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			log.Fatalf("Failed to set up login provider %s: %v\n", p.Name, err)
+		}
+		// external logins only create accounts while anyone may register
+		if p.AllowSignup && s.cfg.RegistrationMode != users.RegistrationOpen {
+			log.Printf("Signups through %s are off while registration is %s\n", p.Name, s.cfg.RegistrationMode)
+		}
+		providers[i] = sessionService.OIDCProvider{
+			Name:        p.Name,
+			DisplayName: p.DisplayName,
+			AllowSignup: p.AllowSignup,
+			Client:      client,
+		}
+	}
+
+	identityRepo := userRepo.NewIdentityRepository(s.ent)
+	return sessionService.NewOIDCService(sService, uRepo, identityRepo, providers, s.cfg.RegistrationMode, s.cfg.DefaultRole, s.secret)
+}
+
+func (s *Server) initTokens() *tokenHandler.TokenHandler {
+	tokenRepo := tokenRepo.NewTokenRepository(s.ent)
+	tokenService := tokenService.NewTokenService(tokenRepo)
+	tokenHandler := tokenHandler.NewTokenHandler(tokenService, s.tmplCache)
+	s.token = tokenRepo
 
-	return userHandler, sessionHandler
+	return tokenHandler
 }
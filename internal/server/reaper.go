@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"goserv/internal/domain/sessions"
+	"log"
+	"time"
+)
+
+func (s *Server) sessionPolicy() sessions.Policy {
+	return sessions.Policy{
+		Absolute: s.cfg.SessionAbsoluteTimeout,
+		Idle:     s.cfg.SessionIdleTimeout,
+	}
+}
+
+func (s *Server) loginLimits() sessions.LoginLimits {
+	policy := func(attempts int) sessions.ThrottlePolicy {
+		return sessions.ThrottlePolicy{
+			FreeAttempts: attempts,
+			BaseDelay:    s.cfg.LoginBaseDelay,
+			MaxDelay:     s.cfg.LoginMaxDelay,
+			ResetAfter:   s.cfg.LoginResetAfter,
+		}
+	}
+	return sessions.LoginLimits{
+		Account: policy(s.cfg.LoginAccountAttempts),
+		IP:      policy(s.cfg.LoginIPAttempts),
+	}
+}
+
+// startSessionReaper periodically purges expired sessions, old login throttles and expired password resets
+// until stopSessionReaper is called.
+func (s *Server) startSessionReaper() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopReaper = cancel
+	s.reaperDone = make(chan struct{})
+
+	go func() {
+		defer close(s.reaperDone)
+
+		ticker := time.NewTicker(s.cfg.SessionReapInterval)
+		defer ticker.Stop()
+
+		for {
+			s.reapSessions(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Server) reapSessions(ctx context.Context) {
+	removed, err := s.session.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to purge expired sessions: %v\n", err)
+		}
+	} else if removed > 0 {
+		log.Printf("Purged %d expired sessions\n", removed)
+	}
+
+	now := time.Now()
+	removed, err = s.throttle.DeleteStaleThrottles(ctx, now, now.Add(-s.cfg.LoginResetAfter))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to purge login throttles: %v\n", err)
+		}
+	} else if removed > 0 {
+		log.Printf("Purged %d stale login throttles\n", removed)
+	}
+
+	removed, err = s.reset.DeleteExpiredResets(ctx, now)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to purge password resets: %v\n", err)
+		}
+	} else if removed > 0 {
+		log.Printf("Purged %d expired password resets\n", removed)
+	}
+}
+
+func (s *Server) stopSessionReaper() {
+	if s.stopReaper == nil {
+		return
+	}
+	s.stopReaper()
+	<-s.reaperDone
+}
@@ -1,14 +1,21 @@
 package server
 
 import (
+	"errors"
+	adminHandler "goserv/internal/domain/admin/handler"
+	poolHandler "goserv/internal/domain/pools/handler"
 	postHandler "goserv/internal/domain/posts/handler"
 	sessionHandler "goserv/internal/domain/sessions/handler"
 	tagHandler "goserv/internal/domain/tags/handler"
+	tokenHandler "goserv/internal/domain/tokens/handler"
 	userHandler "goserv/internal/domain/users/handler"
 	"goserv/internal/middleware"
+	"goserv/internal/static/enum"
+	"goserv/pkg/storage"
+	"io"
 	"log"
 	"net/http"
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
@@ -19,13 +26,20 @@ const fileHashLen = 64
 func (s *Server) initRoutes(
 	tagHandler *tagHandler.TagHandler,
 	postHandler *postHandler.PostHandler,
+	poolHandler *poolHandler.PoolHandler,
 	userHandler *userHandler.UserHandler,
-	sessionHandler *sessionHandler.SessionHandler) {
+	sessionHandler *sessionHandler.SessionHandler,
+	tokenHandler *tokenHandler.TokenHandler,
+	adminHandler *adminHandler.AdminHandler) {
 
-	authMiddleware := middleware.AuthRestrictMiddleware(s.session)
-	checkMiddleware := middleware.AuthCheckMiddleware(s.session)
+	authMiddleware := middleware.AuthRestrictMiddleware(s.session, s.token, s.sessionPolicy())
+	checkMiddleware := middleware.AuthCheckMiddleware(s.session, s.token, s.sessionPolicy())
 	deleteMiddleware := middleware.DeleteMiddleware(s.user, s.post)
+	editMiddleware := middleware.EditMiddleware(s.user, s.post)
 	newTagMiddleware := middleware.AddNewTags(s.tag)
+	createMiddleware := middleware.RequirePermission(s.user, enum.PermPostCreate)
+
+	s.router.Use(middleware.CSRF(s.secret))
 
 	s.router.With(checkMiddleware).Get("/",
 		func(w http.ResponseWriter, r *http.Request) {
@@ -47,8 +61,17 @@ func (s *Server) initRoutes(
 
 	s.router.With(checkMiddleware).Get("/register", userHandler.DisplayRegister)
 	s.router.With(checkMiddleware).Post("/register", userHandler.Register)
+	s.router.Get("/password/forgot", userHandler.DisplayForgotPassword)
+	s.router.Post("/password/forgot", userHandler.ForgotPassword)
+	s.router.Get("/password/reset", userHandler.DisplayResetPassword)
+	s.router.Post("/password/reset", userHandler.ResetPassword)
 	s.router.With(checkMiddleware).Get("/login", sessionHandler.DisplayLogin)
 	s.router.With(checkMiddleware).Post("/login", sessionHandler.Login)
+	s.router.Get("/login/2fa", sessionHandler.DisplaySecondFactor)
+	s.router.Post("/login/2fa", sessionHandler.SecondFactor)
+	s.router.Post("/login/2fa/enable", sessionHandler.EnrollSecondFactor)
+	s.router.With(checkMiddleware).Get("/login/oidc/{provider}", sessionHandler.BeginOIDC)
+	s.router.With(checkMiddleware).Get("/login/oidc/{provider}/callback", sessionHandler.OIDCCallback)
 	s.router.With(authMiddleware).Get("/logout", sessionHandler.DisplayLogout)
 	s.router.With(authMiddleware).Post("/logout", sessionHandler.Logout)
 
@@ -59,23 +82,116 @@ func (s *Server) initRoutes(
 		r.Get("/people", tagHandler.ListPeopleTags)
 	})
 
+	s.router.With(checkMiddleware).Get("/users/{username}", userHandler.PublicProfile)
+
+	s.router.With(checkMiddleware).Get("/pools", poolHandler.ListPools)
+	s.router.With(checkMiddleware).Get("/pools/{id}", poolHandler.ViewPool)
+	s.router.With(authMiddleware, middleware.SessionOnly).Group(func(r chi.Router) {
+		r.Post("/pools", poolHandler.CreatePool)
+		r.Post("/pools/{id}/edit", poolHandler.EditPool)
+		r.Post("/pools/{id}/delete", poolHandler.DeletePool)
+		r.Post("/pools/{id}/posts/remove", poolHandler.RemovePost)
+		r.Post("/pools/{id}/order", poolHandler.ReorderPosts)
+	})
+
+	s.router.With(authMiddleware, middleware.RequireScope(enum.ScopePostsWrite), editMiddleware).Route("/view/posts/{id}/edit", func(r chi.Router) {
+		r.Get("/", postHandler.ViewEditPost)
+		r.With(newTagMiddleware).Post("/", postHandler.EditPost)
+	})
+	s.router.With(authMiddleware, middleware.SessionOnly).Post("/view/posts/{id}/progress", postHandler.SaveReadingPosition)
+	s.router.With(authMiddleware, middleware.SessionOnly).Post("/view/posts/{id}/pools", poolHandler.AddPost)
+
 	s.router.With(authMiddleware).Route("/profile", func(r chi.Router) {
 		r.Get("/", userHandler.Profile)
-		r.Get("/create", postHandler.ViewAddPost)
-		r.With(newTagMiddleware).Post("/create", postHandler.AddPost)
-		r.Get("/uploads", postHandler.ListUserPosts)
+		r.With(createMiddleware).Get("/create", postHandler.ViewAddPost)
+		r.With(middleware.RequireScope(enum.ScopePostsWrite), createMiddleware, newTagMiddleware).Post("/create", postHandler.AddPost)
+		r.With(middleware.RequireScope(enum.ScopePostsRead)).Get("/uploads", postHandler.ListUserPosts)
 		//r.Mount("/uploads/", routeSingleUploads(postHandler))
-		r.Get("/favourites", postHandler.ListUserFavs)
+		r.With(middleware.RequireScope(enum.ScopeFavouritesRead)).Get("/favourites", postHandler.ListUserFavs)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.SessionOnly)
+			r.Get("/tokens", tokenHandler.ListTokens)
+			r.Post("/tokens", tokenHandler.CreateToken)
+			r.Post("/tokens/revoke", tokenHandler.RevokeToken)
+
+			r.Get("/sessions", sessionHandler.ListSessions)
+			r.Post("/sessions/revoke", sessionHandler.RevokeSession)
+			r.Post("/sessions/revoke-others", sessionHandler.RevokeOtherSessions)
+			r.Get("/identities", sessionHandler.ListIdentities)
+
+			r.With(middleware.RequirePermission(s.user, enum.PermInviteCreate)).Route("/invites", func(r chi.Router) {
+				r.Get("/", userHandler.ListInvites)
+				r.Post("/", userHandler.CreateInvite)
+				r.Post("/revoke", userHandler.RevokeInvite)
+			})
+
+			r.Get("/password", userHandler.DisplayChangePassword)
+			r.Post("/password", userHandler.ChangePassword)
+			r.Get("/email", userHandler.DisplayEmail)
+			r.Post("/email", userHandler.ChangeEmail)
+
+			r.Get("/edit", userHandler.DisplayEditProfile)
+			r.Post("/edit", userHandler.EditProfile)
+			r.Post("/avatar", userHandler.UploadAvatar)
+			r.Post("/avatar/remove", userHandler.RemoveAvatar)
+
+			r.Get("/export", userHandler.ExportData)
+			r.Get("/delete", userHandler.DisplayDeleteAccount)
+			r.Post("/delete", userHandler.DeleteAccount)
+
+			r.Get("/2fa", userHandler.DisplayTwoFactor)
+			r.Post("/2fa/setup", userHandler.SetupTwoFactor)
+			r.Post("/2fa/enable", userHandler.EnableTwoFactor)
+			r.Post("/2fa/disable", userHandler.DisableTwoFactor)
+			r.Post("/2fa/recovery", userHandler.RegenerateRecoveryCodes)
+		})
 	})
 
-	s.router.With(authMiddleware, deleteMiddleware).Post("/delete", postHandler.DeletePost)
-	s.router.With(authMiddleware).Post("/favourite", postHandler.FavouritePost)
-	s.router.With(authMiddleware).Post("/unfavourite", postHandler.UnfavouritePost)
+	s.router.With(authMiddleware, middleware.RequireScope(enum.ScopePostsWrite), deleteMiddleware).Post("/delete", postHandler.DeletePost)
+	s.router.With(authMiddleware, middleware.RequireScope(enum.ScopeFavouritesWrite)).Post("/favourite", postHandler.FavouritePost)
+	s.router.With(authMiddleware, middleware.RequireScope(enum.ScopeFavouritesWrite)).Post("/unfavourite", postHandler.UnfavouritePost)
+
+	s.router.With(authMiddleware, middleware.SessionOnly).Route("/admin", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(s.user, enum.PermUserManage))
+			r.Get("/users", adminHandler.ListUsers)
+			r.Post("/users/role", adminHandler.SetRole)
+			r.Post("/users/disable", adminHandler.SetDisabled)
+			r.Post("/users/2fa", adminHandler.RequireTwoFactor)
+			r.Post("/users/delete", adminHandler.DeleteUser)
+			r.Post("/users/unlock", adminHandler.UnlockUser)
+			r.Get("/invites", adminHandler.ListInvites)
+			r.Post("/invites/revoke", adminHandler.RevokeInvite)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(s.user, enum.PermTagEdit))
+			r.Get("/tags", adminHandler.ListTags)
+			r.Post("/tags/rename", adminHandler.RenameTag)
+			r.Post("/tags/retype", adminHandler.RetypeTag)
+			r.Post("/tags/delete", adminHandler.DeleteTag)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(s.user, enum.PermPostDeleteAny))
+			r.Get("/posts", adminHandler.ListPosts)
+			r.Post("/posts/takedown", adminHandler.TakedownPost)
+		})
+	})
+
+	s.router.Mount("/api/v1", s.api.Routes(checkMiddleware))
 
 	s.router.Mount("/styles/", http.StripPrefix("/styles/", http.FileServer(http.Dir("styles"))))
 	//s.router.Mount("/assets/content/", http.StripPrefix("/assets/content/", http.FileServer(http.Dir("content"))))
-	s.router.Mount("/assets/content/", routeContentServe())
-	s.router.Mount("/assets/thumbnails/", routeThumbnailServe())
+	s.router.Mount("/assets/content/", routeContentServe(s.blob))
+	s.router.Mount("/assets/thumbnails/", routeThumbnailServe(s.blob))
+	s.router.Mount("/assets/avatars/", routeAvatarServe(s.blob))
+	s.router.Mount("/assets/pages/", routePageServe(s.blob))
 
 	s.router.NotFound(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("404 Not Found: %s\n", r.URL.Path)
@@ -94,54 +210,103 @@ func routeSinglePosts(postHandler *postHandler.PostHandler) http.HandlerFunc {
 	}
 }
 
-func routeContentServe() http.HandlerFunc {
+func routeContentServe(blob storage.Blob) http.HandlerFunc {
+	return routeBlobServe(blob, "/assets/content/", "content")
+}
+
+func routeThumbnailServe(blob storage.Blob) http.HandlerFunc {
+	return routeBlobServe(blob, "/assets/thumbnails/", "thumbnails")
+}
+
+func routePageServe(blob storage.Blob) http.HandlerFunc {
+	return routeBlobServe(blob, "/assets/pages/", "pages")
+}
+
+func routeAvatarServe(blob storage.Blob) http.HandlerFunc {
+	return routeBlobServe(blob, "/assets/avatars/", "avatars")
+}
+
+// routeBlobServe serves files from blob storage, redirecting to the backend when it hands out direct links.
+func routeBlobServe(blob storage.Blob, prefix string, dir string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			filename, ok := strings.CutPrefix(r.URL.Path, "/assets/content/")
+			filename, ok := strings.CutPrefix(r.URL.Path, prefix)
 			if !ok {
 				http.NotFound(w, r)
 				return
 			}
 
-			if len(filename) < fileHashLen+len(".ext") {
+			key, ok := blobKey(dir, filename)
+			if !ok {
 				http.NotFound(w, r)
 				return
 			}
+			link, err := blob.URL(r.Context(), key)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			if link != "" {
+				http.Redirect(w, r, link, http.StatusFound)
+				return
+			}
 
-			title := filename[fileHashLen:]
-			w.Header().Set("Content-Disposition", "attachment; filename="+title)
-			http.ServeFile(w, r, filepath.Join("content", filename[0:2], filename[2:4], filename))
-		default:
-			http.Error(w, "Unsupported status method", http.StatusMethodNotAllowed)
-		}
-	}
-}
-
-func routeThumbnailServe() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			filename, ok := strings.CutPrefix(r.URL.Path, "/assets/thumbnails/")
-			if !ok {
-				http.Error(w, "bad prefix", http.StatusNotFound)
+			info, err := blob.Stat(r.Context(), key)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				http.Error(w, "Error reading file", http.StatusInternalServerError)
 				return
 			}
 
-			if len(filename) < fileHashLen+len(".ext") {
-				http.Error(w, "bad file length", http.StatusNotFound)
+			content, err := blob.Get(r.Context(), key)
+			if err != nil {
+				http.Error(w, "Error reading file", http.StatusInternalServerError)
 				return
 			}
+			defer content.Close()
 
 			title := filename[fileHashLen:]
 			w.Header().Set("Content-Disposition", "attachment; filename="+title)
-			http.ServeFile(w, r, filepath.Join("thumbnails", filename[0:2], filename[2:4], filename))
+			if seeker, ok := content.(io.ReadSeeker); ok {
+				http.ServeContent(w, r, filename, info.ModTime, seeker)
+				return
+			}
+
+			if info.ContentType != "" {
+				w.Header().Set("Content-Type", info.ContentType)
+			}
+			if _, err := io.Copy(w, content); err != nil {
+				log.Printf("Failed to serve %s: %v\n", key, err)
+			}
 		default:
-			http.Error(w, "bad method", http.StatusMethodNotAllowed)
+			http.Error(w, "Unsupported status method", http.StatusMethodNotAllowed)
 		}
 	}
 }
 
+// blobKey finds where a requested file is stored. Only a bare filename starting with its content hash is
+// accepted, so a request cannot climb out of dir.
+func blobKey(dir string, filename string) (string, bool) {
+	if len(filename) < fileHashLen+len(".ext") || strings.ContainsAny(filename, "/\\") {
+		return "", false
+	}
+	for _, c := range filename[:fileHashLen] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", false
+		}
+	}
+
+	key := path.Join(dir, filename[0:2], filename[2:4], filename)
+	if !strings.HasPrefix(key, dir+"/") {
+		return "", false
+	}
+	return key, true
+}
+
 // func routeSingleUploads(postHandler *postHandler.PostHandler) http.HandlerFunc {
 // 	return func(w http.ResponseWriter, r *http.Request) {
 // 		switch r.Method {
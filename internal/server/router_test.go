@@ -0,0 +1,90 @@
+package server
+
+import (
+	"goserv/pkg/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteBlobServe(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+
+	type args struct {
+		path string
+	}
+	type want struct {
+		status int
+		body   string
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "stored file",
+			args: args{path: "/assets/content/" + hash + "beach.jpg"},
+			want: want{status: http.StatusOK, body: "image"},
+		},
+		{
+			name: "dots in the title",
+			args: args{path: "/assets/content/" + hash + "wow..jpg"},
+			want: want{status: http.StatusOK, body: "wow"},
+		},
+		{
+			name: "dot dot segments",
+			args: args{path: "/assets/content/../x/../x/../x/../x/../x/../x/../x/../x/../x/../x/../x/../x/../database/secret.txt"},
+			want: want{status: http.StatusNotFound, body: ""},
+		},
+		{
+			name: "dot dot after the hash",
+			args: args{path: "/assets/content/" + hash + "/../../../../database/secret.txt"},
+			want: want{status: http.StatusNotFound, body: ""},
+		},
+		{
+			name: "backslash",
+			args: args{path: "/assets/content/" + hash + "\\..\\secret.txt"},
+			want: want{status: http.StatusNotFound, body: ""},
+		},
+		{
+			name: "not a hash",
+			args: args{path: "/assets/content/" + strings.Repeat("AB", 32) + "beach.jpg"},
+			want: want{status: http.StatusNotFound, body: ""},
+		},
+	}
+
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "database"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "database", "secret.txt"), []byte("secret"), 0644))
+	contentDir := filepath.Join(root, "content", hash[0:2], hash[2:4])
+	assert.NoError(t, os.MkdirAll(contentDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(contentDir, hash+"beach.jpg"), []byte("image"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(contentDir, hash+"wow..jpg"), []byte("wow"), 0644))
+
+	handler := routeContentServe(storage.NewLocal(root))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL.Path = test.args.path
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			assert.Equal(t, test.want.status, rec.Code)
+			if test.want.status == http.StatusOK {
+				assert.Equal(t, test.want.body, rec.Body.String())
+			} else {
+				assert.NotContains(t, rec.Body.String(), "secret")
+			}
+		})
+	}
+}
@@ -2,13 +2,21 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"goserv/ent/gen"
+	v1 "goserv/internal/api/v1"
 	"goserv/internal/database"
+	jService "goserv/internal/domain/jobs/service"
 	pRepo "goserv/internal/domain/posts/repository"
 	sRepo "goserv/internal/domain/sessions/repository"
 	tRepo "goserv/internal/domain/tags/repository"
+	tokRepo "goserv/internal/domain/tokens/repository"
 	uRepo "goserv/internal/domain/users/repository"
 	"goserv/pkg/config"
+	"goserv/pkg/mailer"
+	"goserv/pkg/password"
+	"goserv/pkg/storage"
 	"goserv/pkg/templates"
 	"html/template"
 	"log"
@@ -19,23 +27,42 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Server struct {
 	cfg config.Config
 
+	secret []byte
+
 	tmplCache *template.Template
 
 	ent *gen.Client
 
-	user    uRepo.User
-	session sRepo.Session
-	post    pRepo.Post
-	tag     tRepo.Tag
+	blob storage.Blob
+
+	mailer mailer.Mailer
+
+	hasher *password.Hasher
+
+	user     uRepo.User
+	session  sRepo.Session
+	throttle sRepo.Throttle
+	reset    uRepo.PasswordReset
+	post     pRepo.Post
+	tag      tRepo.Tag
+	token    tokRepo.Token
+
+	jobs *jService.JobService
+
+	api *v1.API
 
 	router *chi.Mux
 
 	httpServer *http.Server
+
+	stopReaper context.CancelFunc
+	reaperDone chan struct{}
 }
 
 func NewServer() *Server {
@@ -44,13 +71,30 @@ func NewServer() *Server {
 		router: chi.NewRouter(),
 	}
 
+	server.initSecret()
 	server.initDB()
+	server.initStorage()
+	server.initMailer()
+	server.initHasher()
 	server.initTemplates()
 	server.initDomain()
 
 	return server
 }
 
+func (s *Server) initSecret() {
+	if s.cfg.SecretKey != "" {
+		s.secret = []byte(s.cfg.SecretKey)
+		return
+	}
+
+	log.Println("SECRET_KEY is not set, using a random key for this run")
+	s.secret = make([]byte, 32)
+	if _, err := rand.Read(s.secret); err != nil {
+		log.Fatalf("Failed to generate secret key: %v\n", err)
+	}
+}
+
 func (s *Server) initDB() {
 	conn, err := database.NewDB(s.cfg.DB)
 	if err != nil {
@@ -60,6 +104,76 @@ func (s *Server) initDB() {
 	s.ent = conn
 }
 
+func (s *Server) initStorage() {
+	switch s.cfg.StorageBackend {
+	case "local":
+		s.blob = storage.NewLocal(s.cfg.StorageRoot)
+	case "s3":
+		blob, err := storage.NewS3(storage.S3Config{
+			Endpoint:  s.cfg.S3Endpoint,
+			Region:    s.cfg.S3Region,
+			Bucket:    s.cfg.S3Bucket,
+			AccessKey: s.cfg.S3AccessKey,
+			SecretKey: s.cfg.S3SecretKey,
+			URLExpiry: s.cfg.S3URLExpiry,
+		}, &http.Client{Timeout: 5 * time.Minute})
+		if err != nil {
+			log.Fatalf("Failed to set up s3 storage: %v\n", err)
+		}
+		s.blob = blob
+	default:
+		log.Fatalf("Unknown storage backend: %s\n", s.cfg.StorageBackend)
+	}
+}
+
+func (s *Server) initMailer() {
+	switch s.cfg.MailBackend {
+	case "log":
+		s.mailer = mailer.NewLog(s.cfg.MailDir, s.cfg.MailFrom)
+	case "smtp":
+		s.mailer = mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     s.cfg.SMTPHost,
+			Port:     s.cfg.SMTPPort,
+			Username: s.cfg.SMTPUsername,
+			Password: s.cfg.SMTPPassword,
+			From:     s.cfg.MailFrom,
+		})
+	default:
+		log.Fatalf("Unknown mail backend: %s\n", s.cfg.MailBackend)
+	}
+}
+
+// initHasher picks the scheme new password hashes are made with. The other scheme is still
+// checked so existing hashes keep working until they are upgraded at login.
+func (s *Server) initHasher() {
+	if s.cfg.Argon2Parallelism < 1 || s.cfg.Argon2Parallelism > 255 {
+		log.Fatalf("ARGON2_PARALLELISM must be between 1 and 255\n")
+	}
+	if s.cfg.Argon2Iterations < 1 || s.cfg.Argon2Memory < 8*s.cfg.Argon2Parallelism {
+		log.Fatalf("ARGON2_ITERATIONS must be at least 1 and ARGON2_MEMORY at least 8 KiB per lane\n")
+	}
+	argon2id := password.Argon2id{Params: password.Argon2idParams{
+		Memory:      uint32(s.cfg.Argon2Memory),
+		Iterations:  uint32(s.cfg.Argon2Iterations),
+		Parallelism: uint8(s.cfg.Argon2Parallelism),
+		SaltLength:  password.DefaultArgon2idParams.SaltLength,
+		KeyLength:   password.DefaultArgon2idParams.KeyLength,
+	}}
+	if s.cfg.BcryptCost < bcrypt.MinCost || s.cfg.BcryptCost > bcrypt.MaxCost {
+		log.Fatalf("BCRYPT_COST must be between %d and %d\n", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	bcryptScheme := password.Bcrypt{Cost: s.cfg.BcryptCost}
+
+	switch s.cfg.PasswordHash {
+	case "argon2id":
+		s.hasher = password.NewHasher(argon2id, bcryptScheme)
+	case "bcrypt":
+		s.hasher = password.NewHasher(bcryptScheme, argon2id)
+	default:
+		log.Fatalf("Unknown password hash: %s\n", s.cfg.PasswordHash)
+	}
+}
+
 func (s *Server) initTemplates() {
 	tmplCache, err := templates.LoadTemplates("tmpl/*.html")
 	if err != nil {
@@ -82,6 +196,8 @@ func (s *Server) Run() {
 	go func() {
 		start(s)
 	}()
+	s.startSessionReaper()
+	s.jobs.Start()
 
 	_ = gracefulShutdown(context.Background(), s)
 }
@@ -89,7 +205,7 @@ func (s *Server) Run() {
 func start(s *Server) {
 	log.Printf("Starting server on %s...", s.httpServer.Addr)
 	err := s.httpServer.ListenAndServe()
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
 }
@@ -113,6 +229,8 @@ func gracefulShutdown(ctx context.Context, s *Server) error {
 	if err != nil {
 		log.Println(err)
 	}
+	s.stopSessionReaper()
+	s.jobs.Stop()
 	s.closeResources()
 
 	return nil
@@ -13,3 +13,8 @@ func GetVideoExts() []string {
 func GetAudioExts() []string {
 	return []string{".m4a", ".mp3", ".wav", ".ogg", ".opus", ".flac"}
 }
+
+// GetBookExts are the archives a book can be uploaded as, holding one image per page.
+func GetBookExts() []string {
+	return []string{".cbz", ".zip"}
+}
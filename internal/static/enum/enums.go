@@ -31,3 +31,77 @@ func (TagType) Values() []string {
 		string(TagPeople),
 	}
 }
+
+type Scope string
+
+const (
+	ScopePostsRead       Scope = "posts:read"
+	ScopePostsWrite      Scope = "posts:write"
+	ScopeFavouritesRead  Scope = "favourites:read"
+	ScopeFavouritesWrite Scope = "favourites:write"
+)
+
+func (Scope) Values() []string {
+	return []string{
+		string(ScopePostsRead),
+		string(ScopePostsWrite),
+		string(ScopeFavouritesRead),
+		string(ScopeFavouritesWrite),
+	}
+}
+
+type ProcessingState string
+
+const (
+	ProcessingPending ProcessingState = "Pending"
+	ProcessingReady   ProcessingState = "Ready"
+	ProcessingFailed  ProcessingState = "Failed"
+)
+
+func (ProcessingState) Values() []string {
+	return []string{
+		string(ProcessingPending),
+		string(ProcessingReady),
+		string(ProcessingFailed),
+	}
+}
+
+type JobState string
+
+const (
+	JobQueued  JobState = "Queued"
+	JobRunning JobState = "Running"
+	JobDone    JobState = "Done"
+	JobFailed  JobState = "Failed"
+)
+
+func (JobState) Values() []string {
+	return []string{
+		string(JobQueued),
+		string(JobRunning),
+		string(JobDone),
+		string(JobFailed),
+	}
+}
+
+type Permission string
+
+const (
+	PermPostCreate    Permission = "post.create"
+	PermPostEditAny   Permission = "post.edit.any"
+	PermPostDeleteAny Permission = "post.delete.any"
+	PermTagEdit       Permission = "tag.edit"
+	PermUserManage    Permission = "user.manage"
+	PermInviteCreate  Permission = "invite.create"
+)
+
+func (Permission) Values() []string {
+	return []string{
+		string(PermPostCreate),
+		string(PermPostEditAny),
+		string(PermPostDeleteAny),
+		string(PermTagEdit),
+		string(PermUserManage),
+		string(PermInviteCreate),
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goserv/internal/static/constant"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoAudio = errors.New("file has no audio stream")
+
+// AudioInfo is what ffprobe reads from an audio file. Tags come from ID3, MP4 atoms or Vorbis
+// comments, whichever the file uses, and are left empty when missing.
+type AudioInfo struct {
+	Title    string
+	Artist   string
+	Album    string
+	Duration time.Duration
+	// Bitrate is in bits per second
+	Bitrate int
+	// HasCover is set when the file carries embedded cover art
+	HasCover bool
+}
+
+type probeOutput struct {
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		Duration string            `json:"duration"`
+		BitRate  string            `json:"bit_rate"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+type probeStream struct {
+	CodecType   string            `json:"codec_type"`
+	Duration    string            `json:"duration"`
+	BitRate     string            `json:"bit_rate"`
+	Tags        map[string]string `json:"tags"`
+	Disposition struct {
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+}
+
+// ProbeAudio reads the tags, duration and bitrate of the audio file at srcPath.
+func ProbeAudio(srcPath string) (AudioInfo, error) {
+	cmd := exec.Command("ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		srcPath,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return AudioInfo{}, err
+	}
+	return parseProbe(out)
+}
+
+func parseProbe(out []byte) (AudioInfo, error) {
+	var probe probeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return AudioInfo{}, err
+	}
+
+	var audio *probeStream
+	info := AudioInfo{}
+	for i := range probe.Streams {
+		switch {
+		case probe.Streams[i].CodecType == "audio" && audio == nil:
+			audio = &probe.Streams[i]
+		case probe.Streams[i].CodecType == "video" && probe.Streams[i].Disposition.AttachedPic == 1:
+			info.HasCover = true
+		}
+	}
+	if audio == nil {
+		return AudioInfo{}, ErrNoAudio
+	}
+
+	// Ogg keeps its Vorbis comments on the stream rather than the container
+	info.Title = probeTag("title", probe.Format.Tags, audio.Tags)
+	info.Artist = probeTag("artist", probe.Format.Tags, audio.Tags)
+	info.Album = probeTag("album", probe.Format.Tags, audio.Tags)
+
+	seconds, err := strconv.ParseFloat(firstNonEmpty(probe.Format.Duration, audio.Duration), 64)
+	if err == nil && seconds > 0 {
+		info.Duration = time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+	}
+	bitrate, err := strconv.Atoi(firstNonEmpty(probe.Format.BitRate, audio.BitRate))
+	if err == nil && bitrate > 0 {
+		info.Bitrate = bitrate
+	}
+	return info, nil
+}
+
+// probeTag looks name up ignoring case, as Vorbis comments are usually upper case and ID3 tags are not.
+func probeTag(name string, tagSets ...map[string]string) string {
+	for _, tags := range tagSets {
+		for key, value := range tags {
+			if strings.EqualFold(key, name) && strings.TrimSpace(value) != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ExtractCoverArt writes the picture embedded in the audio file at srcPath to dstPath as a thumbnail.
+func ExtractCoverArt(srcPath string, dstPath string) error {
+	coverPath := filepath.Join("tmp", filepath.Base(srcPath)+"-cover"+constant.ThumbnailExt)
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-i", srcPath,
+		"-an",
+		"-map", "0:v:0",
+		"-frames:v", "1",
+		coverPath,
+	)
+
+	err := cmd.Run()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(coverPath); err != nil {
+			log.Printf("Failed to remove temp file: %s\n", coverPath)
+		}
+	}()
+
+	return CreateImageThumbnail(coverPath, dstPath)
+}
+
+// CreateWaveform draws the waveform of the audio file at srcPath to dstPath, for audio without cover art.
+func CreateWaveform(srcPath string, dstPath string) error {
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-i", srcPath,
+		"-filter_complex", fmt.Sprintf("showwavespic=s=%dx%d:colors=white", width, width/2),
+		"-frames:v", "1",
+		dstPath,
+	)
+	return cmd.Run()
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProbe(t *testing.T) {
+	type args struct {
+		out string
+	}
+	type want struct {
+		info AudioInfo
+		err  error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "mp3 with id3 tags and cover",
+			args: args{out: `{
+				"streams": [
+					{"codec_type": "audio", "bit_rate": "320000", "duration": "215.327347", "disposition": {"attached_pic": 0}},
+					{"codec_type": "video", "disposition": {"attached_pic": 1}}
+				],
+				"format": {"duration": "215.327347", "bit_rate": "321045", "tags": {"title": "Song", "artist": "Band", "album": "Record"}}
+			}`},
+			want: want{info: AudioInfo{Title: "Song", Artist: "Band", Album: "Record", Duration: 215327 * time.Millisecond, Bitrate: 321045, HasCover: true}},
+		},
+		{
+			name: "ogg keeps vorbis comments on the stream",
+			args: args{out: `{
+				"streams": [{"codec_type": "audio", "tags": {"TITLE": "Song", "ARTIST": "Band"}}],
+				"format": {"duration": "60.000000", "bit_rate": "128000"}
+			}`},
+			want: want{info: AudioInfo{Title: "Song", Artist: "Band", Duration: time.Minute, Bitrate: 128000}},
+		},
+		{
+			name: "flac upper case tags on the container",
+			args: args{out: `{
+				"streams": [{"codec_type": "audio", "duration": "1.5"}],
+				"format": {"tags": {"ALBUM": " Record "}}
+			}`},
+			want: want{info: AudioInfo{Album: "Record", Duration: 1500 * time.Millisecond}},
+		},
+		{
+			name: "unknown duration and bitrate",
+			args: args{out: `{"streams": [{"codec_type": "audio"}], "format": {"duration": "N/A"}}`},
+			want: want{info: AudioInfo{}},
+		},
+		{
+			name: "no audio stream",
+			args: args{out: `{"streams": [{"codec_type": "video"}], "format": {}}`},
+			want: want{err: ErrNoAudio},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := parseProbe([]byte(test.args.out))
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.info, info)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Order string
+
+const (
+	OrderNewest Order = "newest"
+	OrderOldest Order = "oldest"
+)
+
+const (
+	DefaultLimit = 40
+	MaxLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidRequest = errors.New("invalid page request")
+
+type PageRequest struct {
+	After string
+	Limit int
+	Order Order
+}
+
+type Page[T any] struct {
+	Items []T
+	Next  string
+	Prev  string
+}
+
+// Cursor points at the row a page starts after. Backward cursors walk towards
+// the start of the listing and are produced for "previous" links.
+type Cursor struct {
+	ID       int
+	Backward bool
+}
+
+func (c Cursor) Encode() string {
+	direction := "n"
+	if c.Backward {
+		direction = "p"
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(direction + ":" + strconv.Itoa(c.ID)))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	direction, id, ok := strings.Cut(string(raw), ":")
+	if !ok || (direction != "n" && direction != "p") {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	cursorID, err := strconv.Atoi(id)
+	if err != nil || cursorID <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{ID: cursorID, Backward: direction == "p"}, nil
+}
+
+func (req PageRequest) Normalize() PageRequest {
+	if req.Limit <= 0 {
+		req.Limit = DefaultLimit
+	}
+	if req.Limit > MaxLimit {
+		req.Limit = MaxLimit
+	}
+	if req.Order != OrderOldest {
+		req.Order = OrderNewest
+	}
+	return req
+}
+
+// Cursor decodes After, reporting false when the request is for the first page.
+func (req PageRequest) Cursor() (Cursor, bool, error) {
+	if req.After == "" {
+		return Cursor{}, false, nil
+	}
+	cursor, err := DecodeCursor(req.After)
+	if err != nil {
+		return Cursor{}, false, err
+	}
+	return cursor, true, nil
+}
+
+// Ascending reports whether rows should be fetched in ascending ID order for the given cursor.
+func (req PageRequest) Ascending(cursor Cursor) bool {
+	return (req.Order == OrderOldest) != cursor.Backward
+}
+
+func ParsePageRequest(values url.Values) (PageRequest, error) {
+	req := PageRequest{
+		After: values.Get("after"),
+		Order: Order(values.Get("order")),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			return PageRequest{}, ErrInvalidRequest
+		}
+		req.Limit = parsed
+	}
+
+	switch req.Order {
+	case "", OrderNewest, OrderOldest:
+	default:
+		return PageRequest{}, ErrInvalidRequest
+	}
+
+	if _, _, err := req.Cursor(); err != nil {
+		return PageRequest{}, err
+	}
+	return req.Normalize(), nil
+}
+
+// NewPage builds a page from rows fetched with a limit of req.Limit+1, where the
+// extra row only signals that another page exists in the direction of travel.
+func NewPage[T any](items []T, req PageRequest, cursor Cursor, hasCursor bool, id func(T) int) Page[T] {
+	hasMore := len(items) > req.Limit
+	if hasMore {
+		items = items[:req.Limit]
+	}
+	if cursor.Backward {
+		slices.Reverse(items)
+	}
+
+	page := Page[T]{Items: items}
+	if len(items) == 0 {
+		return page
+	}
+
+	first := Cursor{ID: id(items[0]), Backward: true}.Encode()
+	last := Cursor{ID: id(items[len(items)-1])}.Encode()
+	if cursor.Backward {
+		page.Next = last
+		if hasMore {
+			page.Prev = first
+		}
+	} else {
+		if hasMore {
+			page.Next = last
+		}
+		if hasCursor {
+			page.Prev = first
+		}
+	}
+	return page
+}
+
+type Nav struct {
+	Prev string
+	Next string
+}
+
+// NewNav turns page cursors into links that keep the rest of the current query string.
+func NewNav(u *url.URL, prev string, next string) Nav {
+	link := func(cursor string) string {
+		if cursor == "" {
+			return ""
+		}
+		values := u.Query()
+		values.Set("after", cursor)
+		return u.Path + "?" + values.Encode()
+	}
+	return Nav{Prev: link(prev), Next: link(next)}
+}
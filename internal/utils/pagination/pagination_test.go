@@ -0,0 +1,169 @@
+package pagination
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{ID: 1, Backward: false},
+		{ID: 42, Backward: true},
+	}
+
+	for _, cursor := range tests {
+		decoded, err := DecodeCursor(cursor.Encode())
+		assert.NoError(t, err)
+		assert.Equal(t, cursor, decoded)
+	}
+
+	for _, bad := range []string{"!!", "eDox", Cursor{ID: 0}.Encode()} {
+		_, err := DecodeCursor(bad)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	type want struct {
+		req PageRequest
+		err error
+	}
+	type test struct {
+		name   string
+		values url.Values
+		want   want
+	}
+
+	after := Cursor{ID: 10}.Encode()
+
+	tests := []test{
+		{
+			name:   "defaults",
+			values: url.Values{},
+			want:   want{req: PageRequest{Limit: DefaultLimit, Order: OrderNewest}, err: nil},
+		},
+		{
+			name:   "explicit values",
+			values: url.Values{"after": {after}, "limit": {"10"}, "order": {"oldest"}},
+			want:   want{req: PageRequest{After: after, Limit: 10, Order: OrderOldest}, err: nil},
+		},
+		{
+			name:   "limit is capped",
+			values: url.Values{"limit": {"5000"}},
+			want:   want{req: PageRequest{Limit: MaxLimit, Order: OrderNewest}, err: nil},
+		},
+		{
+			name:   "bad limit",
+			values: url.Values{"limit": {"-1"}},
+			want:   want{req: PageRequest{}, err: ErrInvalidRequest},
+		},
+		{
+			name:   "bad order",
+			values: url.Values{"order": {"sideways"}},
+			want:   want{req: PageRequest{}, err: ErrInvalidRequest},
+		},
+		{
+			name:   "bad cursor",
+			values: url.Values{"after": {"garbage"}},
+			want:   want{req: PageRequest{}, err: ErrInvalidCursor},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := ParsePageRequest(test.values)
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Equal(t, test.want.req, req)
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	type args struct {
+		items     []int
+		cursor    Cursor
+		hasCursor bool
+	}
+	type want struct {
+		page Page[int]
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	req := PageRequest{Limit: 3, Order: OrderNewest}
+	id := func(i int) int { return i }
+
+	tests := []test{
+		{
+			name: "first page with more",
+			args: args{items: []int{9, 8, 7, 6}},
+			want: want{page: Page[int]{
+				Items: []int{9, 8, 7},
+				Next:  Cursor{ID: 7}.Encode(),
+			}},
+		},
+		{
+			name: "only page",
+			args: args{items: []int{9, 8}},
+			want: want{page: Page[int]{Items: []int{9, 8}}},
+		},
+		{
+			name: "middle page going forward",
+			args: args{items: []int{6, 5, 4, 3}, cursor: Cursor{ID: 7}, hasCursor: true},
+			want: want{page: Page[int]{
+				Items: []int{6, 5, 4},
+				Next:  Cursor{ID: 4}.Encode(),
+				Prev:  Cursor{ID: 6, Backward: true}.Encode(),
+			}},
+		},
+		{
+			name: "last page going forward",
+			args: args{items: []int{3, 2}, cursor: Cursor{ID: 4}, hasCursor: true},
+			want: want{page: Page[int]{
+				Items: []int{3, 2},
+				Prev:  Cursor{ID: 3, Backward: true}.Encode(),
+			}},
+		},
+		{
+			name: "going backward with more",
+			args: args{items: []int{4, 5, 6, 7}, cursor: Cursor{ID: 3, Backward: true}, hasCursor: true},
+			want: want{page: Page[int]{
+				Items: []int{6, 5, 4},
+				Next:  Cursor{ID: 4}.Encode(),
+				Prev:  Cursor{ID: 6, Backward: true}.Encode(),
+			}},
+		},
+		{
+			name: "going backward to the first page",
+			args: args{items: []int{7, 8, 9}, cursor: Cursor{ID: 6, Backward: true}, hasCursor: true},
+			want: want{page: Page[int]{
+				Items: []int{9, 8, 7},
+				Next:  Cursor{ID: 7}.Encode(),
+			}},
+		},
+		{
+			name: "empty page",
+			args: args{items: []int{}, cursor: Cursor{ID: 1}, hasCursor: true},
+			want: want{page: Page[int]{Items: []int{}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := NewPage(test.args.items, req, test.args.cursor, test.args.hasCursor, id)
+			assert.Equal(t, test.want.page, page)
+		})
+	}
+}
+
+func TestNewNav(t *testing.T) {
+	u, _ := url.Parse("/view/posts?q=beach&after=old")
+	nav := NewNav(u, "", "abc")
+	assert.Equal(t, "", nav.Prev)
+	assert.Equal(t, "/view/posts?after=abc&q=beach", nav.Next)
+}
@@ -14,32 +14,27 @@ import (
 const width = 400
 const thumbTimestamp = 5
 
-func CreateImageThumbnail(dir string, filename string, fileExt string) error {
-	image, err := imaging.Open(filepath.Join(dir, filename+fileExt))
+// CreateImageThumbnail writes a scaled down copy of the image at srcPath to dstPath.
+func CreateImageThumbnail(srcPath string, dstPath string) error {
+	image, err := imaging.Open(srcPath)
 	if err != nil {
 		log.Println("open error")
 		return err
 	}
 
-	thumbnailDir := filepath.Join("thumbnails", filename[0:2], filename[2:4])
-	if err := os.MkdirAll(thumbnailDir, 0755); err != nil {
-		log.Printf("failed to create thumbnail dir: %v\n", err)
-		return err
-	}
-
 	thumbnail := imaging.Resize(image, width, 0, imaging.Linear)
-	return imaging.Save(thumbnail, filepath.Join(thumbnailDir, filename+constant.ThumbnailExt))
+	return imaging.Save(thumbnail, dstPath)
 }
 
-func ExctractVideoThumbnail(filename string, fileExt string) error {
-	videoPath := filepath.Join("content", filename[0:2], filename[2:4], filename+fileExt)
-	tmpPath := filepath.Join("tmp", filename+constant.ThumbnailExt)
+// ExctractVideoThumbnail grabs a frame from the video at srcPath and writes it to dstPath as a thumbnail.
+func ExctractVideoThumbnail(srcPath string, dstPath string) error {
+	framePath := filepath.Join("tmp", filepath.Base(srcPath)+"-frame"+constant.ThumbnailExt)
 	cmd := exec.Command("ffmpeg",
 		"-y",
-		"-i", videoPath,
+		"-i", srcPath,
 		"-ss", fmt.Sprintf("%d", thumbTimestamp),
 		"-vframes", "1",
-		tmpPath,
+		framePath,
 	)
 
 	err := cmd.Run()
@@ -47,14 +42,14 @@ func ExctractVideoThumbnail(filename string, fileExt string) error {
 		return err
 	}
 
-	err = CreateImageThumbnail("tmp", filename, constant.ThumbnailExt)
+	err = CreateImageThumbnail(framePath, dstPath)
 	if err != nil {
-		log.Printf("Failed to create thumbnail for: %s\n", videoPath)
+		log.Printf("Failed to create thumbnail for: %s\n", srcPath)
 	}
 
-	err = os.Remove(tmpPath)
+	err = os.Remove(framePath)
 	if err != nil {
-		log.Printf("Failed to remove temp file: %s\n", tmpPath)
+		log.Printf("Failed to remove temp file: %s\n", framePath)
 	}
 	return nil
 }
@@ -0,0 +1,112 @@
+package validate
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MinUsernameLen = 3
+	MaxUsernameLen = 32
+	MinPasswordLen = 8
+	// MaxPasswordLen is in bytes, as bcrypt cannot hash anything longer and can still be configured
+	MaxPasswordLen = 72
+
+	MaxDisplayNameLen = 64
+	MaxBioLen         = 1000
+)
+
+var ErrUsernameLength = fmt.Errorf("username must be %d to %d characters long", MinUsernameLen, MaxUsernameLen)
+var ErrUsernameChars = errors.New("username may only contain letters, digits, dots, dashes and underscores")
+var ErrUsernameReserved = errors.New("username is reserved")
+var ErrPasswordLength = fmt.Errorf("password must be at least %d characters and at most %d bytes long", MinPasswordLen, MaxPasswordLen)
+var ErrPasswordUsername = errors.New("password cannot be the same as the username")
+var ErrPasswordBreached = errors.New("password is too common, it appears in lists of breached passwords")
+var ErrDisplayNameLength = fmt.Errorf("display name must be at most %d characters long", MaxDisplayNameLen)
+var ErrDisplayNameChars = errors.New("display name cannot contain line breaks or control characters")
+var ErrBioLength = fmt.Errorf("bio must be at most %d characters long", MaxBioLen)
+
+// reservedUsernames could be mistaken for the site itself or clash with its pages.
+var reservedUsernames = []string{
+	"admin", "administrator", "root", "system", "sysadmin", "moderator", "mod", "staff", "support",
+	"help", "security", "api", "www", "mail", "noreply", "login", "logout", "register", "profile",
+	"settings", "users", "anonymous", "guest", "null", "undefined", "me", "goserv",
+}
+
+//go:embed breached_passwords.txt
+var breachedList string
+
+var breachedPasswords = parseBreached(breachedList)
+
+func parseBreached(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[line] = struct{}{}
+	}
+	return passwords
+}
+
+// Username checks the rules for a new username. Usernames are compared ignoring case elsewhere,
+// so reserved names are matched the same way.
+func Username(username string) error {
+	if len(username) < MinUsernameLen || len(username) > MaxUsernameLen {
+		return ErrUsernameLength
+	}
+	for _, r := range username {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return ErrUsernameChars
+		}
+	}
+
+	lower := strings.ToLower(username)
+	for _, reserved := range reservedUsernames {
+		if lower == reserved {
+			return ErrUsernameReserved
+		}
+	}
+	return nil
+}
+
+// Password checks the policy for a new password. An empty username skips comparing the two.
+func Password(password string, username string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLen || len(password) > MaxPasswordLen {
+		return ErrPasswordLength
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return ErrPasswordUsername
+	}
+	if _, ok := breachedPasswords[strings.ToLower(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+// DisplayName checks the name shown on a profile instead of the username. Empty names are allowed.
+func DisplayName(name string) error {
+	if utf8.RuneCountInString(name) > MaxDisplayNameLen {
+		return ErrDisplayNameLength
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return ErrDisplayNameChars
+		}
+	}
+	return nil
+}
+
+func Bio(bio string) error {
+	if utf8.RuneCountInString(bio) > MaxBioLen {
+		return ErrBioLength
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsername(t *testing.T) {
+	type test struct {
+		name     string
+		username string
+		want     error
+	}
+
+	tests := []test{
+		{name: "plain", username: "alice", want: nil},
+		{name: "dots, dashes and underscores", username: "a.l-i_ce2", want: nil},
+		{name: "too short", username: "al", want: ErrUsernameLength},
+		{name: "too long", username: strings.Repeat("a", MaxUsernameLen+1), want: ErrUsernameLength},
+		{name: "space", username: "al ice", want: ErrUsernameChars},
+		{name: "non ascii letter", username: "álice", want: ErrUsernameChars},
+		{name: "reserved in another case", username: "Admin", want: ErrUsernameReserved},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Username(test.username))
+		})
+	}
+}
+
+func TestPassword(t *testing.T) {
+	type test struct {
+		name     string
+		password string
+		username string
+		want     error
+	}
+
+	tests := []test{
+		{name: "long enough", password: "correct horse", username: "alice", want: nil},
+		{name: "too short", password: "short", want: ErrPasswordLength},
+		{name: "multibyte characters count once", password: "ééééééé", want: ErrPasswordLength},
+		{name: "too long for bcrypt", password: strings.Repeat("a", MaxPasswordLen+1), want: ErrPasswordLength},
+		{name: "same as username", password: "AliceAlice", username: "alicealice", want: ErrPasswordUsername},
+		{name: "breached in another case", password: "PassWord123", want: ErrPasswordBreached},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Password(test.password, test.username))
+		})
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	type test struct {
+		name        string
+		displayName string
+		want        error
+	}
+
+	tests := []test{
+		{name: "empty", displayName: "", want: nil},
+		{name: "spaces and accents", displayName: "Zoë Ann", want: nil},
+		{name: "longest allowed in multibyte characters", displayName: strings.Repeat("é", MaxDisplayNameLen), want: nil},
+		{name: "too long", displayName: strings.Repeat("a", MaxDisplayNameLen+1), want: ErrDisplayNameLength},
+		{name: "line break", displayName: "Zoë\nAnn", want: ErrDisplayNameChars},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, DisplayName(test.displayName))
+		})
+	}
+}
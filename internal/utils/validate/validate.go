@@ -16,6 +16,8 @@ func IsValidFileType(filename string, mediaType enum.MediaType) bool {
 		validExts = constant.GetAudioExts()
 	case enum.MediaVideo:
 		validExts = constant.GetVideoExts()
+	case enum.MediaBook:
+		validExts = constant.GetBookExts()
 	default:
 		return false
 	}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Log writes mail to the server log, or to .eml files in a directory, instead of sending it.
+// It is meant for local development.
+type Log struct {
+	dir  string
+	from string
+}
+
+// NewLog keeps messages as files in dir, or logs them when dir is empty.
+func NewLog(dir string, from string) *Log {
+	return &Log{dir: dir, from: from}
+}
+
+func (m *Log) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := format(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	if m.dir == "" {
+		log.Printf("Mail to %s:\n%s\n", msg.To, data)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := now.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix) + ".eml"
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
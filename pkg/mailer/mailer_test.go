@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"context"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	type args struct {
+		msg Message
+	}
+	type want struct {
+		err error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "plain message",
+			args: args{msg: Message{To: "alice@example.com", Subject: "Reset your password", Body: "line one\nline two"}},
+			want: want{err: nil},
+		},
+		{
+			name: "header injection in subject",
+			args: args{msg: Message{To: "alice@example.com", Subject: "hi\r\nBcc: bob@example.com", Body: "body"}},
+			want: want{err: ErrInvalidHeader},
+		},
+		{
+			name: "bad recipient",
+			args: args{msg: Message{To: "not an address", Subject: "hi", Body: "body"}},
+			want: want{err: ErrInvalidHeader},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := format("goserv <noreply@example.com>", test.args.msg, time.Unix(0, 0))
+			assert.ErrorIs(t, err, test.want.err)
+			if err != nil {
+				return
+			}
+
+			parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+			assert.NoError(t, err)
+			assert.Equal(t, test.args.msg.To, parsed.Header.Get("To"))
+			date, err := parsed.Header.Date()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), date.Unix())
+			assert.Contains(t, string(data), "line one\r\nline two")
+		})
+	}
+}
+
+func TestLog_SendToDir(t *testing.T) {
+	dir := t.TempDir()
+	m := NewLog(dir, "noreply@example.com")
+
+	err := m.Send(context.Background(), Message{To: "alice@example.com", Subject: "Hello", Body: "Hi Alice"})
+	assert.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", parsed.Header.Get("Subject"))
+}
@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP accepts one message and reports the envelope and data it received.
+type fakeSMTP struct {
+	listener net.Listener
+	from     string
+	to       []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := &fakeSMTP{listener: listener, done: make(chan struct{})}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to = append(s.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil || dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTP_Send(t *testing.T) {
+	server := newFakeSMTP(t)
+	host, port, err := net.SplitHostPort(server.listener.Addr().String())
+	assert.NoError(t, err)
+
+	m := NewSMTP(SMTPConfig{Host: host, Port: port, From: "goserv <noreply@example.com>"})
+	err = m.Send(context.Background(), Message{To: "Alice <alice@example.com>", Subject: "Reset", Body: "Follow the link"})
+	assert.NoError(t, err)
+	<-server.done
+
+	assert.Equal(t, "noreply@example.com", server.from)
+	assert.Equal(t, []string{"alice@example.com"}, server.to)
+	assert.Contains(t, server.data, "Subject: Reset\r\n")
+	assert.Contains(t, server.data, "\r\n\r\nFollow the link")
+}
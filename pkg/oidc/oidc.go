@@ -0,0 +1,234 @@
+// Package oidc logs users in through an OpenID Connect provider with the authorization code flow and PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrDiscovery = errors.New("oidc discovery failed")
+
+// DefaultScopes are requested when Config.Scopes is empty.
+var DefaultScopes = []string{"openid", "profile", "email"}
+
+// maxResponseSize caps discovery, key and token responses.
+const maxResponseSize = 1 << 20
+
+type Config struct {
+	// Issuer is the provider's issuer URL. Its /.well-known/openid-configuration is read on first use.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the user back to with the authorization code.
+	RedirectURL string
+	Scopes      []string
+}
+
+// Error is an error response from the provider's authorization or token endpoint.
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return "oidc: " + e.Code
+	}
+	return "oidc: " + e.Code + ": " + e.Description
+}
+
+// Client talks to one provider. Discovery and signing keys are fetched lazily, so a provider that is
+// down at startup does not keep the server from starting.
+type Client struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]*rsa.PublicKey
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewClient(cfg Config, client *http.Client) (*Client, error) {
+	issuer, err := url.Parse(cfg.Issuer)
+	if err != nil || issuer.Host == "" {
+		return nil, fmt.Errorf("invalid oidc issuer %q", cfg.Issuer)
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("oidc client id is required")
+	}
+	if _, err := url.Parse(cfg.RedirectURL); err != nil || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("invalid oidc redirect url %q", cfg.RedirectURL)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DefaultScopes
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Client{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+func (c *Client) discover(ctx context.Context) (*metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	var meta metadata
+	wellKnown := strings.TrimSuffix(c.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, wellKnown, &meta); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	if meta.Issuer != c.cfg.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, meta.Issuer, c.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	c.metadata = &meta
+	return c.metadata, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", target, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v)
+}
+
+// AuthCodeURL returns the provider page to send the user to. state and nonce are echoed back through
+// the callback and the ID token, and verifier must be kept until Exchange.
+func (c *Client) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	meta, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.cfg.ClientID)
+	query.Set("redirect_uri", c.cfg.RedirectURL)
+	query.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", Challenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades an authorization code for the raw ID token.
+func (c *Client) Exchange(ctx context.Context, code string, verifier string) (string, error) {
+	meta, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", c.cfg.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.ClientSecret != "" {
+		// RFC 6749 2.3.1 form-encodes the credentials before they go into the basic auth header
+		req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint answered %s: %w", res.Status, err)
+	}
+	if body.Error != "" {
+		return "", &Error{Code: body.Error, Description: body.ErrorDescription}
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint answered %s", res.Status)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// Login finishes the flow started with AuthCodeURL: it exchanges the code and verifies the ID token.
+func (c *Client) Login(ctx context.Context, code string, verifier string, nonce string) (*Claims, error) {
+	idToken, err := c.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	return c.Verify(ctx, idToken, nonce)
+}
+
+// RandomString returns 32 random bytes encoded for use as a state, nonce or PKCE verifier.
+func RandomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge derives the S256 PKCE code challenge from a verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"goserv/pkg/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://localhost:8080/login/oidc/test/callback"
+
+func newTestClient(t *testing.T, provider *oidctest.Server) *Client {
+	client, err := NewClient(Config{
+		Issuer:       provider.Issuer,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+	}, provider.Client())
+	require.NoError(t, err)
+	return client
+}
+
+// authorize follows authURL to the provider and returns the query it redirects back with.
+func authorize(t *testing.T, authURL string) url.Values {
+	browser := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := browser.Get(authURL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusFound, res.StatusCode)
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(location.String(), redirectURL))
+	return location.Query()
+}
+
+func TestClient_Login(t *testing.T) {
+	provider := oidctest.NewServer()
+	defer provider.Close()
+	provider.SetUser(oidctest.User{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice"})
+
+	type args struct {
+		verifier string
+		nonce    string
+	}
+	type want struct {
+		claims *Claims
+		err    error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "valid flow",
+			args: args{verifier: "", nonce: "nonce"},
+			want: want{claims: &Claims{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice"}, err: nil},
+		},
+		{
+			name: "wrong verifier",
+			args: args{verifier: RandomString(), nonce: "nonce"},
+			want: want{claims: nil, err: &Error{Code: "invalid_grant", Description: "code_verifier mismatch"}},
+		},
+		{
+			name: "wrong nonce",
+			args: args{verifier: "", nonce: "other"},
+			want: want{claims: nil, err: ErrInvalidIDToken},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(t, provider)
+			verifier := RandomString()
+
+			authURL, err := client.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+			require.NoError(t, err)
+			callback := authorize(t, authURL)
+			assert.Equal(t, "state", callback.Get("state"))
+
+			if test.args.verifier != "" {
+				verifier = test.args.verifier
+			}
+			claims, err := client.Login(context.Background(), callback.Get("code"), verifier, test.args.nonce)
+
+			var oidcErr *Error
+			switch {
+			case test.want.err == nil:
+				require.NoError(t, err)
+				assert.Equal(t, test.want.claims.Subject, claims.Subject)
+				assert.Equal(t, test.want.claims.Email, claims.Email)
+				assert.Equal(t, test.want.claims.EmailVerified, claims.EmailVerified)
+				assert.Equal(t, test.want.claims.PreferredUsername, claims.PreferredUsername)
+			case errors.As(test.want.err, &oidcErr):
+				assert.Equal(t, test.want.err, err)
+			default:
+				assert.ErrorIs(t, err, test.want.err)
+			}
+		})
+	}
+}
+
+func TestClient_Verify(t *testing.T) {
+	provider := oidctest.NewServer()
+	defer provider.Close()
+	other := oidctest.NewServer()
+	defer other.Close()
+
+	now := time.Now()
+	user := oidctest.User{Subject: "alice-1"}
+	with := func(key string, value any) map[string]any {
+		claims := provider.Claims(user, "nonce", now)
+		claims[key] = value
+		return claims
+	}
+
+	type args struct {
+		token string
+	}
+	type want struct {
+		err error
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	tests := []test{
+		{
+			name: "valid token",
+			args: args{token: provider.Sign(provider.Claims(user, "nonce", now))},
+			want: want{err: nil},
+		},
+		{
+			name: "audience list with authorized party",
+			args: args{token: provider.Sign(func() map[string]any {
+				claims := with("aud", []string{"other", oidctest.ClientID})
+				claims["azp"] = oidctest.ClientID
+				return claims
+			}())},
+			want: want{err: nil},
+		},
+		{
+			name: "another audience",
+			args: args{token: provider.Sign(with("aud", "other"))},
+			want: want{err: ErrInvalidIDToken},
+		},
+		{
+			name: "another issuer",
+			args: args{token: provider.Sign(with("iss", other.Issuer))},
+			want: want{err: ErrInvalidIDToken},
+		},
+		{
+			name: "expired",
+			args: args{token: provider.Sign(with("exp", now.Add(-2*clockSkew).Unix()))},
+			want: want{err: ErrInvalidIDToken},
+		},
+		{
+			name: "issued in the future",
+			args: args{token: provider.Sign(with("iat", now.Add(2*clockSkew).Unix()))},
+			want: want{err: ErrInvalidIDToken},
+		},
+		{
+			name: "missing subject",
+			args: args{token: provider.Sign(with("sub", ""))},
+			want: want{err: ErrInvalidIDToken},
+		},
+		{
+			name: "signed by another key",
+			args: args{token: other.Sign(provider.Claims(user, "nonce", now))},
+			want: want{err: ErrInvalidIDToken},
+		},
+		{
+			name: "unsigned",
+			args: args{token: "eyJhbGciOiJub25lIn0." + strings.Split(provider.Sign(provider.Claims(user, "nonce", now)), ".")[1] + "."},
+			want: want{err: ErrInvalidIDToken},
+		},
+		{
+			name: "garbage",
+			args: args{token: "not-a-token"},
+			want: want{err: ErrInvalidIDToken},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(t, provider)
+
+			claims, err := client.Verify(context.Background(), test.args.token, "nonce")
+			if test.want.err == nil {
+				require.NoError(t, err)
+				assert.Equal(t, "alice-1", claims.Subject)
+				return
+			}
+			assert.ErrorIs(t, err, test.want.err)
+			assert.Nil(t, claims)
+		})
+	}
+}
+
+func TestClient_DiscoveryIssuerMismatch(t *testing.T) {
+	provider := oidctest.NewServer()
+	defer provider.Close()
+
+	client, err := NewClient(Config{
+		Issuer:      provider.Issuer + "/other",
+		ClientID:    oidctest.ClientID,
+		RedirectURL: redirectURL,
+	}, provider.Client())
+	require.NoError(t, err)
+
+	_, err = client.AuthCodeURL(context.Background(), "state", "nonce", RandomString())
+	assert.ErrorIs(t, err, ErrDiscovery)
+}
@@ -0,0 +1,51 @@
+// Package password hashes passwords into self-describing strings, argon2id in the PHC string format
+// or bcrypt's modular crypt format, so hashes made with older schemes or settings can still be
+// checked and replaced as users log in.
+package password
+
+import (
+	"errors"
+)
+
+var ErrUnknownHash = errors.New("password hash is not in a known format")
+var ErrMalformedHash = errors.New("password hash is malformed")
+
+// Scheme is one way of hashing passwords.
+type Scheme interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded, a hash the scheme recognises.
+	Verify(password string, encoded string) (bool, error)
+	// Recognises reports whether encoded was made by this scheme.
+	Recognises(encoded string) bool
+	// Current reports whether encoded was made with the scheme's present settings.
+	Current(encoded string) bool
+}
+
+// Hasher makes new hashes with its preferred scheme and checks hashes made by any of its schemes.
+type Hasher struct {
+	schemes []Scheme
+}
+
+func NewHasher(preferred Scheme, legacy ...Scheme) *Hasher {
+	return &Hasher{schemes: append([]Scheme{preferred}, legacy...)}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.schemes[0].Hash(password)
+}
+
+// Verify checks password against encoded. On a match, rehash reports that encoded came from another
+// scheme or older settings and should be replaced with a fresh Hash of the password.
+func (h *Hasher) Verify(password string, encoded string) (match bool, rehash bool, err error) {
+	for i, scheme := range h.schemes {
+		if !scheme.Recognises(encoded) {
+			continue
+		}
+		match, err := scheme.Verify(password, encoded)
+		if err != nil || !match {
+			return false, false, err
+		}
+		return true, i > 0 || !scheme.Current(encoded), nil
+	}
+	return false, false, ErrUnknownHash
+}
@@ -0,0 +1,114 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testParams keep the tests fast, they are far too weak for real use.
+var testParams = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	scheme := Argon2id{Params: testParams}
+
+	hash, err := scheme.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, scheme.Recognises(hash))
+	assert.True(t, scheme.Current(hash))
+
+	other, err := scheme.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salts should differ")
+
+	match, err := scheme.Verify("correct horse", hash)
+	assert.NoError(t, err)
+	assert.True(t, match)
+
+	match, err = scheme.Verify("wrong horse", hash)
+	assert.NoError(t, err)
+	assert.False(t, match)
+
+	// hashes made before the settings changed still verify but are no longer current
+	stronger := Argon2id{Params: Argon2idParams{Memory: 2048, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+	match, err = stronger.Verify("correct horse", hash)
+	assert.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, stronger.Current(hash))
+}
+
+func TestArgon2id_Malformed(t *testing.T) {
+	tests := []string{
+		"$argon2id$",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=1,p=1$not base64!$aGFzaGhhc2g",
+	}
+
+	for _, encoded := range tests {
+		t.Run(encoded, func(t *testing.T) {
+			match, err := Argon2id{Params: testParams}.Verify("password", encoded)
+			assert.Equal(t, ErrMalformedHash, err)
+			assert.False(t, match)
+		})
+	}
+}
+
+func TestHasher_Verify(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+	current, err := Argon2id{Params: testParams}.Hash("correct horse")
+	require.NoError(t, err)
+	weaker, err := Argon2id{Params: Argon2idParams{Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}.Hash("correct horse")
+	require.NoError(t, err)
+
+	type want struct {
+		match  bool
+		rehash bool
+		err    error
+	}
+	type test struct {
+		name     string
+		password string
+		encoded  string
+		want     want
+	}
+
+	tests := []test{
+		{name: "current argon2id", password: "correct horse", encoded: current, want: want{match: true, rehash: false, err: nil}},
+		{name: "argon2id with old settings", password: "correct horse", encoded: weaker, want: want{match: true, rehash: true, err: nil}},
+		{name: "legacy bcrypt", password: "correct horse", encoded: string(legacy), want: want{match: true, rehash: true, err: nil}},
+		{name: "wrong password for bcrypt", password: "wrong horse", encoded: string(legacy), want: want{match: false, rehash: false, err: nil}},
+		{name: "wrong password for argon2id", password: "wrong horse", encoded: current, want: want{match: false, rehash: false, err: nil}},
+		{name: "locked account marker", password: "!", encoded: "!", want: want{match: false, rehash: false, err: ErrUnknownHash}},
+		{name: "unknown scheme", password: "correct horse", encoded: "$1$abc$def", want: want{match: false, rehash: false, err: ErrUnknownHash}},
+	}
+
+	hasher := NewHasher(Argon2id{Params: testParams}, Bcrypt{Cost: bcrypt.MinCost})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, rehash, err := hasher.Verify(test.password, test.encoded)
+			assert.Equal(t, test.want.err, err)
+			assert.Equal(t, test.want.match, match)
+			assert.Equal(t, test.want.rehash, rehash)
+		})
+	}
+}
+
+func TestHasher_Hash(t *testing.T) {
+	hasher := NewHasher(Bcrypt{Cost: bcrypt.MinCost}, Argon2id{Params: testParams})
+
+	hash, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$2a$"))
+
+	match, rehash, err := hasher.Verify("correct horse", hash)
+	assert.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, rehash)
+}
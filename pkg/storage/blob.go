@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+var ErrInvalidKey = errors.New("invalid blob key")
+
+type Info struct {
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Blob stores files under slash separated keys such as "content/ab/cd/<name>.png".
+type Blob interface {
+	Put(ctx context.Context, key string, content io.Reader, contentType string) error
+	// Get returns the blob's content. Callers must close it, and may seek it when it is an io.ReadSeeker.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (Info, error)
+	// URL returns a direct link to the blob, or an empty string when it has to be served through Get.
+	URL(ctx context.Context, key string) (string, error)
+}
+
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
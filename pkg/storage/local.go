@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+type Local struct {
+	root string
+}
+
+// NewLocal keeps blobs as files below root, with each key used as the relative path.
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, content io.Reader, contentType string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// write next to the target first so readers never see a partial file
+	tempFile, err := os.CreateTemp(dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = io.Copy(tempFile, content)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), target)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (Info, error) {
+	target, err := l.path(key)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, err
+	}
+	return Info{
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+	}, nil
+}
+
+func (l *Local) URL(ctx context.Context, key string) (string, error) {
+	if _, err := cleanKey(key); err != nil {
+		return "", err
+	}
+	return "", nil
+}
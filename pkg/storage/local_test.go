@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal_RoundTrip(t *testing.T) {
+	root := t.TempDir()
+	blob := NewLocal(root)
+	ctx := context.Background()
+	key := "content/ab/cd/abcd trip.png"
+
+	assert.NoError(t, blob.Put(ctx, key, strings.NewReader("image data"), "image/png"))
+	assert.FileExists(t, filepath.Join(root, "content", "ab", "cd", "abcd trip.png"))
+
+	info, err := blob.Stat(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("image data")), info.Size)
+	assert.Equal(t, "image/png", info.ContentType)
+
+	content, err := blob.Get(ctx, key)
+	assert.NoError(t, err)
+	_, seekable := content.(io.ReadSeeker)
+	assert.True(t, seekable)
+	data, err := io.ReadAll(content)
+	content.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "image data", string(data))
+
+	link, err := blob.URL(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "", link)
+
+	assert.NoError(t, blob.Delete(ctx, key))
+	_, err = blob.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = blob.Stat(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, blob.Delete(ctx, key))
+
+	entries, err := os.ReadDir(filepath.Join(root, "content", "ab", "cd"))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestLocal_InvalidKeys(t *testing.T) {
+	blob := NewLocal(t.TempDir())
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../outside", "content/../../outside", "/absolute", "content\\\\windows", "content/"} {
+		t.Run(key, func(t *testing.T) {
+			assert.ErrorIs(t, blob.Put(ctx, key, strings.NewReader("data"), ""), ErrInvalidKey)
+			_, err := blob.Get(ctx, key)
+			assert.ErrorIs(t, err, ErrInvalidKey)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	// Endpoint is the service root, e.g. "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000".
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// URLExpiry is how long links from URL stay valid. Zero leaves blobs to be served through Get.
+	URLExpiry time.Duration
+}
+
+// S3 talks to an S3-compatible service using path-style addressing ({endpoint}/{bucket}/{key}),
+// which AWS and self-hosted stand-ins such as MinIO both accept.
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	signer    signer
+	urlExpiry time.Duration
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewS3(cfg S3Config, client *http.Client) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("s3 bucket and region are required")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &S3{
+		endpoint: endpoint,
+		bucket:   cfg.Bucket,
+		signer: signer{
+			accessKey: cfg.AccessKey,
+			secretKey: cfg.SecretKey,
+			region:    cfg.Region,
+		},
+		urlExpiry: cfg.URLExpiry,
+		client:    client,
+		now:       time.Now,
+	}, nil
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	objectURL := *s.endpoint
+	objectURL.Path = s.endpoint.Path + "/" + s.bucket + "/" + key
+	return &objectURL, nil
+}
+
+func (s *S3) newRequest(ctx context.Context, method string, key string, body io.Reader) (*http.Request, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+}
+
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.signer.sign(req, payloadHash, s.now())
+	return s.client.Do(req)
+}
+
+// Put sends content in one request. The body is buffered to learn its length and hash
+// unless it can be seeked, in which case it is streamed with an unsigned payload.
+func (s *S3) Put(ctx context.Context, key string, content io.Reader, contentType string) error {
+	var body io.Reader
+	var size int64
+	var payloadHash string
+
+	if seeker, ok := content.(io.ReadSeeker); ok {
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		start, err := seeker.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		// the client closes request bodies, which must not close the caller's file
+		body, size, payloadHash = io.NopCloser(seeker), end-start, unsignedPayload
+	} else {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		body, size, payloadHash = bytes.NewReader(data), int64(len(data)), hashHex(data)
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, payloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = checkResponse(resp)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return Info{}, err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info, nil
+}
+
+// URL presigns a GET for the blob when a URLExpiry is configured.
+func (s *S3) URL(ctx context.Context, key string) (string, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return "", err
+	}
+	if s.urlExpiry <= 0 {
+		return "", nil
+	}
+	return s.signer.presign(req, s.urlExpiry, s.now()), nil
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a small in-memory stand-in for an S3-compatible server. It checks each
+// request's signature by signing a copy of it again with the shared secret.
+type fakeS3 struct {
+	signer  signer
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.validSignature(r) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != unsignedPayload && hash != hashHex(data) {
+			http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) validSignature(r *http.Request) bool {
+	signedAt, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	check := httptest.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for _, name := range []string{"Content-Type", "Range"} {
+		if val := r.Header.Get(name); val != "" {
+			check.Header.Set(name, val)
+		}
+	}
+	f.signer.sign(check, r.Header.Get("X-Amz-Content-Sha256"), signedAt)
+	return check.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func newTestS3(t *testing.T, urlExpiry time.Duration) (*S3, *fakeS3) {
+	fake := &fakeS3{
+		signer:  signer{accessKey: "minio", secretKey: "minio-secret", region: "us-east-1"},
+		objects: map[string]fakeObject{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	blob, err := NewS3(S3Config{
+		Endpoint:  server.URL,
+		Region:    "us-east-1",
+		Bucket:    "media",
+		AccessKey: "minio",
+		SecretKey: "minio-secret",
+		URLExpiry: urlExpiry,
+	}, server.Client())
+	assert.NoError(t, err)
+	return blob, fake
+}
+
+func TestS3_RoundTrip(t *testing.T) {
+	blob, fake := newTestS3(t, 0)
+	ctx := context.Background()
+	key := "content/ab/cd/abcd summer trip.png"
+
+	assert.NoError(t, blob.Put(ctx, key, io.MultiReader(strings.NewReader("image data")), "image/png"))
+	assert.Contains(t, fake.objects, "/media/"+key)
+
+	info, err := blob.Stat(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("image data")), info.Size)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), info.ModTime.UTC())
+
+	content, err := blob.Get(ctx, key)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(content)
+	content.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "image data", string(data))
+
+	assert.NoError(t, blob.Delete(ctx, key))
+	_, err = blob.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = blob.Stat(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, blob.Delete(ctx, key))
+}
+
+func TestS3_PutSeekable(t *testing.T) {
+	blob, fake := newTestS3(t, 0)
+
+	assert.NoError(t, blob.Put(context.Background(), "thumbnails/a.jpg", strings.NewReader("thumb"), "image/jpeg"))
+	assert.Equal(t, "thumb", string(fake.objects["/media/thumbnails/a.jpg"].data))
+}
+
+func TestS3_BadCredentials(t *testing.T) {
+	blob, _ := newTestS3(t, 0)
+	blob.signer.secretKey = "wrong"
+
+	err := blob.Put(context.Background(), "content/a.png", strings.NewReader("data"), "image/png")
+	assert.ErrorContains(t, err, "403")
+}
+
+func TestS3_URL(t *testing.T) {
+	blob, _ := newTestS3(t, 0)
+	link, err := blob.URL(context.Background(), "content/a.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "", link)
+
+	blob, _ = newTestS3(t, time.Minute)
+	link, err = blob.URL(context.Background(), "content/a.png")
+	assert.NoError(t, err)
+	assert.Contains(t, link, "/media/content/a.png?")
+	assert.Contains(t, link, "X-Amz-Expires=60")
+
+	_, err = blob.URL(context.Background(), "../secret")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,112 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	type args struct {
+		unix int64
+	}
+	type want struct {
+		code string
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	// The RFC vectors use 8 digits, these are their last 6.
+	tests := []test{
+		{name: "59", args: args{unix: 59}, want: want{code: "287082"}},
+		{name: "1111111109", args: args{unix: 1111111109}, want: want{code: "081804"}},
+		{name: "1111111111", args: args{unix: 1111111111}, want: want{code: "050471"}},
+		{name: "1234567890", args: args{unix: 1234567890}, want: want{code: "005924"}},
+		{name: "2000000000", args: args{unix: 2000000000}, want: want{code: "279037"}},
+		{name: "20000000000", args: args{unix: 20000000000}, want: want{code: "353130"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := Code(rfcSecret, Step(time.Unix(test.args.unix, 0)))
+			assert.NoError(t, err)
+			assert.Equal(t, test.want.code, code)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	type args struct {
+		code string
+		skew int
+	}
+	type want struct {
+		step int64
+		ok   bool
+	}
+	type test struct {
+		name string
+		args args
+		want want
+	}
+
+	previous, _ := Code(rfcSecret, step-1)
+	stale, _ := Code(rfcSecret, step-2)
+
+	tests := []test{
+		{name: "current code", args: args{code: "050471", skew: 1}, want: want{step: step, ok: true}},
+		{name: "spaces ignored", args: args{code: "050 471", skew: 1}, want: want{step: step, ok: true}},
+		{name: "previous step within skew", args: args{code: previous, skew: 1}, want: want{step: step - 1, ok: true}},
+		{name: "previous step without skew", args: args{code: previous, skew: 0}, want: want{step: 0, ok: false}},
+		{name: "outside skew", args: args{code: stale, skew: 1}, want: want{step: 0, ok: false}},
+		{name: "wrong code", args: args{code: "123456", skew: 1}, want: want{step: 0, ok: false}},
+		{name: "wrong length", args: args{code: "50471", skew: 1}, want: want{step: 0, ok: false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched, ok := Validate(rfcSecret, test.args.code, now, test.args.skew)
+			assert.Equal(t, test.want.ok, ok)
+			assert.Equal(t, test.want.step, matched)
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	now := time.Now()
+	code, err := Code(secret, Step(now))
+	assert.NoError(t, err)
+	_, ok := Validate(secret, code, now, 0)
+	assert.True(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("goserv", "alice smith", "JBSWY3DPEHPK3PXP"))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/goserv:alice smith", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "goserv", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+
+	uri, err = url.Parse(ProvisioningURI("localhost:8080", "alice", "JBSWY3DPEHPK3PXP"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/localhost%3A8080:alice", uri.EscapedPath())
+}